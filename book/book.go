@@ -0,0 +1,231 @@
+// Package book reads opening books in the Polyglot .bin format, so the
+// engine and the GUI can play well-known opening moves without searching.
+package book
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+
+	"github.com/mattellis91/go-chess/chess"
+)
+
+// entrySize is the size of a Polyglot entry: a 64-bit position key, a 16-bit
+// move, a 16-bit weight and 32 bits of learning data, all big-endian.
+const entrySize = 16
+
+var ErrNotBook = errors.New("book: not a Polyglot book")
+
+// Entry is one move the book has for a position.
+type Entry struct {
+	Move   chess.Move
+	Weight int
+	Learn  uint32
+}
+
+// Selection says how Choose picks between the moves the book has.
+type Selection int
+
+const (
+	// Weighted picks a move at random, in proportion to its weight.
+	Weighted Selection = iota
+	// BestOnly picks the move with the highest weight.
+	BestOnly
+)
+
+// Book is a Polyglot book. Entries are read from it as they are needed, so
+// even a large book does not have to fit in memory. A Book may be used by
+// several goroutines at once.
+type Book struct {
+	r       io.ReaderAt
+	entries int64
+	closer  io.Closer
+}
+
+// Open opens the Polyglot book at path. The file stays open until Close.
+func Open(path string) (*Book, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	b, err := New(f, info.Size())
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	b.closer = f
+	return b, nil
+}
+
+// New reads a Polyglot book of size bytes from r, which must hold entries
+// sorted by key as Polyglot writes them.
+func New(r io.ReaderAt, size int64) (*Book, error) {
+	if size%entrySize != 0 {
+		return nil, ErrNotBook
+	}
+	return &Book{r: r, entries: size / entrySize}, nil
+}
+
+// Close closes the file opened by Open.
+func (b *Book) Close() error {
+	if b.closer == nil {
+		return nil
+	}
+	return b.closer.Close()
+}
+
+// Len returns the number of entries in the book.
+func (b *Book) Len() int {
+	return int(b.entries)
+}
+
+// Lookup returns the book moves for the position in gs, highest weight
+// first. Entries whose move is not legal in the position, as happens when
+// two positions share a key, are left out. gs is not modified.
+func (b *Book) Lookup(gs *chess.GameState) ([]Entry, error) {
+	var buf [entrySize]byte
+	read := func(i int64) (uint64, error) {
+		if _, err := b.r.ReadAt(buf[:], i*entrySize); err != nil {
+			return 0, err
+		}
+		return binary.BigEndian.Uint64(buf[:8]), nil
+	}
+
+	// Find the first entry whose key is not less than the position's.
+	var readErr error
+	first := sort.Search(int(b.entries), func(i int) bool {
+		key, err := read(int64(i))
+		if err != nil {
+			readErr = err
+			return true
+		}
+		return key >= gs.Hash
+	})
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	var legal []chess.Move
+	entries := []Entry{}
+	for i := int64(first); i < b.entries; i++ {
+		key, err := read(i)
+		if err != nil {
+			return nil, err
+		}
+		if key != gs.Hash {
+			break
+		}
+		if legal == nil {
+			legal = gs.Clone().GetValidMoves()
+		}
+		move, ok := DecodeMove(binary.BigEndian.Uint16(buf[8:10]), legal)
+		if !ok {
+			continue
+		}
+		entries = append(entries, Entry{
+			Move:   move,
+			Weight: int(binary.BigEndian.Uint16(buf[10:12])),
+			Learn:  binary.BigEndian.Uint32(buf[12:16]),
+		})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Weight > entries[j].Weight
+	})
+	return entries, nil
+}
+
+// Choose picks one of the book moves for the position in gs. It reports
+// false if the book has none, or every move it has has no weight.
+func (b *Book) Choose(gs *chess.GameState, selection Selection) (chess.Move, bool, error) {
+	entries, err := b.Lookup(gs)
+	if err != nil || len(entries) == 0 {
+		return chess.Move{}, false, err
+	}
+	total := 0
+	for _, entry := range entries {
+		total += entry.Weight
+	}
+	if total == 0 {
+		return chess.Move{}, false, nil
+	}
+	if selection == BestOnly {
+		return entries[0].Move, true, nil
+	}
+	n := rand.Intn(total)
+	for _, entry := range entries {
+		if n < entry.Weight {
+			return entry.Move, true, nil
+		}
+		n -= entry.Weight
+	}
+	return entries[0].Move, true, nil
+}
+
+// DecodeMove finds the move encoded in a Polyglot entry among legal. The
+// encoding packs the destination file and rank in bits 0 to 5, the start
+// square in bits 6 to 11 and the promotion piece in bits 12 to 14. Castling is
+// written as the king taking its own rook.
+func DecodeMove(encoded uint16, legal []chess.Move) (chess.Move, bool) {
+	toFile, toRank := int(encoded&7), int(encoded>>3&7)
+	fromFile, fromRank := int(encoded>>6&7), int(encoded>>9&7)
+	promotion := int(encoded >> 12 & 7)
+	if promotion > 4 {
+		return chess.Move{}, false
+	}
+
+	from := chess.Square{Row: 7 - fromRank, Col: fromFile}
+	to := chess.Square{Row: 7 - toRank, Col: toFile}
+	for _, move := range legal {
+		if move.StartRow != from.Row || move.StartCol != from.Col {
+			continue
+		}
+		if move.IsCastleMove {
+			// e1h1 is O-O and e1a1 O-O-O.
+			rookCol := 7
+			if move.EndCol < move.StartCol {
+				rookCol = 0
+			}
+			if to.Row == move.EndRow && to.Col == rookCol {
+				return move, true
+			}
+			continue
+		}
+		if move.EndRow != to.Row || move.EndCol != to.Col {
+			continue
+		}
+		if promotion == 0 && !move.IsPawnPromotion ||
+			promotion > 0 && move.IsPawnPromotion && move.PromotionPiece[1] == " NBRQ"[promotion] {
+			return move, true
+		}
+	}
+	return chess.Move{}, false
+}
+
+// EncodeMove is the inverse of DecodeMove.
+func EncodeMove(move chess.Move) uint16 {
+	toCol := move.EndCol
+	if move.IsCastleMove {
+		toCol = 7
+		if move.EndCol < move.StartCol {
+			toCol = 0
+		}
+	}
+	encoded := uint16(toCol) | uint16(7-move.EndRow)<<3 | uint16(move.StartCol)<<6 | uint16(7-move.StartRow)<<9
+	if move.IsPawnPromotion {
+		for i, piece := range " NBRQ" {
+			if byte(piece) == move.PromotionPiece[1] {
+				encoded |= uint16(i) << 12
+			}
+		}
+	}
+	return encoded
+}
@@ -0,0 +1,124 @@
+package book
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"testing"
+
+	"github.com/mattellis91/go-chess/chess"
+)
+
+type testEntry struct {
+	key    uint64
+	move   uint16
+	weight uint16
+}
+
+// makeBook writes entries in the Polyglot format, sorted by key.
+func makeBook(t *testing.T, entries []testEntry) *Book {
+	t.Helper()
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+	var buf bytes.Buffer
+	for _, e := range entries {
+		binary.Write(&buf, binary.BigEndian, e.key)
+		binary.Write(&buf, binary.BigEndian, e.move)
+		binary.Write(&buf, binary.BigEndian, e.weight)
+		binary.Write(&buf, binary.BigEndian, uint32(0))
+	}
+	b, err := New(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// encode returns the Polyglot encoding of a move from file and rank numbers.
+func encode(fromFile, fromRank, toFile, toRank, promotion int) uint16 {
+	return uint16(toFile | toRank<<3 | fromFile<<6 | fromRank<<9 | promotion<<12)
+}
+
+func TestLookup(t *testing.T) {
+	gs := chess.NewGameState()
+	if gs.Hash != 0x463b96181691fc9c {
+		t.Fatalf("start position key %#x is not Polyglot's", gs.Hash)
+	}
+	b := makeBook(t, []testEntry{
+		{gs.Hash, encode(4, 1, 4, 3, 0), 10},    // e2e4
+		{gs.Hash, encode(3, 1, 3, 3, 0), 30},    // d2d4
+		{gs.Hash, encode(4, 1, 4, 4, 0), 50},    // e2e5 is not legal
+		{gs.Hash - 1, encode(6, 0, 5, 2, 0), 1}, // another position
+		{gs.Hash + 1, encode(6, 0, 5, 2, 0), 1},
+	})
+	entries, err := b.Lookup(gs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, entry := range entries {
+		got = append(got, entry.Move.GetUCINotation())
+	}
+	if len(got) != 2 || got[0] != "d2d4" || got[1] != "e2e4" {
+		t.Errorf("got %v, want [d2d4 e2e4]", got)
+	}
+
+	move, ok, err := b.Choose(gs, BestOnly)
+	if err != nil || !ok || move.GetUCINotation() != "d2d4" {
+		t.Errorf("best only chose %s, %v, %v", move.GetUCINotation(), ok, err)
+	}
+	chosen := map[string]int{}
+	for i := 0; i < 400; i++ {
+		move, _, _ := b.Choose(gs, Weighted)
+		chosen[move.GetUCINotation()]++
+	}
+	if chosen["e2e4"] == 0 || chosen["d2d4"] <= chosen["e2e4"] {
+		t.Errorf("weighted choices %v", chosen)
+	}
+
+	gs.MakeMove(entries[0].Move)
+	if _, ok, _ := b.Choose(gs, Weighted); ok {
+		t.Error("chose a move for a position not in the book")
+	}
+}
+
+func TestDecodeMove(t *testing.T) {
+	tests := []struct {
+		fen     string
+		encoded uint16
+		want    string
+	}{
+		// Castling is the king taking its own rook.
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", encode(4, 0, 7, 0, 0), "e1g1"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", encode(4, 0, 0, 0, 0), "e1c1"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", encode(4, 7, 7, 7, 0), "e8g8"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", encode(4, 7, 0, 7, 0), "e8c8"},
+		// A king move to g1 is not castling when the king can simply go there.
+		{"4k3/8/8/8/8/8/8/5K2 w - - 0 1", encode(5, 0, 6, 0, 0), "f1g1"},
+		{"4k3/P7/8/8/8/8/8/4K3 w - - 0 1", encode(0, 6, 0, 7, 4), "a7a8q"},
+		{"4k3/P7/8/8/8/8/8/4K3 w - - 0 1", encode(0, 6, 0, 7, 1), "a7a8n"},
+		{"4k3/P7/8/8/8/8/8/4K3 w - - 0 1", encode(0, 6, 0, 7, 0), ""},
+		{"4k3/P7/8/8/8/8/8/4K3 w - - 0 1", encode(0, 6, 0, 7, 5), ""},
+		{"4k3/P7/8/8/8/8/8/4K3 w - - 0 1", encode(0, 6, 0, 7, 7), ""},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w - - 0 1", encode(4, 0, 7, 0, 0), ""},
+	}
+	for _, test := range tests {
+		gs, err := chess.NewGameStateFromFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		legal := gs.GetValidMoves()
+		move, ok := DecodeMove(test.encoded, legal)
+		if got := move.GetUCINotation(); ok != (test.want != "") || ok && got != test.want {
+			t.Errorf("%s: %#x decoded to %q, %v, want %q", test.fen, test.encoded, got, ok, test.want)
+		}
+		if ok && EncodeMove(move) != test.encoded {
+			t.Errorf("%s: %s encoded to %#x, want %#x", test.fen, test.want, EncodeMove(move), test.encoded)
+		}
+	}
+}
+
+func TestNotABook(t *testing.T) {
+	if _, err := New(bytes.NewReader(make([]byte, 17)), 17); err != ErrNotBook {
+		t.Errorf("got %v, want ErrNotBook", err)
+	}
+}
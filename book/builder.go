@@ -0,0 +1,198 @@
+package book
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sort"
+
+	"github.com/mattellis91/go-chess/chess"
+)
+
+// DefaultMaxEntries is the number of moves a Builder made by NewBuilder keeps
+// count of before it starts forgetting the rarest.
+const DefaultMaxEntries = 1 << 22
+
+// Builder counts the moves played in a collection of games and writes the
+// ones worth playing as a Polyglot book.
+type Builder struct {
+	// MaxPlies is how far into each game moves are counted, or 0 for the
+	// whole game.
+	MaxPlies int
+	// MinGames leaves out moves played in fewer games.
+	MinGames int
+	// MinScore leaves out moves that scored less than this for the side
+	// that played them, as a fraction: 1 for a win and 0.5 for a draw.
+	MinScore float64
+	// MaxEntries bounds the memory the counts take. Whenever there are more
+	// moves than this, the ones seen in the fewest games are dropped, so the
+	// moves that survive are those common enough to matter. 0 is no limit.
+	MaxEntries int
+
+	stats map[moveKey]*moveStats
+	// pruned is the most games any move dropped so far had been seen in.
+	pruned uint32
+}
+
+type moveKey struct {
+	key  uint64
+	move uint16
+}
+
+// moveStats are counted from the point of view of the side that moved.
+type moveStats struct {
+	games, wins, draws uint32
+}
+
+func NewBuilder() *Builder {
+	return &Builder{MinGames: 1, MaxEntries: DefaultMaxEntries, stats: map[moveKey]*moveStats{}}
+}
+
+// AddGame counts the moves of the main line of game. Games without a result
+// are skipped, since their moves cannot be scored.
+func (b *Builder) AddGame(game *chess.PGNGame) {
+	var whiteScore uint32
+	switch game.Result {
+	case "1-0":
+		whiteScore = 2
+	case "1/2-1/2":
+		whiteScore = 1
+	case "0-1":
+		whiteScore = 0
+	default:
+		return
+	}
+
+	gs := chess.NewGameState()
+	if fen := game.Tag("FEN"); fen != "" {
+		var err error
+		if gs, err = chess.NewGameStateFromFEN(fen); err != nil {
+			return
+		}
+	}
+	if b.stats == nil {
+		b.stats = map[moveKey]*moveStats{}
+	}
+	for ply, move := range game.Moves {
+		if b.MaxPlies > 0 && ply >= b.MaxPlies {
+			break
+		}
+		score := whiteScore
+		if !gs.WhiteToMove {
+			score = 2 - score
+		}
+		k := moveKey{gs.Hash, EncodeMove(move.Move)}
+		s := b.stats[k]
+		if s == nil {
+			s = &moveStats{}
+			b.stats[k] = s
+		}
+		s.games++
+		switch score {
+		case 2:
+			s.wins++
+		case 1:
+			s.draws++
+		}
+		gs.MakeMove(move.Move)
+	}
+	if b.MaxEntries > 0 && len(b.stats) > b.MaxEntries {
+		b.prune()
+	}
+}
+
+// prune drops the moves seen in the fewest games until at most three quarters
+// of MaxEntries are left, making room for a run of new moves.
+func (b *Builder) prune() {
+	for len(b.stats) > b.MaxEntries*3/4 {
+		b.pruned++
+		for k, s := range b.stats {
+			if s.games <= b.pruned {
+				delete(b.stats, k)
+			}
+		}
+	}
+}
+
+// AddPGN counts the moves of every game in the PGN text read from r, one game
+// at a time, so collections of any size can be read. Games that cannot be
+// read are skipped. It returns the number of games counted and skipped.
+func (b *Builder) AddPGN(r io.Reader) (added int, skipped int, err error) {
+	pr := chess.NewPGNReader(r)
+	for {
+		game, err := pr.Next()
+		var pgnErr *chess.PGNError
+		switch {
+		case err == io.EOF:
+			return added, skipped, nil
+		case errors.As(err, &pgnErr):
+			skipped++
+			continue
+		case err != nil:
+			return added, skipped, err
+		}
+		b.AddGame(game)
+		added++
+	}
+}
+
+// Len returns the number of moves counted so far.
+func (b *Builder) Len() int {
+	return len(b.stats)
+}
+
+// WriteTo writes the moves that pass MinGames and MinScore as a Polyglot book,
+// sorted by position key and then by weight. A move weighs two points per win
+// and one per draw, scaled down if need be to fit in 16 bits.
+func (b *Builder) WriteTo(w io.Writer) (int64, error) {
+	type bookEntry struct {
+		moveKey
+		points uint64
+	}
+	entries := []bookEntry{}
+	var maxPoints uint64
+	for k, s := range b.stats {
+		if int(s.games) < b.MinGames || float64(2*s.wins+s.draws) < b.MinScore*float64(2*s.games) {
+			continue
+		}
+		points := uint64(2*s.wins + s.draws)
+		entries = append(entries, bookEntry{k, points})
+		if points > maxPoints {
+			maxPoints = points
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].key != entries[j].key {
+			return entries[i].key < entries[j].key
+		}
+		if entries[i].points != entries[j].points {
+			return entries[i].points > entries[j].points
+		}
+		return entries[i].move < entries[j].move
+	})
+
+	bw := bufio.NewWriter(w)
+	var written int64
+	var buf [entrySize]byte
+	for _, e := range entries {
+		weight := e.points
+		if maxPoints > 0xffff {
+			weight = e.points * 0xffff / maxPoints
+			if weight == 0 && e.points > 0 {
+				// A move that scored at all stays playable.
+				weight = 1
+			}
+		}
+		binary.BigEndian.PutUint64(buf[:8], e.key)
+		binary.BigEndian.PutUint16(buf[8:10], e.move)
+		binary.BigEndian.PutUint16(buf[10:12], uint16(weight))
+		binary.BigEndian.PutUint32(buf[12:16], 0)
+		n, err := bw.Write(buf[:])
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, bw.Flush()
+}
@@ -0,0 +1,116 @@
+package book
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mattellis91/go-chess/chess"
+)
+
+const testGames = `[Result "1-0"]
+1. e4 e5 2. Nf3 Nc6 1-0
+
+[Result "1/2-1/2"]
+1. e4 c5 2. Nf3 1/2-1/2
+
+[Result "0-1"]
+1. d4 d5 (1... Nf6 2. c4) 2. c4 0-1
+
+[Result "*"]
+1. c4 *
+
+[Result "1-0"]
+1. e4 Qxe4 1-0
+
+[Result "1-0"]
+1. e4 e5 2. O-O 1-0
+`
+
+func buildBook(t *testing.T, builder *Builder) *Book {
+	t.Helper()
+	added, skipped, err := builder.AddPGN(strings.NewReader(testGames))
+	if err != nil || added != 4 || skipped != 2 {
+		t.Fatalf("added %d games and skipped %d: %v", added, skipped, err)
+	}
+	var buf bytes.Buffer
+	if _, err := builder.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	b, err := New(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// bookMoves returns the book moves after the UCI moves in line, with their
+// weights.
+func bookMoves(t *testing.T, b *Book, line ...string) map[string]int {
+	t.Helper()
+	gs := chess.NewGameState()
+	for _, text := range line {
+		move, err := gs.ParseMove(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gs.MakeMove(move)
+		gs.ValidMoves = nil
+	}
+	entries, err := b.Lookup(gs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	moves := map[string]int{}
+	for _, entry := range entries {
+		moves[entry.Move.GetUCINotation()] = entry.Weight
+	}
+	return moves
+}
+
+func TestBuilder(t *testing.T) {
+	b := buildBook(t, NewBuilder())
+	// e4 won once and drew once, d4 lost and c4 was in an unfinished game.
+	if got := bookMoves(t, b); len(got) != 2 || got["e2e4"] != 3 || got["d2d4"] != 0 {
+		t.Errorf("start position: %v", got)
+	}
+	if got := bookMoves(t, b, "e2e4"); len(got) != 2 || got["e7e5"] != 0 || got["c7c5"] != 1 {
+		t.Errorf("after e4: %v", got)
+	}
+	// Variations are not counted.
+	if got := bookMoves(t, b, "d2d4"); len(got) != 1 || got["d7d5"] != 2 {
+		t.Errorf("after d4: %v", got)
+	}
+	if got := bookMoves(t, b, "e2e4", "e7e5", "g1f3", "b8c6"); len(got) != 0 {
+		t.Errorf("after the end of the games: %v", got)
+	}
+}
+
+func TestBuilderFilters(t *testing.T) {
+	builder := NewBuilder()
+	builder.MaxPlies = 1
+	builder.MinGames = 2
+	b := buildBook(t, builder)
+	if got := bookMoves(t, b); len(got) != 1 || got["e2e4"] != 3 {
+		t.Errorf("start position: %v", got)
+	}
+	if got := bookMoves(t, b, "e2e4"); len(got) != 0 {
+		t.Errorf("after e4, past MaxPlies: %v", got)
+	}
+
+	builder = NewBuilder()
+	builder.MinScore = 0.5
+	b = buildBook(t, builder)
+	if got := bookMoves(t, b); len(got) != 1 || got["e2e4"] != 3 {
+		t.Errorf("start position with MinScore: %v", got)
+	}
+}
+
+func TestBuilderMaxEntries(t *testing.T) {
+	builder := NewBuilder()
+	builder.MaxEntries = 4
+	buildBook(t, builder)
+	if builder.Len() > 4 {
+		t.Errorf("%d moves counted, want at most 4", builder.Len())
+	}
+}
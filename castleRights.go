@@ -1,8 +0,0 @@
-package main
-
-type CastleRights struct {
-	wks bool // white king side
-	wqs bool // white queen side
-	bks bool // black king side
-	bqs bool // black queen side
-}
\ No newline at end of file
@@ -0,0 +1,135 @@
+package chess
+
+import "math/bits"
+
+// Bitboard is a set of squares, one bit per square. Bit row*8+col stands for
+// Square{row, col}, so bit 0 is a8 and bit 63 is h1.
+type Bitboard uint64
+
+func SquareBit(row int, col int) Bitboard {
+	return Bitboard(1) << uint(row*8+col)
+}
+
+func (b Bitboard) Has(sq int) bool {
+	return b&(Bitboard(1)<<uint(sq)) != 0
+}
+
+func (b Bitboard) Count() int {
+	return bits.OnesCount64(uint64(b))
+}
+
+// First returns the lowest numbered square in the set, which must not be empty.
+func (b Bitboard) First() int {
+	return bits.TrailingZeros64(uint64(b))
+}
+
+// PopFirst removes the lowest numbered square from the set and returns it.
+func (b *Bitboard) PopFirst() int {
+	sq := bits.TrailingZeros64(uint64(*b))
+	*b &= *b - 1
+	return sq
+}
+
+// Ray directions. The first four run towards higher square numbers.
+const (
+	south = iota
+	east
+	southEast
+	southWest
+	north
+	west
+	northWest
+	northEast
+)
+
+var rayDeltas = [8]PieceDelta{{1, 0}, {0, 1}, {1, 1}, {1, -1}, {-1, 0}, {0, -1}, {-1, -1}, {-1, 1}}
+
+var (
+	rays          [8][64]Bitboard
+	knightAttacks [64]Bitboard
+	kingAttacks   [64]Bitboard
+	// pawnAttacks[White][sq] holds the squares a white pawn on sq attacks.
+	pawnAttacks [2][64]Bitboard
+)
+
+func init() {
+	onBoard := func(row int, col int) bool {
+		return 0 <= row && row < 8 && 0 <= col && col < 8
+	}
+
+	knightDeltas := []PieceDelta{{-2, -1}, {-2, 1}, {-1, -2}, {-1, 2}, {1, -2}, {1, 2}, {2, -1}, {2, 1}}
+
+	for row := 0; row < 8; row++ {
+		for col := 0; col < 8; col++ {
+			sq := row*8 + col
+
+			for d, delta := range rayDeltas {
+				for r, c := row+delta.Row, col+delta.Col; onBoard(r, c); r, c = r+delta.Row, c+delta.Col {
+					rays[d][sq] |= SquareBit(r, c)
+				}
+				if onBoard(row+delta.Row, col+delta.Col) {
+					kingAttacks[sq] |= SquareBit(row+delta.Row, col+delta.Col)
+				}
+			}
+
+			for _, delta := range knightDeltas {
+				if onBoard(row+delta.Row, col+delta.Col) {
+					knightAttacks[sq] |= SquareBit(row+delta.Row, col+delta.Col)
+				}
+			}
+
+			for _, dc := range []int{-1, 1} {
+				if onBoard(row-1, col+dc) {
+					pawnAttacks[White][sq] |= SquareBit(row-1, col+dc)
+				}
+				if onBoard(row+1, col+dc) {
+					pawnAttacks[Black][sq] |= SquareBit(row+1, col+dc)
+				}
+			}
+		}
+	}
+}
+
+// rayAttacks returns the squares a slider on sq reaches in direction d,
+// stopping at and including the first occupied square.
+func rayAttacks(d int, sq int, occupied Bitboard) Bitboard {
+	ray := rays[d][sq]
+	blockers := ray & occupied
+	if blockers == 0 {
+		return ray
+	}
+	var blocker int
+	if d < north {
+		blocker = bits.TrailingZeros64(uint64(blockers))
+	} else {
+		blocker = 63 - bits.LeadingZeros64(uint64(blockers))
+	}
+	return ray ^ rays[d][blocker]
+}
+
+func RookAttacks(sq int, occupied Bitboard) Bitboard {
+	return rayAttacks(north, sq, occupied) | rayAttacks(south, sq, occupied) |
+		rayAttacks(east, sq, occupied) | rayAttacks(west, sq, occupied)
+}
+
+func BishopAttacks(sq int, occupied Bitboard) Bitboard {
+	return rayAttacks(northEast, sq, occupied) | rayAttacks(northWest, sq, occupied) |
+		rayAttacks(southEast, sq, occupied) | rayAttacks(southWest, sq, occupied)
+}
+
+func QueenAttacks(sq int, occupied Bitboard) Bitboard {
+	return RookAttacks(sq, occupied) | BishopAttacks(sq, occupied)
+}
+
+func KnightAttacks(sq int) Bitboard {
+	return knightAttacks[sq]
+}
+
+func KingAttacks(sq int) Bitboard {
+	return kingAttacks[sq]
+}
+
+// PawnAttacks returns the squares a pawn of the given colour attacks from sq.
+func PawnAttacks(color int, sq int) Bitboard {
+	return pawnAttacks[color][sq]
+}
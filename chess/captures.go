@@ -0,0 +1,170 @@
+package chess
+
+// GetValidCaptures returns the legal moves that capture a piece, including en
+// passant and promotions that capture. It finds them without generating the
+// quiet moves, so it is cheaper than filtering GetValidMoves, which makes it
+// suited to a quiescence search. Unlike GetValidMoves it does not update
+// Checkmate or Stalemate.
+func (gs *GameState) GetValidCaptures() []Move {
+	gs.CurrentPlayerInCheck, gs.Pins, gs.Checks = gs.CheckForPinsAndChecks()
+	if gs.CurrentPlayerInCheck {
+		// Few moves escape a check, so take the captures from all of them.
+		checkmate, stalemate := gs.Checkmate, gs.Stalemate
+		moves := []Move{}
+		for _, move := range gs.GetValidMoves() {
+			if isCapture(move) {
+				moves = append(moves, move)
+			}
+		}
+		gs.Checkmate, gs.Stalemate = checkmate, stalemate
+		return moves
+	}
+
+	allyColor, enemyColor := byte('w'), byte('b')
+	forward := -1
+	if !gs.WhiteToMove {
+		allyColor, enemyColor = 'b', 'w'
+		forward = 1
+	}
+
+	moves := []Move{}
+	for r := 0; r < 8; r++ {
+		for c := 0; c < 8; c++ {
+			if gs.Board[r][c][0] != allyColor {
+				continue
+			}
+			start := Square{r, c}
+			pinDirection, pinned := gs.pinDirection(r, c)
+			canMove := func(direction PieceDelta) bool {
+				return !pinned || pinDirection == direction || pinDirection == (PieceDelta{-direction.Row, -direction.Col})
+			}
+
+			switch gs.Board[r][c][1] {
+			case 'p':
+				for _, dc := range []int{-1, 1} {
+					end := Square{r + forward, c + dc}
+					if end.Row < 0 || end.Row >= 8 || end.Col < 0 || end.Col >= 8 {
+						continue
+					}
+					if gs.Board[end.Row][end.Col][0] == enemyColor {
+						// A pinned pawn can only take the piece pinning it.
+						if !pinned || pinDirection == (PieceDelta{forward, dc}) {
+							moves = addPawnMoves(moves, start, end, gs.Board)
+						}
+					} else if end == gs.EnPassantSquare {
+						moves = gs.addEnPassantMove(moves, start, end)
+					}
+				}
+			case 'N':
+				if pinned {
+					continue
+				}
+				for _, d := range []PieceDelta{{-2, -1}, {-2, 1}, {-1, -2}, {-1, 2}, {1, -2}, {1, 2}, {2, -1}, {2, 1}} {
+					endRow, endCol := r+d.Row, c+d.Col
+					if 0 <= endRow && endRow < 8 && 0 <= endCol && endCol < 8 && gs.Board[endRow][endCol][0] == enemyColor {
+						moves = append(moves, NewMove(start, Square{endRow, endCol}, gs.Board, false, false))
+					}
+				}
+			case 'R', 'B', 'Q':
+				for j, d := range []PieceDelta{{-1, 0}, {1, 0}, {0, -1}, {0, 1}, {-1, -1}, {-1, 1}, {1, -1}, {1, 1}} {
+					diagonal := j >= 4
+					piece := gs.Board[r][c][1]
+					if (piece == 'R' && diagonal) || (piece == 'B' && !diagonal) || !canMove(d) {
+						continue
+					}
+					for i := 1; i < 8; i++ {
+						endRow, endCol := r+d.Row*i, c+d.Col*i
+						if endRow < 0 || endRow >= 8 || endCol < 0 || endCol >= 8 {
+							break
+						}
+						if endPiece := gs.Board[endRow][endCol]; endPiece != "--" {
+							if endPiece[0] == enemyColor {
+								moves = append(moves, NewMove(start, Square{endRow, endCol}, gs.Board, false, false))
+							}
+							break
+						}
+					}
+				}
+			case 'K':
+				for _, move := range gs.GetKingMoves(r, c) {
+					if move.PieceCaptured != "--" {
+						moves = append(moves, move)
+					}
+				}
+			}
+		}
+	}
+	return moves
+}
+
+// pinDirection returns the direction of the pin on the piece at r, c, if it
+// is pinned. Unlike the move generators it leaves gs.Pins as it is.
+func (gs *GameState) pinDirection(r int, c int) (PieceDelta, bool) {
+	for _, pin := range gs.Pins {
+		if pin.Row == r && pin.Col == c {
+			return pin.Direction, true
+		}
+	}
+	return PieceDelta{}, false
+}
+
+// GetValidCaptures returns the legal moves that capture a piece, as
+// GameState.GetValidCaptures does, and likewise leaves Checkmate and
+// Stalemate alone.
+func (p *Position) GetValidCaptures() []Move {
+	us := p.sideToMove()
+	them := 1 - us
+	occupied := p.Occupied()
+	moves := make([]Move, 0, 16)
+
+	epBit := Bitboard(0)
+	if p.EnPassantSquare != GetNullSquare() {
+		epBit = SquareBit(p.EnPassantSquare.Row, p.EnPassantSquare.Col)
+	}
+	pawns := p.Pieces[us][Pawn]
+	for pawns != 0 {
+		from := pawns.PopFirst()
+		captures := PawnAttacks(us, from) & p.Colors[them]
+		for captures != 0 {
+			moves = p.addPawnMoves(moves, from, captures.PopFirst())
+		}
+		if PawnAttacks(us, from)&epBit != 0 {
+			move := p.newMove(from, epBit.First())
+			move.IsEnPassant = true
+			moves = append(moves, move)
+		}
+	}
+	for pieceType := Knight; pieceType <= King; pieceType++ {
+		pieces := p.Pieces[us][pieceType]
+		for pieces != 0 {
+			from := pieces.PopFirst()
+			var attacks Bitboard
+			switch pieceType {
+			case Knight:
+				attacks = KnightAttacks(from)
+			case Bishop:
+				attacks = BishopAttacks(from, occupied)
+			case Rook:
+				attacks = RookAttacks(from, occupied)
+			case Queen:
+				attacks = QueenAttacks(from, occupied)
+			case King:
+				attacks = KingAttacks(from)
+			}
+			attacks &= p.Colors[them]
+			for attacks != 0 {
+				moves = append(moves, p.newMove(from, attacks.PopFirst()))
+			}
+		}
+	}
+
+	legal := moves[:0]
+	for _, move := range moves {
+		p.MakeMove(move)
+		if p.AttackersOf(p.KingSquare(us), them, p.Occupied()) == 0 {
+			legal = append(legal, move)
+		}
+		p.UndoMove()
+	}
+	return legal
+}
@@ -0,0 +1,58 @@
+package chess
+
+import "testing"
+
+// checkCaptures walks the tree below gs and compares GetValidCaptures, of
+// both GameState and Position, with the captures among GetValidMoves at every
+// node.
+func checkCaptures(t *testing.T, gs *GameState, depth int) {
+	t.Helper()
+	moves := gs.GetValidMoves()
+	want := []Move{}
+	for _, move := range moves {
+		if isCapture(move) {
+			want = append(want, move)
+		}
+	}
+	wantIds := sortedMoveIds(want)
+	for _, captures := range [][]Move{gs.GetValidCaptures(), NewPosition(gs).GetValidCaptures()} {
+		got := sortedMoveIds(captures)
+		if len(got) != len(wantIds) {
+			t.Fatalf("%s: got captures %v, want %v", gs.FEN(), got, wantIds)
+		}
+		for i := range got {
+			if got[i] != wantIds[i] {
+				t.Fatalf("%s: got captures %v, want %v", gs.FEN(), got, wantIds)
+			}
+		}
+	}
+	if depth == 0 {
+		return
+	}
+	for _, move := range moves {
+		gs.MakeMove(move)
+		checkCaptures(t, gs, depth-1)
+		gs.UndoMove()
+	}
+}
+
+func TestGetValidCaptures(t *testing.T) {
+	fens := []string{
+		// A pawn pinned on a diagonal may take its pinner but nothing else.
+		"4k3/8/8/1b6/2Pp4/3K4/8/8 w - - 0 1",
+		// Taking en passant would expose the king along the rank.
+		"8/8/8/KPp4r/8/8/8/4k3 w - c6 0 1",
+		// Check from a knight that can be taken by the king or a pawn.
+		"4k3/8/8/8/8/3n4/2P1P3/4K3 w - - 0 1",
+	}
+	for _, position := range perftPositions {
+		fens = append(fens, position.fen)
+	}
+	for _, fen := range fens {
+		gs, err := NewGameStateFromFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkCaptures(t, gs, 2)
+	}
+}
@@ -0,0 +1,8 @@
+package chess
+
+type CastleRights struct {
+	WKS bool // white king side
+	WQS bool // white queen side
+	BKS bool // black king side
+	BQS bool // black queen side
+}
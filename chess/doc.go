@@ -0,0 +1,3 @@
+// Package chess implements the rules of chess: board state, legal move
+// generation, making and undoing moves and detecting the end of the game.
+package chess
@@ -0,0 +1,257 @@
+package chess
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const StartingPositionFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+var fenToPiece = map[byte]string{
+	'P': "wp", 'N': "wN", 'B': "wB", 'R': "wR", 'Q': "wQ", 'K': "wK",
+	'p': "bp", 'n': "bN", 'b': "bB", 'r': "bR", 'q': "bQ", 'k': "bK",
+}
+
+var pieceToFen = map[string]byte{
+	"wp": 'P', "wN": 'N', "wB": 'B', "wR": 'R', "wQ": 'Q', "wK": 'K',
+	"bp": 'p', "bN": 'n', "bB": 'b', "bR": 'r', "bQ": 'q', "bK": 'k',
+}
+
+// FENError describes why a FEN string could not be turned into a GameState.
+type FENError struct {
+	FEN    string
+	Field  string
+	Reason string
+}
+
+func (e *FENError) Error() string {
+	return fmt.Sprintf("chess: invalid FEN %q: %s: %s", e.FEN, e.Field, e.Reason)
+}
+
+// NewGameStateFromFEN builds a GameState from a FEN string. The halfmove clock
+// and fullmove number may be omitted, in which case they default to 0 and 1.
+func NewGameStateFromFEN(fen string) (*GameState, error) {
+	fail := func(field string, format string, args ...interface{}) (*GameState, error) {
+		return nil, &FENError{FEN: fen, Field: field, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	fields := strings.Fields(fen)
+	if len(fields) != 4 && len(fields) != 6 {
+		return fail("fields", "expected 6 space separated fields (or 4 without the clocks), got %d", len(fields))
+	}
+
+	gs := &GameState{
+		EnPassantSquare: GetNullSquare(),
+		WhiteKingSquare: GetNullSquare(),
+		BlackKingSquare: GetNullSquare(),
+		FullmoveNumber:  1,
+		MoveLog:         []Move{},
+		UndoLog:         []UndoRecord{},
+	}
+
+	ranks := strings.Split(fields[0], "/")
+	if len(ranks) != 8 {
+		return fail("board", "expected 8 ranks, got %d", len(ranks))
+	}
+	for r, rank := range ranks {
+		c := 0
+		for i := 0; i < len(rank); i++ {
+			ch := rank[i]
+			if ch >= '1' && ch <= '8' {
+				if c+int(ch-'0') > 8 {
+					return fail("board", "rank %d has more than 8 squares", 8-r)
+				}
+				for n := 0; n < int(ch-'0'); n++ {
+					gs.Board[r][c] = "--"
+					c++
+				}
+				continue
+			}
+			piece, ok := fenToPiece[ch]
+			if !ok {
+				return fail("board", "unknown piece %q on rank %d", ch, 8-r)
+			}
+			if c >= 8 {
+				return fail("board", "rank %d has more than 8 squares", 8-r)
+			}
+			if piece[1] == 'p' && (r == 0 || r == 7) {
+				return fail("board", "pawn on rank %d", 8-r)
+			}
+			if piece == "wK" || piece == "bK" {
+				kingSquare := &gs.WhiteKingSquare
+				if piece == "bK" {
+					kingSquare = &gs.BlackKingSquare
+				}
+				if kingSquare.Row != -1 {
+					return fail("board", "more than one %s king", colorName(piece[0]))
+				}
+				*kingSquare = Square{r, c}
+			}
+			gs.Board[r][c] = piece
+			c++
+		}
+		if c != 8 {
+			return fail("board", "rank %d has %d squares, expected 8", 8-r, c)
+		}
+	}
+	if gs.WhiteKingSquare.Row == -1 {
+		return fail("board", "no white king")
+	}
+	if gs.BlackKingSquare.Row == -1 {
+		return fail("board", "no black king")
+	}
+
+	switch fields[1] {
+	case "w":
+		gs.WhiteToMove = true
+	case "b":
+		gs.WhiteToMove = false
+	default:
+		return fail("side to move", "expected \"w\" or \"b\", got %q", fields[1])
+	}
+
+	if fields[2] != "-" {
+		for i := 0; i < len(fields[2]); i++ {
+			var right *bool
+			var king, rook string
+			var row, rookCol int
+			switch fields[2][i] {
+			case 'K':
+				right, king, rook, row, rookCol = &gs.CastleRights.WKS, "wK", "wR", 7, 7
+			case 'Q':
+				right, king, rook, row, rookCol = &gs.CastleRights.WQS, "wK", "wR", 7, 0
+			case 'k':
+				right, king, rook, row, rookCol = &gs.CastleRights.BKS, "bK", "bR", 0, 7
+			case 'q':
+				right, king, rook, row, rookCol = &gs.CastleRights.BQS, "bK", "bR", 0, 0
+			default:
+				return fail("castling", "unknown castling right %q", fields[2][i])
+			}
+			if *right {
+				return fail("castling", "castling right %q repeated", fields[2][i])
+			}
+			if gs.Board[row][4] != king || gs.Board[row][rookCol] != rook {
+				return fail("castling", "castling right %q requires the king and rook on their starting squares", fields[2][i])
+			}
+			*right = true
+		}
+	}
+
+	if fields[3] != "-" {
+		square, err := SquareFromNotation(fields[3])
+		if err != nil {
+			return fail("en passant", "%v", err)
+		}
+		if (gs.WhiteToMove && square.Row != 2) || (!gs.WhiteToMove && square.Row != 5) {
+			return fail("en passant", "%s is not on the rank behind a pawn that just moved two squares", fields[3])
+		}
+		// The pawn that just moved stands in front of the square, and both
+		// the square and the one it came from are empty.
+		pawn, forward := "bp", 1
+		if !gs.WhiteToMove {
+			pawn, forward = "wp", -1
+		}
+		if gs.Board[square.Row+forward][square.Col] != pawn || gs.Board[square.Row][square.Col] != "--" || gs.Board[square.Row-forward][square.Col] != "--" {
+			return fail("en passant", "no %s pawn can just have moved two squares past %s", colorName(pawn[0]), fields[3])
+		}
+		gs.EnPassantSquare = square
+	}
+
+	gs.WhiteToMove = !gs.WhiteToMove
+	inCheck := gs.InCheck()
+	gs.WhiteToMove = !gs.WhiteToMove
+	if inCheck {
+		toMove, other := "white", "black"
+		if !gs.WhiteToMove {
+			toMove, other = other, toMove
+		}
+		return fail("board", "the %s king is in check with %s to move", other, toMove)
+	}
+
+	if len(fields) == 6 {
+		halfmove, err := strconv.Atoi(fields[4])
+		if err != nil || halfmove < 0 {
+			return fail("halfmove clock", "expected a non-negative integer, got %q", fields[4])
+		}
+		fullmove, err := strconv.Atoi(fields[5])
+		if err != nil || fullmove < 1 {
+			return fail("fullmove number", "expected a positive integer, got %q", fields[5])
+		}
+		gs.HalfmoveClock = halfmove
+		gs.FullmoveNumber = fullmove
+	}
+	gs.StartFEN = gs.FEN()
+	gs.Hash = gs.ComputeHash()
+	gs.PositionHistory = []uint64{gs.Hash}
+
+	return gs, nil
+}
+
+// FEN returns the Forsyth-Edwards Notation for the current position.
+func (gs *GameState) FEN() string {
+	var sb strings.Builder
+
+	for r := 0; r < 8; r++ {
+		empty := 0
+		for c := 0; c < 8; c++ {
+			piece := gs.Board[r][c]
+			if piece == "--" {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				sb.WriteByte(byte('0' + empty))
+				empty = 0
+			}
+			sb.WriteByte(pieceToFen[piece])
+		}
+		if empty > 0 {
+			sb.WriteByte(byte('0' + empty))
+		}
+		if r < 7 {
+			sb.WriteByte('/')
+		}
+	}
+
+	if gs.WhiteToMove {
+		sb.WriteString(" w ")
+	} else {
+		sb.WriteString(" b ")
+	}
+
+	castling := ""
+	if gs.CastleRights.WKS {
+		castling += "K"
+	}
+	if gs.CastleRights.WQS {
+		castling += "Q"
+	}
+	if gs.CastleRights.BKS {
+		castling += "k"
+	}
+	if gs.CastleRights.BQS {
+		castling += "q"
+	}
+	if castling == "" {
+		castling = "-"
+	}
+	sb.WriteString(castling)
+
+	if gs.EnPassantSquare == GetNullSquare() {
+		sb.WriteString(" -")
+	} else {
+		sb.WriteString(" " + SquareNotation(gs.EnPassantSquare))
+	}
+
+	sb.WriteString(" " + strconv.Itoa(gs.HalfmoveClock) + " " + strconv.Itoa(gs.FullmoveNumber))
+
+	return sb.String()
+}
+
+func colorName(color byte) string {
+	if color == 'w' {
+		return "white"
+	}
+	return "black"
+}
@@ -0,0 +1,82 @@
+package chess
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFENRoundTrip(t *testing.T) {
+	fens := []string{
+		StartingPositionFEN,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"rnbqkbnr/ppp1pppp/8/3pP3/8/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 3",
+		"rnbqkbnr/pppp1ppp/8/8/3Pp3/8/PPP1PPPP/RNBQKBNR b Kq d3 0 3",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 12 40",
+	}
+	for _, fen := range fens {
+		gs, err := NewGameStateFromFEN(fen)
+		if err != nil {
+			t.Fatalf("%s: %v", fen, err)
+		}
+		if got := gs.FEN(); got != fen {
+			t.Errorf("FEN() = %q, want %q", got, fen)
+		}
+	}
+
+	gs, err := NewGameStateFromFEN("4k3/8/8/8/8/8/8/4K3 b - -")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := gs.FEN(), "4k3/8/8/8/8/8/8/4K3 b - - 0 1"; got != want {
+		t.Errorf("FEN() without clocks = %q, want %q", got, want)
+	}
+	if gs.WhiteKingSquare != (Square{7, 4}) || gs.BlackKingSquare != (Square{0, 4}) {
+		t.Errorf("king squares %v and %v", gs.WhiteKingSquare, gs.BlackKingSquare)
+	}
+}
+
+func TestFENErrors(t *testing.T) {
+	tests := []struct {
+		fen   string
+		field string
+	}{
+		{"", "fields"},
+		{"4k3/8/8/8/8/8/8/4K3 w - - 0", "fields"},
+		{"4k3/8/8/8/8/8/4K3 w - - 0 1", "board"},
+		{"4k3/8/8/8/8/8/8/4K4 w - - 0 1", "board"},
+		{"4k3/8/8/8/8/8/8/4K2 w - - 0 1", "board"},
+		{"4k3/8/8/8/8/8/8/4KX2 w - - 0 1", "board"},
+		{"4k2P/8/8/8/8/8/8/4K3 w - - 0 1", "board"},
+		{"4k3/8/8/8/8/8/8/K3K3 w - - 0 1", "board"},
+		{"8/8/8/8/8/8/8/4K3 w - - 0 1", "board"},
+		{"4k3/8/8/8/8/8/8/8 w - - 0 1", "board"},
+		// The side that is not to move is in check.
+		{"4k2R/8/8/8/8/8/8/4K3 w - - 0 1", "board"},
+		{"4k3/4r3/8/8/8/8/8/4K3 b - - 0 1", "board"},
+		{"4k3/8/8/8/8/8/8/4K3 x - - 0 1", "side to move"},
+		{"4k3/8/8/8/8/8/8/4K3 w X - 0 1", "castling"},
+		{"4k3/8/8/8/8/8/8/4K2R w KK - 0 1", "castling"},
+		{"4k3/8/8/8/8/8/8/4K3 w K - 0 1", "castling"},
+		{"4k3/8/8/8/8/8/8/4K3 w - z9 0 1", "en passant"},
+		{"4k3/8/8/3pP3/8/8/8/4K3 w - d3 0 1", "en passant"},
+		// No pawn in front of the square, or something in the way.
+		{"4k3/8/8/4P3/8/8/8/4K3 w - d6 0 1", "en passant"},
+		{"4k3/8/3n4/3pP3/8/8/8/4K3 w - d6 0 1", "en passant"},
+		{"4k3/3n4/8/3pP3/8/8/8/4K3 w - d6 0 1", "en passant"},
+		{"4k3/8/8/8/3p4/8/8/4K3 b - d3 0 1", "en passant"},
+		{"4k3/8/8/8/8/8/8/4K3 w - - -1 1", "halfmove clock"},
+		{"4k3/8/8/8/8/8/8/4K3 w - - x 1", "halfmove clock"},
+		{"4k3/8/8/8/8/8/8/4K3 w - - 0 0", "fullmove number"},
+	}
+	for _, test := range tests {
+		_, err := NewGameStateFromFEN(test.fen)
+		var fenErr *FENError
+		if !errors.As(err, &fenErr) {
+			t.Errorf("%q: got %v, want a FENError", test.fen, err)
+			continue
+		}
+		if fenErr.Field != test.field {
+			t.Errorf("%q: error in %s (%v), want %s", test.fen, fenErr.Field, err, test.field)
+		}
+	}
+}
@@ -1,7 +1,6 @@
-package main
+package chess
 
 import (
-	"fmt"
 	"math"
 )
 
@@ -11,37 +10,59 @@ type GameState struct {
 	Board                BoardState
 	WhiteToMove          bool
 	MoveLog              []Move
-	SquareSelected       Square
-	PlayerClicks         []Square
 	ValidMoves           []Move
-	MoveMade             bool
-	HiglightedSquares    []Square
 	BlackKingSquare      Square
 	WhiteKingSquare      Square
 	CurrentPlayerInCheck bool
 	Stalemate            bool
-	Checkmate           bool
+	Checkmate            bool
 	Pins                 []AttactedSquare
 	Checks               []AttactedSquare
 	EnPassantSquare      Square
 	CastleRights         CastleRights
-	CastleRightsLog      []CastleRights
+	HalfmoveClock        int
+	FullmoveNumber       int
+	StartFEN             string
+	Hash                 uint64
+	PositionHistory      []uint64
+	UndoLog              []UndoRecord
+	RedoStack            []Move
+	// Tablebase, if set, adjudicates positions it knows in Outcome.
+	Tablebase Tablebase
+}
+
+// UndoRecord holds everything MakeMove changes apart from the board and the
+// side to move, as it was before the move was made.
+type UndoRecord struct {
+	EnPassantSquare      Square
+	CastleRights         CastleRights
+	HalfmoveClock        int
+	FullmoveNumber       int
+	Hash                 uint64
+	WhiteKingSquare      Square
+	BlackKingSquare      Square
+	CurrentPlayerInCheck bool
+	Checkmate            bool
+	Stalemate            bool
+	Pins                 []AttactedSquare
+	Checks               []AttactedSquare
+	ValidMoves           []Move
 }
 
 type PieceDelta struct {
-	row int
-	col int
+	Row int
+	Col int
 }
 
 type AttactedSquare struct {
-	row       int
-	col       int
-	direction PieceDelta
+	Row       int
+	Col       int
+	Direction PieceDelta
 }
 
 func NewGameState() *GameState {
 
-	return &GameState{
+	gs := &GameState{
 		Board: BoardState{
 			{"bR", "bN", "bB", "bQ", "bK", "bB", "bN", "bR"},
 			{"bp", "bp", "bp", "bp", "bp", "bp", "bp", "bp"},
@@ -53,25 +74,48 @@ func NewGameState() *GameState {
 			{"wR", "wN", "wB", "wQ", "wK", "wB", "wN", "wR"},
 		},
 		WhiteToMove:     true,
-		MoveMade:        false,
-		SquareSelected:  GetNullSquare(),
 		EnPassantSquare: GetNullSquare(),
 		CastleRights:    CastleRights{true, true, true, true},
-		CastleRightsLog: []CastleRights{{true, true, true, true}},
 		WhiteKingSquare: Square{7, 4},
 		BlackKingSquare: Square{0, 4},
-	}
+		HalfmoveClock:   0,
+		FullmoveNumber:  1,
+		StartFEN:        StartingPositionFEN,
+		MoveLog:         []Move{},
+		UndoLog:         []UndoRecord{},
+	}
+	gs.Hash = gs.ComputeHash()
+	gs.PositionHistory = []uint64{gs.Hash}
+	return gs
 }
 
 func GetNullSquare() Square {
 	return Square{-1, -1}
 }
 
+// Clone returns a copy of the game state that shares no memory with it.
+func (gs *GameState) Clone() *GameState {
+	clone := *gs
+	clone.MoveLog = append([]Move(nil), gs.MoveLog...)
+	clone.ValidMoves = append([]Move(nil), gs.ValidMoves...)
+	clone.Pins = append([]AttactedSquare(nil), gs.Pins...)
+	clone.Checks = append([]AttactedSquare(nil), gs.Checks...)
+	clone.PositionHistory = append([]uint64(nil), gs.PositionHistory...)
+	clone.UndoLog = append([]UndoRecord(nil), gs.UndoLog...)
+	clone.RedoStack = append([]Move(nil), gs.RedoStack...)
+	return &clone
+}
+
 func (gs *GameState) MakeMove(move Move) {
 
+	gs.UndoLog = append(gs.UndoLog, gs.undoRecord())
+
+	gs.Hash ^= gs.stateKey()
+
 	gs.Board[move.StartRow][move.StartCol] = "--"
 	gs.Board[move.EndRow][move.EndCol] = move.PieceMoved
 	gs.MoveLog = append(gs.MoveLog, move)
+	gs.ValidMoves = nil
 
 	if move.PieceMoved == "wK" {
 		gs.WhiteKingSquare = Square{move.EndRow, move.EndCol}
@@ -79,9 +123,8 @@ func (gs *GameState) MakeMove(move Move) {
 		gs.BlackKingSquare = Square{move.EndRow, move.EndCol}
 	}
 
-	//TODO: ADD option select piece to promote to
 	if move.IsPawnPromotion {
-		gs.Board[move.EndRow][move.EndCol] = move.PieceMoved[:1] + "Q"
+		gs.Board[move.EndRow][move.EndCol] = move.PromotionPiece
 	}
 
 	if move.IsEnPassant {
@@ -93,107 +136,199 @@ func (gs *GameState) MakeMove(move Move) {
 	} else {
 		gs.EnPassantSquare = GetNullSquare()
 	}
-	
+
 	gs.UpdateCastleRights(move)
 
+	if move.PieceMoved[1] == 'p' || move.PieceCaptured != "--" {
+		gs.HalfmoveClock = 0
+	} else {
+		gs.HalfmoveClock++
+	}
+
+	if !gs.WhiteToMove {
+		gs.FullmoveNumber++
+	}
+
 	if move.IsCastleMove {
-		if move.EndCol - move.StartCol == 2 { //kingside castle
-			gs.Board[move.EndRow][move.EndCol - 1] = gs.Board[move.EndRow][move.EndCol + 1]
-			gs.Board[move.EndRow][move.EndCol + 1] = "--"
+		if move.EndCol-move.StartCol == 2 { //kingside castle
+			gs.Board[move.EndRow][move.EndCol-1] = gs.Board[move.EndRow][move.EndCol+1]
+			gs.Board[move.EndRow][move.EndCol+1] = "--"
 		} else { //queenside castle
-			gs.Board[move.EndRow][move.EndCol + 1] = gs.Board[move.EndRow][move.EndCol - 2]
-			gs.Board[move.EndRow][move.EndCol - 2] = "--"
+			gs.Board[move.EndRow][move.EndCol+1] = gs.Board[move.EndRow][move.EndCol-2]
+			gs.Board[move.EndRow][move.EndCol-2] = "--"
 		}
 	}
 
 	gs.WhiteToMove = !gs.WhiteToMove
-
+	gs.Hash ^= moveKey(move) ^ polyglotRandom[polyglotTurnOffset] ^ gs.stateKey()
+	gs.PositionHistory = append(gs.PositionHistory, gs.Hash)
 }
 
 func (gs *GameState) UpdateCastleRights(move Move) {
 	if move.PieceMoved == "wK" {
-		gs.CastleRights.wks = false
-		gs.CastleRights.wqs = false
+		gs.CastleRights.WKS = false
+		gs.CastleRights.WQS = false
 	} else if move.PieceMoved == "bK" {
-		gs.CastleRights.bks = false
-		gs.CastleRights.bqs = false
+		gs.CastleRights.BKS = false
+		gs.CastleRights.BQS = false
 	} else if move.PieceMoved == "wR" {
 		if move.StartRow == 7 {
 			if move.StartCol == 0 {
-				gs.CastleRights.wqs = false
+				gs.CastleRights.WQS = false
 			} else if move.StartCol == 7 {
-				gs.CastleRights.wks = false
+				gs.CastleRights.WKS = false
 			}
 		}
 	} else if move.PieceMoved == "bR" {
 		if move.StartRow == 0 {
 			if move.StartCol == 0 {
-				gs.CastleRights.bqs = false
+				gs.CastleRights.BQS = false
 			} else if move.StartCol == 7 {
-				gs.CastleRights.bks = false
+				gs.CastleRights.BKS = false
 			}
 		}
 	}
 
-	gs.CastleRightsLog = append(
-		gs.CastleRightsLog,
-		CastleRights{gs.CastleRights.wks, gs.CastleRights.wqs, gs.CastleRights.bks, gs.CastleRights.bqs},
-	)
+	// a rook captured on its starting square can no longer castle
+	if move.PieceCaptured == "wR" && move.EndRow == 7 {
+		if move.EndCol == 0 {
+			gs.CastleRights.WQS = false
+		} else if move.EndCol == 7 {
+			gs.CastleRights.WKS = false
+		}
+	} else if move.PieceCaptured == "bR" && move.EndRow == 0 {
+		if move.EndCol == 0 {
+			gs.CastleRights.BQS = false
+		} else if move.EndCol == 7 {
+			gs.CastleRights.BKS = false
+		}
+	}
 }
 
+// UndoMove takes back the last move, returning the game state to exactly what
+// it was before MakeMove. It does not touch RedoStack; see TakeBackMove.
 func (gs *GameState) UndoMove() {
 
-	fmt.Println("undo move")
-
 	if len(gs.MoveLog) == 0 {
 		return
 	}
 	move := gs.MoveLog[len(gs.MoveLog)-1]
+	record := gs.UndoLog[len(gs.UndoLog)-1]
 
 	gs.Board[move.StartRow][move.StartCol] = move.PieceMoved
 	gs.Board[move.EndRow][move.EndCol] = move.PieceCaptured
 
-	if move.PieceMoved == "wK" {
-		gs.WhiteKingSquare = Square{move.StartRow, move.StartCol}
-	} else if move.PieceMoved == "bK" {
-		gs.BlackKingSquare = Square{move.StartRow, move.StartCol}
+	if move.IsEnPassant {
+		gs.Board[move.StartRow][move.EndCol] = opponentPawn(move.PieceMoved)
 	}
 
-	if move.IsEnPassant {
+	if move.IsCastleMove {
+		if move.EndCol-move.StartCol == 2 { //undo kingside castle
+			gs.Board[move.EndRow][move.EndCol+1] = gs.Board[move.EndRow][move.EndCol-1]
+			gs.Board[move.EndRow][move.EndCol-1] = "--"
+		} else { //undo queenside castle
+			gs.Board[move.EndRow][move.EndCol-2] = gs.Board[move.EndRow][move.EndCol+1]
+			gs.Board[move.EndRow][move.EndCol+1] = "--"
+		}
+	}
 
-		gs.Board[move.StartRow][move.EndCol] = move.PieceCaptured
-		gs.Board[move.EndRow][move.EndCol] = "--"
+	gs.restore(record)
 
-		if gs.WhiteToMove {
-			gs.Board[move.EndRow-1][move.EndCol] = "wp"
-		} else {
-			gs.Board[move.EndRow+1][move.EndCol] = "bp"
-		}
+	gs.MoveLog = gs.MoveLog[:len(gs.MoveLog)-1]
+	gs.UndoLog = gs.UndoLog[:len(gs.UndoLog)-1]
+	gs.PositionHistory = gs.PositionHistory[:len(gs.PositionHistory)-1]
 
-		gs.EnPassantSquare = Square{move.EndRow, move.EndCol}
+	gs.WhiteToMove = !gs.WhiteToMove
+}
 
+func (gs *GameState) undoRecord() UndoRecord {
+	return UndoRecord{
+		EnPassantSquare:      gs.EnPassantSquare,
+		CastleRights:         gs.CastleRights,
+		HalfmoveClock:        gs.HalfmoveClock,
+		FullmoveNumber:       gs.FullmoveNumber,
+		Hash:                 gs.Hash,
+		WhiteKingSquare:      gs.WhiteKingSquare,
+		BlackKingSquare:      gs.BlackKingSquare,
+		CurrentPlayerInCheck: gs.CurrentPlayerInCheck,
+		Checkmate:            gs.Checkmate,
+		Stalemate:            gs.Stalemate,
+		Pins:                 gs.Pins,
+		Checks:               gs.Checks,
+		ValidMoves:           gs.ValidMoves,
 	}
+}
 
-	if move.PieceMoved[1] == 'p' && math.Abs(float64(move.StartRow-move.EndRow)) == 2 {
-		gs.EnPassantSquare = GetNullSquare()
-	}
+func (gs *GameState) restore(record UndoRecord) {
+	gs.EnPassantSquare = record.EnPassantSquare
+	gs.CastleRights = record.CastleRights
+	gs.HalfmoveClock = record.HalfmoveClock
+	gs.FullmoveNumber = record.FullmoveNumber
+	gs.Hash = record.Hash
+	gs.WhiteKingSquare = record.WhiteKingSquare
+	gs.BlackKingSquare = record.BlackKingSquare
+	gs.CurrentPlayerInCheck = record.CurrentPlayerInCheck
+	gs.Checkmate = record.Checkmate
+	gs.Stalemate = record.Stalemate
+	gs.Pins = record.Pins
+	gs.Checks = record.Checks
+	gs.ValidMoves = record.ValidMoves
+}
 
-	gs.MoveLog = gs.MoveLog[:len(gs.MoveLog)-1]
+// MakeNullMove passes the turn to the other player without moving a piece.
+// It is not a legal move: a search uses it to find out whether a position is
+// so good that it stays good even if the opponent moves twice. It is not
+// added to MoveLog or PositionHistory, and must be taken back with
+// UndoNullMove before any earlier move is undone. The side to move must not
+// be in check.
+func (gs *GameState) MakeNullMove() {
+	gs.UndoLog = append(gs.UndoLog, gs.undoRecord())
+	gs.Hash ^= gs.stateKey()
+	gs.EnPassantSquare = GetNullSquare()
+	gs.ValidMoves = nil
+	gs.WhiteToMove = !gs.WhiteToMove
+	gs.Hash ^= polyglotRandom[polyglotTurnOffset] ^ gs.stateKey()
+}
 
-	gs.CastleRightsLog = gs.CastleRightsLog[:len(gs.CastleRightsLog)-1]
-	gs.CastleRights = gs.CastleRightsLog[len(gs.CastleRightsLog)-1]
+// UndoNullMove takes back MakeNullMove.
+func (gs *GameState) UndoNullMove() {
+	gs.restore(gs.UndoLog[len(gs.UndoLog)-1])
+	gs.UndoLog = gs.UndoLog[:len(gs.UndoLog)-1]
+	gs.WhiteToMove = !gs.WhiteToMove
+}
 
-	if move.IsCastleMove {
-		if move.EndCol - move.StartCol == 2 { //undo kingside castle
-			gs.Board[move.EndRow][move.EndCol + 1] = gs.Board[move.EndRow][move.EndCol - 1]
-			gs.Board[move.EndRow][move.EndCol - 1] = "--"
-		} else { //undo queenside castle
-			gs.Board[move.EndRow][move.EndCol - 2] = gs.Board[move.EndRow][move.EndCol + 1]
-			gs.Board[move.EndRow][move.EndCol + 1] = "--"
-		}
+// PlayMove makes a move chosen by a player. If it is the move TakeBackMove
+// last took back, the rest of RedoStack is kept; any other move clears it.
+func (gs *GameState) PlayMove(move Move) {
+	if n := len(gs.RedoStack); n > 0 && gs.RedoStack[n-1].MoveId == move.MoveId {
+		gs.RedoStack = gs.RedoStack[:n-1]
+	} else {
+		gs.RedoStack = nil
 	}
+	gs.MakeMove(move)
+}
 
-	gs.WhiteToMove = !gs.WhiteToMove
+// TakeBackMove undoes the last move and keeps it on RedoStack so RedoMove can
+// play it again. It returns false if there is no move to take back.
+func (gs *GameState) TakeBackMove() bool {
+	if len(gs.MoveLog) == 0 {
+		return false
+	}
+	gs.RedoStack = append(gs.RedoStack, gs.MoveLog[len(gs.MoveLog)-1])
+	gs.UndoMove()
+	return true
+}
+
+// RedoMove replays the last move taken back by TakeBackMove. It returns false
+// if there is nothing to redo.
+func (gs *GameState) RedoMove() bool {
+	if len(gs.RedoStack) == 0 {
+		return false
+	}
+	move := gs.RedoStack[len(gs.RedoStack)-1]
+	gs.RedoStack = gs.RedoStack[:len(gs.RedoStack)-1]
+	gs.MakeMove(move)
+	return true
 }
 
 func (gs *GameState) GetValidMoves() []Move {
@@ -205,11 +340,11 @@ func (gs *GameState) GetValidMoves() []Move {
 	var kingRow, kingCol int
 
 	if gs.WhiteToMove {
-		kingRow = gs.WhiteKingSquare.row
-		kingCol = gs.WhiteKingSquare.col
+		kingRow = gs.WhiteKingSquare.Row
+		kingCol = gs.WhiteKingSquare.Col
 	} else {
-		kingRow = gs.BlackKingSquare.row
-		kingCol = gs.BlackKingSquare.col
+		kingRow = gs.BlackKingSquare.Row
+		kingCol = gs.BlackKingSquare.Col
 	}
 
 	if gs.CurrentPlayerInCheck {
@@ -217,16 +352,16 @@ func (gs *GameState) GetValidMoves() []Move {
 			moves = gs.GetAllPossibleMoves()
 			// to block a check you must move a piece into one of the squares between the enemy piece and the king
 			check := gs.Checks[0]
-			checkRow := check.row
-			checkCol := check.col
+			checkRow := check.Row
+			checkCol := check.Col
 			pieceAttacking := gs.Board[checkRow][checkCol]
 			validSquares := []Square{}
 			if pieceAttacking[1] == 'N' { // if knight, must capture knight or move king
 				validSquares = append(validSquares, Square{checkRow, checkCol})
 			} else { // if rook, bishop, or queen, you can block the check by moving a piece in between the king and the enemy piece
 				for i := 1; i < 8; i++ {
-					endRow := kingRow + check.direction.row*i
-					endCol := kingCol + check.direction.col*i
+					endRow := kingRow + check.Direction.Row*i
+					endCol := kingCol + check.Direction.Col*i
 					validSquares = append(validSquares, Square{endRow, endCol})
 					if endRow == checkRow && endCol == checkCol {
 						break
@@ -234,10 +369,10 @@ func (gs *GameState) GetValidMoves() []Move {
 				}
 			}
 			for i := len(moves) - 1; i >= 0; i-- { // remove moves that don't block check or move king
-				if moves[i].PieceMoved[1] != 'K' {
+				if moves[i].PieceMoved[1] != 'K' && !moves[i].IsEnPassant { // en passant moves are already checked by addEnPassantMove
 					moveSquareInValidSquares := false
 					for _, validSquare := range validSquares {
-						if moves[i].EndRow == validSquare.row && moves[i].EndCol == validSquare.col {
+						if moves[i].EndRow == validSquare.Row && moves[i].EndCol == validSquare.Col {
 							moveSquareInValidSquares = true
 							break
 						}
@@ -255,9 +390,9 @@ func (gs *GameState) GetValidMoves() []Move {
 	}
 
 	if gs.WhiteToMove {
-		moves = append(moves, gs.GetCastleMoves(gs.WhiteKingSquare.row, gs.WhiteKingSquare.col, 'w')...)
+		moves = append(moves, gs.GetCastleMoves(gs.WhiteKingSquare.Row, gs.WhiteKingSquare.Col, 'w')...)
 	} else {
-		moves = append(moves, gs.GetCastleMoves(gs.BlackKingSquare.row, gs.BlackKingSquare.col, 'b')...)
+		moves = append(moves, gs.GetCastleMoves(gs.BlackKingSquare.Row, gs.BlackKingSquare.Col, 'b')...)
 	}
 
 	if len(moves) == 0 {
@@ -285,28 +420,28 @@ func (gs *GameState) CheckForPinsAndChecks() (bool, []AttactedSquare, []Attacted
 	if gs.WhiteToMove {
 		enemyColor = 'b'
 		allyColor = 'w'
-		startRow = gs.WhiteKingSquare.row
-		startCol = gs.WhiteKingSquare.col
+		startRow = gs.WhiteKingSquare.Row
+		startCol = gs.WhiteKingSquare.Col
 	} else {
 		enemyColor = 'w'
 		allyColor = 'b'
-		startRow = gs.BlackKingSquare.row
-		startCol = gs.BlackKingSquare.col
+		startRow = gs.BlackKingSquare.Row
+		startCol = gs.BlackKingSquare.Col
 	}
 
 	// directions 0 to 3 are orthogonal, 4 to 7 are diagonal
-	directions := []PieceDelta{{-1, 0}, {0, -1}, {1, 0}, {0, 1}, {-1, 1}, {1, -1}, {1, 1}}
+	directions := []PieceDelta{{-1, 0}, {0, -1}, {1, 0}, {0, 1}, {-1, -1}, {-1, 1}, {1, -1}, {1, 1}}
 
 	for j := 0; j < len(directions); j++ {
 		d := directions[j]
 		possiblePin := AttactedSquare{-1, -1, PieceDelta{}}
 		for i := 1; i < 8; i++ {
-			endRow := startRow + d.row*i
-			endCol := startCol + d.col*i
+			endRow := startRow + d.Row*i
+			endCol := startCol + d.Col*i
 			if 0 <= endRow && endRow < 8 && 0 <= endCol && endCol < 8 {
 				endPiece := gs.Board[endRow][endCol]
 				if endPiece[0] == allyColor && endPiece[1] != 'K' {
-					if possiblePin.row == -1 {
+					if possiblePin.Row == -1 {
 						possiblePin = AttactedSquare{endRow, endCol, d}
 					} else {
 						break
@@ -317,7 +452,7 @@ func (gs *GameState) CheckForPinsAndChecks() (bool, []AttactedSquare, []Attacted
 					if pieceType == 'R' && 0 <= j && j <= 3 || pieceType == 'B' && 4 <= j && j <= 7 ||
 						i == 1 && pieceType == 'p' && ((enemyColor == 'w' && 6 <= j && j <= 7) || (enemyColor == 'b' && 4 <= j && j <= 5)) ||
 						pieceType == 'Q' || (i == 1 && pieceType == 'K') {
-						if possiblePin.row == -1 {
+						if possiblePin.Row == -1 {
 							inCheck = true
 							checks = append(checks, AttactedSquare{endRow, endCol, d})
 							break
@@ -338,8 +473,8 @@ func (gs *GameState) CheckForPinsAndChecks() (bool, []AttactedSquare, []Attacted
 	// knight checks
 	knightMoves := []PieceDelta{{-2, -1}, {-2, 1}, {-1, -2}, {-1, 2}, {1, -2}, {1, 2}, {2, -1}, {2, 1}}
 	for _, m := range knightMoves {
-		endRow := startRow + m.row
-		endCol := startCol + m.col
+		endRow := startRow + m.Row
+		endCol := startCol + m.Col
 		if 0 <= endRow && endRow < 8 && 0 <= endCol && endCol < 8 {
 			endPiece := gs.Board[endRow][endCol]
 			if endPiece[0] == enemyColor && endPiece[1] == 'N' {
@@ -362,24 +497,6 @@ func (gs *GameState) IsValidMove(move Move) bool {
 	return false
 }
 
-func (gs *GameState) SquareAlreadyHighlighted(square Square) bool {
-	for _, currentSquare := range gs.HiglightedSquares {
-		if square == currentSquare {
-			return true
-		}
-	}
-	return false
-}
-
-func (gs *GameState) RemoveSquareFromSlice(squares []Square, square Square) []Square {
-	for i, currentSquare := range squares {
-		if currentSquare == square {
-			return append(squares[:i], squares[i+1:]...)
-		}
-	}
-	return squares
-}
-
 func (gs *GameState) GetAllPossibleMoves() []Move {
 	moves := []Move{}
 	for r := 0; r < len(gs.Board); r++ {
@@ -413,9 +530,9 @@ func (gs *GameState) GetPawnMoves(r int, c int) []Move {
 	pinDirection := PieceDelta{}
 
 	for i := len(gs.Pins) - 1; i >= 0; i-- {
-		if gs.Pins[i].row == r && gs.Pins[i].col == c {
+		if gs.Pins[i].Row == r && gs.Pins[i].Col == c {
 			piecePinned = true
-			pinDirection = gs.Pins[i].direction
+			pinDirection = gs.Pins[i].Direction
 			gs.Pins = append(gs.Pins[:i], gs.Pins[i+1:]...)
 			break
 		}
@@ -423,55 +540,88 @@ func (gs *GameState) GetPawnMoves(r int, c int) []Move {
 
 	if gs.WhiteToMove {
 		if r-1 >= 0 && gs.Board[r-1][c] == "--" { //move one square
-			if !piecePinned || pinDirection == (PieceDelta{-1, 0}) {
-				moves = append(moves, NewMove(Square{r, c}, Square{r - 1, c}, gs.Board, false, false))
+			if !piecePinned || pinDirection == (PieceDelta{-1, 0}) || pinDirection == (PieceDelta{1, 0}) {
+				moves = addPawnMoves(moves, Square{r, c}, Square{r - 1, c}, gs.Board)
 				if r == 6 && gs.Board[r-2][c] == "--" { //move two squares
-					moves = append(moves, NewMove(Square{r, c}, Square{r - 2, c}, gs.Board, false, false))
+					moves = addPawnMoves(moves, Square{r, c}, Square{r - 2, c}, gs.Board)
 				}
 			}
 		}
 		if r-1 >= 0 && c-1 >= 0 && gs.Board[r-1][c-1][0] == 'b' { //capture to the left
 			if !piecePinned || pinDirection == (PieceDelta{-1, -1}) {
-				moves = append(moves, NewMove(Square{r, c}, Square{r - 1, c - 1}, gs.Board, false, false))
+				moves = addPawnMoves(moves, Square{r, c}, Square{r - 1, c - 1}, gs.Board)
 			}
-		} else if r-1 == gs.EnPassantSquare.row && c-1 == gs.EnPassantSquare.col { //en passant capture to the left
-			moves = append(moves, NewMove(Square{r, c}, Square{r - 1, c - 1}, gs.Board, true, false))
+		} else if r-1 == gs.EnPassantSquare.Row && c-1 == gs.EnPassantSquare.Col { //en passant capture to the left
+			moves = gs.addEnPassantMove(moves, Square{r, c}, Square{r - 1, c - 1})
 		}
 		if r-1 >= 0 && c+1 < 8 && gs.Board[r-1][c+1][0] == 'b' { //capture to the right
 			if !piecePinned || pinDirection == (PieceDelta{-1, 1}) {
-				moves = append(moves, NewMove(Square{r, c}, Square{r - 1, c + 1}, gs.Board, false, false))
+				moves = addPawnMoves(moves, Square{r, c}, Square{r - 1, c + 1}, gs.Board)
 			}
-		} else if r-1 == gs.EnPassantSquare.row && c+1 == gs.EnPassantSquare.col { //en passant capture to the right
-			fmt.Println("en passant capture to the right")
-			moves = append(moves, NewMove(Square{r, c}, Square{r - 1, c + 1}, gs.Board, true, false))
+		} else if r-1 == gs.EnPassantSquare.Row && c+1 == gs.EnPassantSquare.Col { //en passant capture to the right
+			moves = gs.addEnPassantMove(moves, Square{r, c}, Square{r - 1, c + 1})
 		}
 	} else {
 		if r+1 < 8 && gs.Board[r+1][c] == "--" { //move one square
-			if !piecePinned || pinDirection == (PieceDelta{1, 0}) {
-				moves = append(moves, NewMove(Square{r, c}, Square{r + 1, c}, gs.Board, false, false))
+			if !piecePinned || pinDirection == (PieceDelta{1, 0}) || pinDirection == (PieceDelta{-1, 0}) {
+				moves = addPawnMoves(moves, Square{r, c}, Square{r + 1, c}, gs.Board)
 				if r == 1 && gs.Board[r+2][c] == "--" { //move two squares
-					moves = append(moves, NewMove(Square{r, c}, Square{r + 2, c}, gs.Board, false, false))
+					moves = addPawnMoves(moves, Square{r, c}, Square{r + 2, c}, gs.Board)
 				}
 			}
 		}
 		if r+1 < 8 && c-1 >= 0 && gs.Board[r+1][c-1][0] == 'w' { //capture to the left
 			if !piecePinned || pinDirection == (PieceDelta{1, -1}) {
-				moves = append(moves, NewMove(Square{r, c}, Square{r + 1, c - 1}, gs.Board, false, false))
+				moves = addPawnMoves(moves, Square{r, c}, Square{r + 1, c - 1}, gs.Board)
 			}
-		} else if r+1 == gs.EnPassantSquare.row && c-1 == gs.EnPassantSquare.col { //en passant capture to the left
-			moves = append(moves, NewMove(Square{r, c}, Square{r + 1, c - 1}, gs.Board, true, false))
+		} else if r+1 == gs.EnPassantSquare.Row && c-1 == gs.EnPassantSquare.Col { //en passant capture to the left
+			moves = gs.addEnPassantMove(moves, Square{r, c}, Square{r + 1, c - 1})
 		}
 		if r+1 < 8 && c+1 < 8 && gs.Board[r+1][c+1][0] == 'w' { //capture to the right
 			if !piecePinned || pinDirection == (PieceDelta{1, 1}) {
-				moves = append(moves, NewMove(Square{r, c}, Square{r + 1, c + 1}, gs.Board, false, false))
+				moves = addPawnMoves(moves, Square{r, c}, Square{r + 1, c + 1}, gs.Board)
 			}
-		} else if r+1 == gs.EnPassantSquare.row && c+1 == gs.EnPassantSquare.col { //en passant capture to the right
-			moves = append(moves, NewMove(Square{r, c}, Square{r + 1, c + 1}, gs.Board, true, false))
+		} else if r+1 == gs.EnPassantSquare.Row && c+1 == gs.EnPassantSquare.Col { //en passant capture to the right
+			moves = gs.addEnPassantMove(moves, Square{r, c}, Square{r + 1, c + 1})
 		}
 	}
 	return moves
 }
 
+// addEnPassantMove appends an en passant capture if it does not leave the
+// king in check. Both pawns leave the rank at once, so a pin along the rank is
+// not found by CheckForPinsAndChecks; instead the capture is tried on the board.
+func (gs *GameState) addEnPassantMove(moves []Move, startSquare Square, endSquare Square) []Move {
+	move := NewMove(startSquare, endSquare, gs.Board, true, false)
+	capturedPawn := gs.Board[startSquare.Row][endSquare.Col]
+
+	gs.Board[startSquare.Row][startSquare.Col] = "--"
+	gs.Board[startSquare.Row][endSquare.Col] = "--"
+	gs.Board[endSquare.Row][endSquare.Col] = move.PieceMoved
+	inCheck, _, _ := gs.CheckForPinsAndChecks()
+	gs.Board[endSquare.Row][endSquare.Col] = "--"
+	gs.Board[startSquare.Row][endSquare.Col] = capturedPawn
+	gs.Board[startSquare.Row][startSquare.Col] = move.PieceMoved
+
+	if !inCheck {
+		moves = append(moves, move)
+	}
+	return moves
+}
+
+// addPawnMoves appends a pawn move, or one move for each promotion piece when
+// the pawn reaches the last rank.
+func addPawnMoves(moves []Move, startSquare Square, endSquare Square, boardState BoardState) []Move {
+	move := NewMove(startSquare, endSquare, boardState, false, false)
+	if !move.IsPawnPromotion {
+		return append(moves, move)
+	}
+	for i := 0; i < len(PromotionPieceTypes); i++ {
+		moves = append(moves, NewPromotionMove(startSquare, endSquare, boardState, PromotionPieceTypes[i]))
+	}
+	return moves
+}
+
 func (gs *GameState) GetRookMoves(r int, c int) []Move {
 	moves := []Move{}
 	directions := []PieceDelta{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
@@ -479,9 +629,9 @@ func (gs *GameState) GetRookMoves(r int, c int) []Move {
 	piecePinned := false
 	pinDirection := PieceDelta{}
 	for i := len(gs.Pins) - 1; i >= 0; i-- {
-		if gs.Pins[i].row == r && gs.Pins[i].col == c {
+		if gs.Pins[i].Row == r && gs.Pins[i].Col == c {
 			piecePinned = true
-			pinDirection = gs.Pins[i].direction
+			pinDirection = gs.Pins[i].Direction
 			if gs.Board[r][c][1] != 'Q' {
 				gs.Pins = append(gs.Pins[:i], gs.Pins[i+1:]...)
 			}
@@ -491,12 +641,12 @@ func (gs *GameState) GetRookMoves(r int, c int) []Move {
 
 	for _, direction := range directions {
 		for i := 1; i < 8; i++ {
-			endRow := r + direction.row*i
-			endCol := c + direction.col*i
+			endRow := r + direction.Row*i
+			endCol := c + direction.Col*i
 			if endRow < 0 || endRow >= 8 || endCol < 0 || endCol >= 8 {
 				break
 			}
-			if !piecePinned || pinDirection == direction || pinDirection == (PieceDelta{-direction.row, -direction.col}) {
+			if !piecePinned || pinDirection == direction || pinDirection == (PieceDelta{-direction.Row, -direction.Col}) {
 				if gs.Board[endRow][endCol] == "--" {
 					moves = append(moves, NewMove(Square{r, c}, Square{endRow, endCol}, gs.Board, false, false))
 				} else {
@@ -518,7 +668,7 @@ func (gs *GameState) GetKnightMoves(r int, c int) []Move {
 	piecePinned := false
 
 	for i := len(gs.Pins) - 1; i >= 0; i-- {
-		if gs.Pins[i].row == r && gs.Pins[i].col == c {
+		if gs.Pins[i].Row == r && gs.Pins[i].Col == c {
 			piecePinned = true
 			gs.Pins = append(gs.Pins[:i], gs.Pins[i+1:]...)
 			break
@@ -526,8 +676,8 @@ func (gs *GameState) GetKnightMoves(r int, c int) []Move {
 	}
 
 	for _, direction := range directions {
-		endRow := r + direction.row
-		endCol := c + direction.col
+		endRow := r + direction.Row
+		endCol := c + direction.Col
 		if endRow >= 0 && endRow < 8 && endCol >= 0 && endCol < 8 {
 			if !piecePinned {
 				if gs.Board[endRow][endCol] == "--" || gs.Board[endRow][endCol][0] != gs.Board[r][c][0] { //empty square or enemy piece
@@ -548,9 +698,9 @@ func (gs *GameState) GetBishopMoves(r int, c int) []Move {
 	pinDirection := PieceDelta{}
 
 	for i := len(gs.Pins) - 1; i >= 0; i-- {
-		if gs.Pins[i].row == r && gs.Pins[i].col == c {
+		if gs.Pins[i].Row == r && gs.Pins[i].Col == c {
 			piecePinned = true
-			pinDirection = gs.Pins[i].direction
+			pinDirection = gs.Pins[i].Direction
 			if gs.Board[r][c][1] != 'Q' {
 				gs.Pins = append(gs.Pins[:i], gs.Pins[i+1:]...)
 			}
@@ -560,12 +710,12 @@ func (gs *GameState) GetBishopMoves(r int, c int) []Move {
 
 	for _, direction := range directions {
 		for i := 1; i < 8; i++ {
-			endRow := r + direction.row*i
-			endCol := c + direction.col*i
+			endRow := r + direction.Row*i
+			endCol := c + direction.Col*i
 			if endRow < 0 || endRow >= 8 || endCol < 0 || endCol >= 8 {
 				break
 			}
-			if !piecePinned || pinDirection == direction || pinDirection == (PieceDelta{-direction.row, -direction.col}) {
+			if !piecePinned || pinDirection == direction || pinDirection == (PieceDelta{-direction.Row, -direction.Col}) {
 				if gs.Board[endRow][endCol] == "--" {
 					moves = append(moves, NewMove(Square{r, c}, Square{endRow, endCol}, gs.Board, false, false))
 				} else {
@@ -640,11 +790,11 @@ func (gs *GameState) GetCastleMoves(r int, c int, allyColor byte) []Move {
 		return moves
 	}
 
-	if (gs.WhiteToMove && gs.CastleRights.wks) || (!gs.WhiteToMove && gs.CastleRights.bks) {
+	if (gs.WhiteToMove && gs.CastleRights.WKS) || (!gs.WhiteToMove && gs.CastleRights.BKS) {
 		moves = append(moves, gs.GetKingsideCastleMoves(r, c, allyColor)...)
 	}
 
-	if (gs.WhiteToMove && gs.CastleRights.wqs) || (!gs.WhiteToMove && gs.CastleRights.bqs) {
+	if (gs.WhiteToMove && gs.CastleRights.WQS) || (!gs.WhiteToMove && gs.CastleRights.BQS) {
 		moves = append(moves, gs.GetQueensideCastleMoves(r, c, allyColor)...)
 	}
 
@@ -654,12 +804,9 @@ func (gs *GameState) GetCastleMoves(r int, c int, allyColor byte) []Move {
 func (gs *GameState) GetKingsideCastleMoves(r int, c int, allyColor byte) []Move {
 	moves := []Move{}
 
-	fmt.Printf("%v, %v, %v", r, c, allyColor)
-	fmt.Printf("%v", gs.CastleRights)
-
 	if gs.Board[r][c+1] == "--" && gs.Board[r][c+2] == "--" {
 		if !gs.SquareAttacked(r, c+1) && !gs.SquareAttacked(r, c+2) {
-			moves = append(moves, NewMove(Square{r, c}, Square{r, c+2}, gs.Board, false, true))
+			moves = append(moves, NewMove(Square{r, c}, Square{r, c + 2}, gs.Board, false, true))
 		}
 	}
 
@@ -669,9 +816,9 @@ func (gs *GameState) GetKingsideCastleMoves(r int, c int, allyColor byte) []Move
 func (gs *GameState) GetQueensideCastleMoves(r int, c int, allyColor byte) []Move {
 	moves := []Move{}
 
-	if gs.Board[r][c-1] == "--" && gs.Board[r][c-2] == "--" && gs.Board[r][c - 3] == "--" {
+	if gs.Board[r][c-1] == "--" && gs.Board[r][c-2] == "--" && gs.Board[r][c-3] == "--" {
 		if !gs.SquareAttacked(r, c-1) && !gs.SquareAttacked(r, c-2) {
-			moves = append(moves, NewMove(Square{r, c}, Square{r, c-2}, gs.Board, false, true))
+			moves = append(moves, NewMove(Square{r, c}, Square{r, c - 2}, gs.Board, false, true))
 		}
 	}
 
@@ -680,20 +827,59 @@ func (gs *GameState) GetQueensideCastleMoves(r int, c int, allyColor byte) []Mov
 
 func (gs *GameState) InCheck() bool {
 	if gs.WhiteToMove {
-		return gs.SquareAttacked(gs.WhiteKingSquare.row, gs.WhiteKingSquare.col)
+		return gs.SquareAttacked(gs.WhiteKingSquare.Row, gs.WhiteKingSquare.Col)
 	} else {
-		return gs.SquareAttacked(gs.BlackKingSquare.row, gs.BlackKingSquare.col)
+		return gs.SquareAttacked(gs.BlackKingSquare.Row, gs.BlackKingSquare.Col)
 	}
 }
 
+// SquareAttacked reports whether any piece of the player who is not to move
+// attacks the square.
 func (gs *GameState) SquareAttacked(r int, c int) bool {
-	gs.WhiteToMove = !gs.WhiteToMove
-	opponentMoves := gs.GetAllPossibleMoves()
-	gs.WhiteToMove = !gs.WhiteToMove
-	for _, move := range opponentMoves {
-		if move.EndRow == r && move.EndCol == c {
+	enemyColor := byte('b')
+	pawnRow := r - 1 // black pawns attack downwards
+	if !gs.WhiteToMove {
+		enemyColor = 'w'
+		pawnRow = r + 1
+	}
+
+	for _, pawnCol := range []int{c - 1, c + 1} {
+		if 0 <= pawnRow && pawnRow < 8 && 0 <= pawnCol && pawnCol < 8 && gs.Board[pawnRow][pawnCol] == string(enemyColor)+"p" {
 			return true
 		}
 	}
+
+	knightMoves := []PieceDelta{{-2, -1}, {-2, 1}, {-1, -2}, {-1, 2}, {1, -2}, {1, 2}, {2, -1}, {2, 1}}
+	for _, m := range knightMoves {
+		endRow := r + m.Row
+		endCol := c + m.Col
+		if 0 <= endRow && endRow < 8 && 0 <= endCol && endCol < 8 && gs.Board[endRow][endCol] == string(enemyColor)+"N" {
+			return true
+		}
+	}
+
+	// directions 0 to 3 are orthogonal, 4 to 7 are diagonal
+	directions := []PieceDelta{{-1, 0}, {0, -1}, {1, 0}, {0, 1}, {-1, -1}, {-1, 1}, {1, -1}, {1, 1}}
+	for j, d := range directions {
+		for i := 1; i < 8; i++ {
+			endRow := r + d.Row*i
+			endCol := c + d.Col*i
+			if endRow < 0 || endRow >= 8 || endCol < 0 || endCol >= 8 {
+				break
+			}
+			endPiece := gs.Board[endRow][endCol]
+			if endPiece == "--" {
+				continue
+			}
+			if endPiece[0] == enemyColor {
+				pieceType := endPiece[1]
+				if pieceType == 'Q' || pieceType == 'R' && j <= 3 || pieceType == 'B' && j >= 4 || i == 1 && pieceType == 'K' {
+					return true
+				}
+			}
+			break
+		}
+	}
+
 	return false
 }
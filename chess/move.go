@@ -0,0 +1,79 @@
+package chess
+
+import "strings"
+
+// PromotionPieceTypes lists the pieces a pawn can promote to, strongest first.
+const PromotionPieceTypes = "QRBN"
+
+type Move struct {
+	StartRow        int
+	StartCol        int
+	EndRow          int
+	EndCol          int
+	PieceMoved      string
+	PieceCaptured   string
+	IsPawnPromotion bool
+	PromotionPiece  string
+	IsEnPassant     bool
+	IsCastleMove    bool
+	MoveId          int
+}
+
+func NewMove(startSquare Square, endSquare Square, boardState BoardState, isEnPassant bool, isCastleMove bool) Move {
+	pieceMoved := boardState[startSquare.Row][startSquare.Col]
+
+	isPawnPromotion := (pieceMoved == "wp" && endSquare.Row == 0) || (pieceMoved == "bp" && endSquare.Row == 7)
+
+	promotionPiece := ""
+	if isPawnPromotion {
+		promotionPiece = pieceMoved[:1] + "Q"
+	}
+
+	return Move{
+		StartRow:        startSquare.Row,
+		StartCol:        startSquare.Col,
+		EndRow:          endSquare.Row,
+		EndCol:          endSquare.Col,
+		PieceMoved:      pieceMoved,
+		PieceCaptured:   boardState[endSquare.Row][endSquare.Col],
+		IsPawnPromotion: isPawnPromotion,
+		PromotionPiece:  promotionPiece,
+		IsEnPassant:     isEnPassant,
+		MoveId:          getMoveId(startSquare, endSquare, promotionPiece),
+		IsCastleMove:    isCastleMove,
+	}
+}
+
+// NewPromotionMove returns a pawn move to the last rank that promotes to
+// promotionPieceType, one of 'Q', 'R', 'B' or 'N'.
+func NewPromotionMove(startSquare Square, endSquare Square, boardState BoardState, promotionPieceType byte) Move {
+	move := NewMove(startSquare, endSquare, boardState, false, false)
+	move.PromotionPiece = move.PieceMoved[:1] + string(promotionPieceType)
+	move.MoveId = getMoveId(startSquare, endSquare, move.PromotionPiece)
+	return move
+}
+
+func getMoveId(startSquare Square, endSquare Square, promotionPiece string) int {
+	moveId := startSquare.Row*1000 + startSquare.Col*100 + endSquare.Row*10 + endSquare.Col
+	if promotionPiece != "" {
+		moveId += (strings.IndexByte(PromotionPieceTypes, promotionPiece[1]) + 1) * 10000
+	}
+	return moveId
+}
+
+func (m *Move) GetChessNotation() string {
+	return m.getSquareNotationFromIndexes(m.StartRow, m.StartCol) + " - " + m.getSquareNotationFromIndexes(m.EndRow, m.EndCol)
+}
+
+func (m *Move) getSquareNotationFromIndexes(row int, col int) string {
+	return SquareNotation(Square{row, col})
+}
+
+// GetUCINotation returns the move as UCI writes it, e.g. "e2e4" or "e7e8n".
+func (m *Move) GetUCINotation() string {
+	notation := m.getSquareNotationFromIndexes(m.StartRow, m.StartCol) + m.getSquareNotationFromIndexes(m.EndRow, m.EndCol)
+	if m.IsPawnPromotion {
+		notation += strings.ToLower(m.PromotionPiece[1:])
+	}
+	return notation
+}
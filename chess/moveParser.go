@@ -0,0 +1,134 @@
+package chess
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrMalformedMove = errors.New("malformed move")
+	ErrIllegalMove   = errors.New("illegal move")
+	ErrAmbiguousMove = errors.New("ambiguous move")
+)
+
+// ParseMoveError is returned by ParseMove. Err is one of ErrMalformedMove,
+// ErrIllegalMove or ErrAmbiguousMove, so callers can test it with errors.Is.
+type ParseMoveError struct {
+	Text string
+	Err  error
+}
+
+func (e *ParseMoveError) Error() string {
+	return fmt.Sprintf("chess: %v %q", e.Err, e.Text)
+}
+
+func (e *ParseMoveError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	coordinateMovePattern = regexp.MustCompile(`^([KQRBN])?([a-h][1-8])([-x])?([a-h][1-8])(?:=?([QRBNqrbn]))?$`)
+	sanMovePattern        = regexp.MustCompile(`^([KQRBN])?([a-h])?([1-8])?(x)?([a-h][1-8])(?:=?([QRBN]))?$`)
+)
+
+// ParseMove turns a move written in SAN ("Nbd7", "exd6", "e8=N+"), long
+// algebraic notation ("Ng1-f3", "e7xd8=Q") or UCI notation ("e7e8n") into
+// one of the legal moves in the current position.
+func (gs *GameState) ParseMove(text string) (Move, error) {
+	notation := strings.TrimSpace(text)
+	notation = strings.TrimRight(notation, "+#!?")
+
+	if gs.ValidMoves == nil {
+		saved := gs.saveScratchState()
+		validMoves := gs.GetValidMoves()
+		gs.restoreScratchState(saved)
+		gs.ValidMoves = validMoves
+	}
+
+	var candidates []Move
+	switch {
+	case notation == "O-O" || notation == "0-0":
+		candidates = gs.filterMoves(func(m Move) bool {
+			return m.IsCastleMove && m.EndCol > m.StartCol
+		})
+	case notation == "O-O-O" || notation == "0-0-0":
+		candidates = gs.filterMoves(func(m Move) bool {
+			return m.IsCastleMove && m.EndCol < m.StartCol
+		})
+	case coordinateMovePattern.MatchString(notation):
+		parts := coordinateMovePattern.FindStringSubmatch(notation)
+		pieceType, startText, separator, endText, promotion := parts[1], parts[2], parts[3], parts[4], strings.ToUpper(parts[5])
+		start, _ := SquareFromNotation(startText)
+		end, _ := SquareFromNotation(endText)
+		candidates = gs.filterMoves(func(m Move) bool {
+			if m.StartRow != start.Row || m.StartCol != start.Col || m.EndRow != end.Row || m.EndCol != end.Col {
+				return false
+			}
+			if pieceType != "" && m.PieceMoved[1] != pieceType[0] {
+				return false
+			}
+			if separator == "x" && !isCapture(m) {
+				return false
+			}
+			return promotionMatches(m, promotion)
+		})
+	case sanMovePattern.MatchString(notation):
+		parts := sanMovePattern.FindStringSubmatch(notation)
+		pieceType, fromFile, fromRank, capture, endText, promotion := parts[1], parts[2], parts[3], parts[4], parts[5], parts[6]
+		if pieceType == "" {
+			pieceType = "p"
+		}
+		end, _ := SquareFromNotation(endText)
+		candidates = gs.filterMoves(func(m Move) bool {
+			if m.PieceMoved[1] != pieceType[0] || m.IsCastleMove || m.EndRow != end.Row || m.EndCol != end.Col {
+				return false
+			}
+			if fromFile != "" && m.StartCol != int(fromFile[0]-'a') {
+				return false
+			}
+			if fromRank != "" && m.StartRow != int('8'-fromRank[0]) {
+				return false
+			}
+			if capture != "" && !isCapture(m) {
+				return false
+			}
+			return promotionMatches(m, promotion)
+		})
+	default:
+		return Move{}, &ParseMoveError{Text: text, Err: ErrMalformedMove}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return Move{}, &ParseMoveError{Text: text, Err: ErrIllegalMove}
+	case 1:
+		return candidates[0], nil
+	default:
+		return Move{}, &ParseMoveError{Text: text, Err: ErrAmbiguousMove}
+	}
+}
+
+func (gs *GameState) filterMoves(keep func(Move) bool) []Move {
+	moves := []Move{}
+	for _, move := range gs.ValidMoves {
+		if keep(move) {
+			moves = append(moves, move)
+		}
+	}
+	return moves
+}
+
+func isCapture(move Move) bool {
+	return move.PieceCaptured != "--" || move.IsEnPassant
+}
+
+// promotionMatches reports whether the promotion piece written after a move
+// agrees with the move.
+func promotionMatches(move Move, promotion string) bool {
+	if !move.IsPawnPromotion {
+		return promotion == ""
+	}
+	return promotion == move.PromotionPiece[1:]
+}
@@ -0,0 +1,81 @@
+package chess
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseMove(t *testing.T) {
+	tests := []struct {
+		fen  string
+		text string
+		want string
+	}{
+		{StartingPositionFEN, "Nf3", "g1f3"},
+		{StartingPositionFEN, "e4", "e2e4"},
+		{StartingPositionFEN, "Ng1-f3", "g1f3"},
+		{StartingPositionFEN, "e2e4", "e2e4"},
+		{StartingPositionFEN, " d4!? ", "d2d4"},
+		{"4k3/8/8/8/8/8/4K3/R6R w - - 0 1", "Rad1", "a1d1"},
+		{"4k3/8/8/R7/8/8/4K3/R7 w - - 0 1", "R1a3", "a1a3"},
+		{"6k1/8/8/8/8/Q1Q5/8/Q6K w - - 0 1", "Qa3b2", "a3b2"},
+		{"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "exd6", "e5d6"},
+		{"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "e5xd6", "e5d6"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "O-O", "e1g1"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "0-0-0", "e8c8"},
+		{"1n2k3/P7/8/8/8/8/8/4K3 w - - 0 1", "axb8=N", "a7b8n"},
+		{"1n2k3/P7/8/8/8/8/8/4K3 w - - 0 1", "a7a8Q+", "a7a8q"},
+		{"1n2k3/P7/8/8/8/8/8/4K3 w - - 0 1", "a7a8r", "a7a8r"},
+	}
+	for _, test := range tests {
+		gs, err := NewGameStateFromFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		move, err := gs.ParseMove(test.text)
+		if err != nil {
+			t.Errorf("%s: ParseMove(%q): %v", test.fen, test.text, err)
+			continue
+		}
+		if got := move.GetUCINotation(); got != test.want {
+			t.Errorf("%s: ParseMove(%q) = %s, want %s", test.fen, test.text, got, test.want)
+		}
+	}
+}
+
+func TestParseMoveErrors(t *testing.T) {
+	tests := []struct {
+		fen  string
+		text string
+		want error
+	}{
+		{StartingPositionFEN, "", ErrMalformedMove},
+		{StartingPositionFEN, "Nf", ErrMalformedMove},
+		{StartingPositionFEN, "e9", ErrMalformedMove},
+		{StartingPositionFEN, "Xe4", ErrMalformedMove},
+		{StartingPositionFEN, "O-O-O-O", ErrMalformedMove},
+		{StartingPositionFEN, "e5", ErrIllegalMove},
+		{StartingPositionFEN, "Nf4", ErrIllegalMove},
+		{StartingPositionFEN, "O-O", ErrIllegalMove},
+		{StartingPositionFEN, "exd3", ErrIllegalMove},
+		{StartingPositionFEN, "e2e5", ErrIllegalMove},
+		// A promotion needs its piece, and only a promotion may have one.
+		{"1n2k3/P7/8/8/8/8/8/4K3 w - - 0 1", "a8", ErrIllegalMove},
+		{"4k3/8/8/8/8/8/4P3/4K3 w - - 0 1", "e4=Q", ErrIllegalMove},
+		{"4k3/8/8/8/8/8/4K3/R6R w - - 0 1", "Rd1", ErrAmbiguousMove},
+		{"6k1/8/8/8/8/Q1Q5/8/Q6K w - - 0 1", "Qab2", ErrAmbiguousMove},
+		// The king may not move into check.
+		{"4k3/8/8/8/8/8/8/r3K3 w - - 0 1", "Kd1", ErrIllegalMove},
+	}
+	for _, test := range tests {
+		gs, err := NewGameStateFromFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = gs.ParseMove(test.text)
+		var parseErr *ParseMoveError
+		if !errors.As(err, &parseErr) || !errors.Is(err, test.want) {
+			t.Errorf("%s: ParseMove(%q) = %v, want %v", test.fen, test.text, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package chess
+
+import "testing"
+
+func TestPromotionMoveIds(t *testing.T) {
+	// The a7 pawn can promote by pushing to a8 or capturing on b8.
+	gs, err := NewGameStateFromFEN("1n5k/P7/8/8/8/8/8/K7 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := map[int]string{}
+	promotions := map[string]bool{}
+	for _, move := range gs.GetValidMoves() {
+		uci := move.GetUCINotation()
+		if other, ok := ids[move.MoveId]; ok {
+			t.Errorf("%s and %s share MoveId %d", other, uci, move.MoveId)
+		}
+		ids[move.MoveId] = uci
+		if move.IsPawnPromotion {
+			if move.PromotionPiece[0] != 'w' || move.PieceMoved != "wp" {
+				t.Errorf("%s promotes %s to %s", uci, move.PieceMoved, move.PromotionPiece)
+			}
+			promotions[uci] = true
+		}
+	}
+	for _, uci := range []string{"a7a8q", "a7a8r", "a7a8b", "a7a8n", "a7b8q", "a7b8r", "a7b8b", "a7b8n"} {
+		if !promotions[uci] {
+			t.Errorf("%s was not generated", uci)
+		}
+	}
+	if len(promotions) != 8 {
+		t.Errorf("generated %d promotions, want 8", len(promotions))
+	}
+}
+
+func TestUnderpromotionTakeBack(t *testing.T) {
+	gs, _ := NewGameStateFromFEN("7k/P7/8/8/8/8/8/K7 w - - 0 1")
+	start := gs.FEN()
+	knight := findMove(t, gs, "a7a8n")
+	gs.PlayMove(knight)
+	if gs.Board[0][0] != "wN" {
+		t.Fatalf("a8 holds %q after a8=N", gs.Board[0][0])
+	}
+
+	if !gs.TakeBackMove() || gs.FEN() != start {
+		t.Fatalf("after taking back a8=N: %s", gs.FEN())
+	}
+	if !gs.RedoMove() || gs.Board[0][0] != "wN" {
+		t.Fatalf("redoing a8=N left %q on a8", gs.Board[0][0])
+	}
+
+	// Promoting to a different piece is a different move, so it clears the
+	// redo stack instead of replaying the knight promotion.
+	gs.TakeBackMove()
+	gs.PlayMove(findMove(t, gs, "a7a8q"))
+	if gs.Board[0][0] != "wQ" || len(gs.RedoStack) != 0 {
+		t.Errorf("a8 holds %q with %d moves to redo", gs.Board[0][0], len(gs.RedoStack))
+	}
+}
@@ -0,0 +1,157 @@
+package chess
+
+type Result int
+
+const (
+	NoResult Result = iota
+	WhiteWins
+	BlackWins
+	Draw
+)
+
+// String returns the PGN result token.
+func (r Result) String() string {
+	switch r {
+	case WhiteWins:
+		return "1-0"
+	case BlackWins:
+		return "0-1"
+	case Draw:
+		return "1/2-1/2"
+	default:
+		return "*"
+	}
+}
+
+type Reason int
+
+const (
+	NoReason Reason = iota
+	Checkmate
+	Stalemate
+	InsufficientMaterial
+	FivefoldRepetition
+	SeventyFiveMoveRule
+	ThreefoldRepetition
+	FiftyMoveRule
+	// TablebaseResult is the result a tablebase gives for the position.
+	TablebaseResult
+)
+
+func (r Reason) String() string {
+	switch r {
+	case Checkmate:
+		return "checkmate"
+	case Stalemate:
+		return "stalemate"
+	case InsufficientMaterial:
+		return "insufficient material"
+	case FivefoldRepetition:
+		return "fivefold repetition"
+	case SeventyFiveMoveRule:
+		return "seventy-five-move rule"
+	case ThreefoldRepetition:
+		return "threefold repetition"
+	case FiftyMoveRule:
+		return "fifty-move rule"
+	case TablebaseResult:
+		return "tablebase"
+	default:
+		return ""
+	}
+}
+
+// Outcome is the result of a game and the reason it ended.
+type Outcome struct {
+	Result Result
+	Reason Reason
+	// Distance is, for a TablebaseResult, how many plies the winning side
+	// needs to win; see Tablebase.ProbeDistance.
+	Distance int
+}
+
+// Outcome reports whether the game is over without either player having to
+// claim anything: checkmate, stalemate, a dead position, fivefold repetition
+// or the seventy-five-move rule. It returns NoResult otherwise; see
+// ClaimableDraw for draws a player may claim.
+//
+// If gs.Tablebase is set and knows the position, a game that has not ended is
+// adjudicated as the tablebase says, counting wins that take too long for the
+// fifty-move rule as draws.
+func (gs *GameState) Outcome() Outcome {
+	saved := gs.saveScratchState()
+	gs.GetValidMoves()
+	checkmate, stalemate := gs.Checkmate, gs.Stalemate
+	gs.restoreScratchState(saved)
+
+	switch {
+	case checkmate && gs.WhiteToMove:
+		return Outcome{Result: BlackWins, Reason: Checkmate}
+	case checkmate:
+		return Outcome{Result: WhiteWins, Reason: Checkmate}
+	case stalemate:
+		return Outcome{Result: Draw, Reason: Stalemate}
+	case gs.IsInsufficientMaterial():
+		return Outcome{Result: Draw, Reason: InsufficientMaterial}
+	case gs.RepetitionCount() >= 5:
+		return Outcome{Result: Draw, Reason: FivefoldRepetition}
+	case gs.HalfmoveClock >= 150:
+		return Outcome{Result: Draw, Reason: SeventyFiveMoveRule}
+	}
+	if outcome, ok := gs.tablebaseOutcome(); ok {
+		return outcome
+	}
+	return Outcome{Result: NoResult, Reason: NoReason}
+}
+
+// ClaimableDraw returns ThreefoldRepetition or FiftyMoveRule if the player to
+// move may claim a draw, or NoReason if they may not.
+func (gs *GameState) ClaimableDraw() Reason {
+	if gs.RepetitionCount() >= 3 {
+		return ThreefoldRepetition
+	}
+	if gs.HalfmoveClock >= 100 {
+		return FiftyMoveRule
+	}
+	return NoReason
+}
+
+// RepetitionCount returns how many times the current position has occurred,
+// counting this occurrence.
+func (gs *GameState) RepetitionCount() int {
+	if len(gs.PositionHistory) == 0 {
+		return 1
+	}
+	current := gs.PositionHistory[len(gs.PositionHistory)-1]
+	count := 0
+	for _, hash := range gs.PositionHistory {
+		if hash == current {
+			count++
+		}
+	}
+	return count
+}
+
+// IsInsufficientMaterial reports whether neither side can possibly checkmate:
+// only kings remain, or kings and a single knight or bishop, or kings and any
+// number of bishops that all stand on squares of the same colour.
+func (gs *GameState) IsInsufficientMaterial() bool {
+	knights := 0
+	bishopSquareColors := map[int]bool{}
+	for r := 0; r < 8; r++ {
+		for c := 0; c < 8; c++ {
+			switch gs.Board[r][c][1] {
+			case 'p', 'R', 'Q':
+				return false
+			case 'N':
+				knights++
+			case 'B':
+				bishopSquareColors[(r+c)%2] = true
+			}
+		}
+	}
+	if knights == 0 {
+		return len(bishopSquareColors) <= 1
+	}
+	return knights == 1 && len(bishopSquareColors) == 0
+}
@@ -0,0 +1,96 @@
+package chess
+
+import "testing"
+
+func TestRepetition(t *testing.T) {
+	gs := NewGameState()
+	shuffle := "Nf3 Nf6 Ng1 Ng8"
+	for cycle := 1; cycle <= 4; cycle++ {
+		playMoves(t, gs, shuffle)
+		if got := gs.RepetitionCount(); got != cycle+1 {
+			t.Fatalf("after %d cycles the position has occurred %d times, want %d", cycle, got, cycle+1)
+		}
+		claim, outcome := gs.ClaimableDraw(), gs.Outcome()
+		switch cycle {
+		case 1:
+			if claim != NoReason || outcome.Reason != NoReason {
+				t.Errorf("twofold repetition: claim %v, outcome %v", claim, outcome.Reason)
+			}
+		case 2, 3:
+			if claim != ThreefoldRepetition || outcome.Reason != NoReason {
+				t.Errorf("%d-fold repetition: claim %v, outcome %v", cycle+1, claim, outcome.Reason)
+			}
+		case 4:
+			if outcome != (Outcome{Result: Draw, Reason: FivefoldRepetition}) {
+				t.Errorf("fivefold repetition: outcome %+v", outcome)
+			}
+		}
+	}
+
+	// Taking back a move takes its position out of the count.
+	gs.TakeBackMove()
+	if got := gs.RepetitionCount(); got != 4 {
+		t.Errorf("after taking back a move the position before it has occurred %d times, want 4", got)
+	}
+}
+
+func TestMoveRules(t *testing.T) {
+	tests := []struct {
+		fen     string
+		move    string
+		claim   Reason
+		outcome Reason
+	}{
+		{"4k3/8/8/8/8/8/8/R3K3 w - - 98 80", "Ra2", NoReason, NoReason},
+		{"4k3/8/8/8/8/8/8/R3K3 w - - 99 80", "Ra2", FiftyMoveRule, NoReason},
+		{"4k3/8/8/8/8/8/8/R3K3 w - - 148 80", "Ra2", FiftyMoveRule, NoReason},
+		{"4k3/8/8/8/8/8/8/R3K3 w - - 149 80", "Ra2", FiftyMoveRule, SeventyFiveMoveRule},
+		// A pawn move or a capture starts the count again.
+		{"4k3/8/8/8/8/8/P7/R3K3 w - - 149 80", "a3", NoReason, NoReason},
+		{"4k3/8/8/8/8/8/r7/R3K3 w - - 149 80", "Rxa2", NoReason, NoReason},
+	}
+	for _, test := range tests {
+		gs, err := NewGameStateFromFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		playMoves(t, gs, test.move)
+		if claim, outcome := gs.ClaimableDraw(), gs.Outcome().Reason; claim != test.claim || outcome != test.outcome {
+			t.Errorf("%s %s: claim %v, outcome %v, want %v, %v", test.fen, test.move, claim, outcome, test.claim, test.outcome)
+		}
+	}
+}
+
+func TestOutcome(t *testing.T) {
+	tests := []struct {
+		fen  string
+		want Outcome
+	}{
+		{StartingPositionFEN, Outcome{Result: NoResult, Reason: NoReason}},
+		{"rnb1kbnr/pppp1ppp/8/4p3/6Pq/5P2/PPPPP2P/RNBQKBNR w KQkq - 1 3", Outcome{Result: BlackWins, Reason: Checkmate}},
+		{"8/8/8/8/8/6k1/8/r5K1 w - - 0 1", Outcome{Result: BlackWins, Reason: Checkmate}},
+		{"7k/5Q2/6K1/8/8/8/8/8 b - - 0 1", Outcome{Result: Draw, Reason: Stalemate}},
+		{"4k3/8/8/8/8/8/8/4K3 w - - 0 1", Outcome{Result: Draw, Reason: InsufficientMaterial}},
+		{"4k3/8/8/8/8/8/8/2B1K3 w - - 0 1", Outcome{Result: Draw, Reason: InsufficientMaterial}},
+		{"4k3/8/8/8/8/8/8/1N2K3 b - - 0 1", Outcome{Result: Draw, Reason: InsufficientMaterial}},
+		// Bishops that all stand on dark squares, on both sides.
+		{"4kb2/8/8/8/8/8/8/B3K1B1 w - - 0 1", Outcome{Result: Draw, Reason: InsufficientMaterial}},
+		// Bishops on squares of both colours, two knights, a knight and a
+		// bishop, or any pawn, rook or queen can still mate.
+		{"2b1k3/8/8/8/8/8/8/B3K3 w - - 0 1", Outcome{Result: NoResult, Reason: NoReason}},
+		{"4k3/8/8/8/8/8/8/1N2K1N1 w - - 0 1", Outcome{Result: NoResult, Reason: NoReason}},
+		{"4k3/8/8/8/8/8/8/1N2KB2 w - - 0 1", Outcome{Result: NoResult, Reason: NoReason}},
+		{"4kn2/8/8/8/8/8/8/2B1K3 w - - 0 1", Outcome{Result: NoResult, Reason: NoReason}},
+		{"4k3/8/8/8/8/8/P7/4K3 w - - 0 1", Outcome{Result: NoResult, Reason: NoReason}},
+		{"4k3/8/8/8/8/8/8/R3K3 w - - 0 1", Outcome{Result: NoResult, Reason: NoReason}},
+	}
+	for _, test := range tests {
+		gs, err := NewGameStateFromFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := gs.Outcome(); got != test.want {
+			t.Errorf("%s: got %+v, want %+v", test.fen, got, test.want)
+		}
+	}
+}
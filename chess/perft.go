@@ -0,0 +1,38 @@
+package chess
+
+// Perft counts the positions reached after every sequence of depth legal
+// moves. Comparing the count with published figures is the standard way of
+// checking a move generator.
+func (gs *GameState) Perft(depth int) int {
+	if depth == 0 {
+		return 1
+	}
+
+	moves := gs.GetValidMoves()
+	if depth == 1 {
+		return len(moves)
+	}
+
+	nodes := 0
+	for _, move := range moves {
+		gs.MakeMove(move)
+		nodes += gs.Perft(depth - 1)
+		gs.UndoMove()
+	}
+	return nodes
+}
+
+// Divide returns the perft count below each legal move, keyed by the move in
+// UCI notation, which helps narrow a wrong total down to the faulty move.
+func (gs *GameState) Divide(depth int) map[string]int {
+	counts := map[string]int{}
+	if depth < 1 {
+		return counts
+	}
+	for _, move := range gs.GetValidMoves() {
+		gs.MakeMove(move)
+		counts[move.GetUCINotation()] = gs.Perft(depth - 1)
+		gs.UndoMove()
+	}
+	return counts
+}
@@ -0,0 +1,103 @@
+package chess
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Node counts from https://www.chessprogramming.org/Perft_Results
+var perftPositions = []struct {
+	name   string
+	fen    string
+	counts []int
+}{
+	{"start position", StartingPositionFEN, []int{20, 400, 8902, 197281, 4865609}},
+	{"kiwipete", "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", []int{48, 2039, 97862, 4085603}},
+	{"position 3", "8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1", []int{14, 191, 2812, 43238, 674624}},
+	{"position 4", "r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1", []int{6, 264, 9467, 422333}},
+	{"position 4 mirrored", "r2q1rk1/pP1p2pp/Q4n2/bbp1p3/Np6/1B3NBn/pPPP1PPP/R3K2R b KQ - 0 1", []int{6, 264, 9467, 422333}},
+	{"position 5", "rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8", []int{44, 1486, 62379, 2103487}},
+	{"position 6", "r4rk1/1pp1qppp/p1np1n2/2b1p1B1/2B1P1b1/P1NP1N2/1PP1QPPP/R4RK1 w - - 0 10", []int{46, 2079, 89890, 3894594}},
+}
+
+// perftShortLimit keeps go test -short fast.
+const perftShortLimit = 100000
+
+func TestPerft(t *testing.T) {
+	for _, position := range perftPositions {
+		t.Run(position.name, func(t *testing.T) {
+			gs, err := NewGameStateFromFEN(position.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, want := range position.counts {
+				if testing.Short() && want > perftShortLimit {
+					break
+				}
+				depth := i + 1
+				if got := gs.Perft(depth); got != want {
+					t.Errorf("perft(%d) = %d, want %d", depth, got, want)
+				}
+			}
+			if fen := gs.FEN(); fen != position.fen {
+				t.Errorf("position after perft = %q, want %q", fen, position.fen)
+			}
+		})
+	}
+}
+
+func TestDivide(t *testing.T) {
+	gs, err := NewGameStateFromFEN(perftPositions[1].fen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	divide := gs.Divide(2)
+	if len(divide) != 48 {
+		t.Fatalf("divide(2) has %d moves, want 48", len(divide))
+	}
+	total := 0
+	for _, nodes := range divide {
+		total += nodes
+	}
+	if total != 2039 {
+		t.Errorf("divide(2) sums to %d, want 2039", total)
+	}
+	if divide["e1g1"] != 43 {
+		t.Errorf("divide(2)[e1g1] = %d, want 43", divide["e1g1"])
+	}
+}
+
+// TestPawnPinnedAlongFile checks that a pawn pinned on its file, with its own
+// king in front of it, can still push towards the king. Both GameState and
+// Position must agree.
+func TestPawnPinnedAlongFile(t *testing.T) {
+	tests := []struct {
+		fen   string
+		moves []string
+	}{
+		{"k7/8/4K3/8/8/8/4P3/4r3 w - - 0 1", []string{"e2e3", "e2e4"}},
+		{"4R3/4p3/8/8/8/4k3/8/K7 b - - 0 1", []string{"e7e6", "e7e5"}},
+	}
+	for _, test := range tests {
+		gs, err := NewGameStateFromFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := NewPosition(gs)
+		for name, moves := range map[string][]Move{"GameState": gs.GetValidMoves(), "Position": p.GetValidMoves()} {
+			got := []string{}
+			for _, move := range moves {
+				if move.PieceMoved[1] == 'p' {
+					got = append(got, move.GetUCINotation())
+				}
+			}
+			sort.Strings(got)
+			want := append([]string{}, test.moves...)
+			sort.Strings(want)
+			if strings.Join(got, " ") != strings.Join(want, " ") {
+				t.Errorf("%s: %s pawn moves %v, want %v", test.fen, name, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,525 @@
+package chess
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// PGNGame is a game read from PGN text.
+type PGNGame struct {
+	Tags []PGNTag
+	// Comments holds any comments that come before the first move.
+	Comments []string
+	Moves    []PGNMove
+	Result   string
+	// GameState is the position at the end of the main line, with every move
+	// of the main line in its MoveLog.
+	GameState *GameState
+}
+
+// PGNMove is a move of a PGN game together with its annotations.
+type PGNMove struct {
+	Move Move
+	// Text is the move as it was written in the PGN.
+	Text       string
+	NAGs       []int
+	Comments   []string
+	Variations []PGNVariation
+}
+
+// PGNVariation is a line played instead of a move.
+type PGNVariation struct {
+	// Comments holds any comments that come before the first move.
+	Comments []string
+	Moves    []PGNMove
+}
+
+// Tag returns the value of the named tag, or "" if the game does not have it.
+func (g *PGNGame) Tag(name string) string {
+	for _, tag := range g.Tags {
+		if tag.Name == name {
+			return tag.Value
+		}
+	}
+	return ""
+}
+
+// PGNError reports where in a PGN stream a game could not be read. Game is
+// the 1-based index of the game in the stream.
+type PGNError struct {
+	Game   int
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *PGNError) Error() string {
+	return fmt.Sprintf("chess: pgn game %d, line %d, column %d: %v", e.Game, e.Line, e.Column, e.Err)
+}
+
+func (e *PGNError) Unwrap() error {
+	return e.Err
+}
+
+// suffixAnnotations maps the traditional move suffixes onto their NAGs.
+var suffixAnnotations = map[string]int{"!": 1, "?": 2, "!!": 3, "??": 4, "!?": 5, "?!": 6}
+
+type pgnTokenKind int
+
+const (
+	pgnEOF pgnTokenKind = iota
+	pgnLeftBracket
+	pgnRightBracket
+	pgnLeftParen
+	pgnRightParen
+	pgnString
+	pgnSymbol
+	pgnPeriod
+	pgnAsterisk
+	pgnNAG
+	pgnSuffix
+	pgnComment
+)
+
+type pgnToken struct {
+	kind   pgnTokenKind
+	text   string
+	line   int
+	column int
+}
+
+// PGNReader reads games one at a time from a stream of PGN text, so files of
+// any size can be processed without holding them in memory.
+type PGNReader struct {
+	r       *bufio.Reader
+	line    int
+	column  int
+	game    int
+	pending *pgnToken
+	resync  bool
+}
+
+func NewPGNReader(r io.Reader) *PGNReader {
+	return &PGNReader{r: bufio.NewReader(r), line: 1, column: 0}
+}
+
+// ParsePGN reads every game in r.
+func ParsePGN(r io.Reader) ([]*PGNGame, error) {
+	pr := NewPGNReader(r)
+	games := []*PGNGame{}
+	for {
+		game, err := pr.Next()
+		if err == io.EOF {
+			return games, nil
+		}
+		if err != nil {
+			return games, err
+		}
+		games = append(games, game)
+	}
+}
+
+// Next reads the next game, replaying its main line and variations through
+// MakeMove. It returns io.EOF when there are no more games. After a
+// *PGNError, calling Next again skips to the start of the following game.
+func (pr *PGNReader) Next() (*PGNGame, error) {
+	if pr.resync {
+		if err := pr.skipToNextGame(); err != nil {
+			return nil, err
+		}
+	}
+
+	tok, err := pr.nextToken()
+	if err != nil {
+		return nil, err
+	}
+	if tok.kind == pgnEOF {
+		return nil, io.EOF
+	}
+	pr.pending = &tok
+	pr.game++
+
+	game, err := pr.readGame()
+	if err != nil {
+		pr.resync = true
+		return nil, err
+	}
+	return game, nil
+}
+
+func (pr *PGNReader) readGame() (*PGNGame, error) {
+	game := &PGNGame{}
+
+	for {
+		tok, err := pr.nextToken()
+		if err != nil {
+			return nil, err
+		}
+		if tok.kind != pgnLeftBracket {
+			pr.pending = &tok
+			break
+		}
+		name, err := pr.expectToken(pgnSymbol, "tag name")
+		if err != nil {
+			return nil, err
+		}
+		value, err := pr.expectToken(pgnString, "tag value")
+		if err != nil {
+			return nil, err
+		}
+		if _, err := pr.expectToken(pgnRightBracket, "]"); err != nil {
+			return nil, err
+		}
+		game.Tags = append(game.Tags, PGNTag{Name: name.text, Value: value.text})
+
+		if name.text == "FEN" {
+			gs, err := NewGameStateFromFEN(value.text)
+			if err != nil {
+				return nil, pr.errorAt(value, err)
+			}
+			game.GameState = gs
+		}
+	}
+
+	if game.GameState == nil {
+		game.GameState = NewGameState()
+	}
+
+	moves, comments, result, err := pr.readLine(game.GameState, 0)
+	if err != nil {
+		return nil, err
+	}
+	game.Moves = moves
+	game.Comments = comments
+	game.Result = result
+	return game, nil
+}
+
+// readLine reads moves from gs until the end of a variation (depth > 0) or the
+// game termination marker (depth 0). It returns the moves, any comments that
+// came before the first move and the result.
+func (pr *PGNReader) readLine(gs *GameState, depth int) ([]PGNMove, []string, string, error) {
+	moves := []PGNMove{}
+	comments := []string{}
+
+	for {
+		tok, err := pr.nextToken()
+		if err != nil {
+			return nil, nil, "", err
+		}
+
+		switch tok.kind {
+		case pgnPeriod:
+		case pgnSymbol:
+			if isMoveNumber(tok.text) {
+				continue
+			}
+			if tok.text == "1-0" || tok.text == "0-1" || tok.text == "1/2-1/2" {
+				if depth > 0 {
+					return nil, nil, "", pr.errorAt(tok, errors.New("game termination marker inside a variation"))
+				}
+				return moves, comments, tok.text, nil
+			}
+			move, err := gs.ParseMove(tok.text)
+			if err != nil {
+				return nil, nil, "", pr.errorAt(tok, err)
+			}
+			gs.MakeMove(move)
+			moves = append(moves, PGNMove{Move: move, Text: tok.text})
+		case pgnAsterisk:
+			if depth > 0 {
+				return nil, nil, "", pr.errorAt(tok, errors.New("game termination marker inside a variation"))
+			}
+			return moves, comments, "*", nil
+		case pgnNAG, pgnSuffix:
+			if len(moves) == 0 {
+				return nil, nil, "", pr.errorAt(tok, fmt.Errorf("annotation %q before any move", tok.text))
+			}
+			nag, ok := suffixAnnotations[tok.text]
+			if tok.kind == pgnNAG {
+				nag, err = strconv.Atoi(tok.text[1:])
+				ok = err == nil && nag <= 255
+			}
+			if !ok {
+				return nil, nil, "", pr.errorAt(tok, fmt.Errorf("invalid annotation %q", tok.text))
+			}
+			moves[len(moves)-1].NAGs = append(moves[len(moves)-1].NAGs, nag)
+		case pgnComment:
+			if len(moves) == 0 {
+				comments = append(comments, tok.text)
+			} else {
+				moves[len(moves)-1].Comments = append(moves[len(moves)-1].Comments, tok.text)
+			}
+		case pgnLeftParen:
+			if len(moves) == 0 {
+				return nil, nil, "", pr.errorAt(tok, errors.New("variation before any move"))
+			}
+			// The variation replaces the last move, so it starts from the
+			// position before it. Copying the game only here keeps reading
+			// a long main line linear.
+			before := gs.Clone()
+			before.UndoMove()
+			variation, variationComments, _, err := pr.readLine(before, depth+1)
+			if err != nil {
+				return nil, nil, "", err
+			}
+			last := &moves[len(moves)-1]
+			last.Variations = append(last.Variations, PGNVariation{Comments: variationComments, Moves: variation})
+		case pgnRightParen:
+			if depth == 0 {
+				return nil, nil, "", pr.errorAt(tok, errors.New("unexpected \")\""))
+			}
+			return moves, comments, "", nil
+		case pgnEOF:
+			if depth > 0 {
+				return nil, nil, "", pr.errorAt(tok, errors.New("unterminated variation"))
+			}
+			return nil, nil, "", pr.errorAt(tok, errors.New("missing game termination marker"))
+		default:
+			return nil, nil, "", pr.errorAt(tok, fmt.Errorf("unexpected %q in movetext", tok.text))
+		}
+	}
+}
+
+func isMoveNumber(text string) bool {
+	for _, r := range text {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (pr *PGNReader) expectToken(kind pgnTokenKind, what string) (pgnToken, error) {
+	tok, err := pr.nextToken()
+	if err != nil {
+		return tok, err
+	}
+	if tok.kind != kind {
+		return tok, pr.errorAt(tok, fmt.Errorf("expected %s, got %q", what, tok.text))
+	}
+	return tok, nil
+}
+
+func (pr *PGNReader) errorAt(tok pgnToken, err error) error {
+	return &PGNError{Game: pr.game, Line: tok.line, Column: tok.column, Err: err}
+}
+
+// skipToNextGame throws away tokens up to the tag section of the next game.
+func (pr *PGNReader) skipToNextGame() error {
+	pr.resync = false
+	for {
+		tok, err := pr.nextToken()
+		if err != nil {
+			var pgnErr *PGNError
+			if errors.As(err, &pgnErr) {
+				continue
+			}
+			return err
+		}
+		if tok.kind == pgnLeftBracket || tok.kind == pgnEOF {
+			pr.pending = &tok
+			return nil
+		}
+	}
+}
+
+func (pr *PGNReader) readRune() (rune, error) {
+	r, _, err := pr.r.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	if r == '\n' {
+		pr.line++
+		pr.column = 0
+	} else {
+		pr.column++
+	}
+	return r, nil
+}
+
+func (pr *PGNReader) peekRune() (rune, error) {
+	r, _, err := pr.r.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	pr.r.UnreadRune()
+	return r, nil
+}
+
+func isSymbolRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_+#=:-/", r)
+}
+
+func (pr *PGNReader) nextToken() (pgnToken, error) {
+	if pr.pending != nil {
+		tok := *pr.pending
+		pr.pending = nil
+		return tok, nil
+	}
+
+	for {
+		r, err := pr.readRune()
+		if err == io.EOF {
+			return pgnToken{kind: pgnEOF, line: pr.line, column: pr.column + 1}, nil
+		}
+		if err != nil {
+			return pgnToken{}, err
+		}
+		tok := pgnToken{text: string(r), line: pr.line, column: pr.column}
+
+		switch {
+		case r == '\uFEFF' || unicode.IsSpace(r):
+		case r == '%' && pr.column == 1:
+			if err := pr.skipLine(); err != nil {
+				return tok, err
+			}
+		case r == ';':
+			text, err := pr.readUntil('\n', false)
+			if err != nil {
+				return tok, err
+			}
+			tok.kind, tok.text = pgnComment, strings.TrimSpace(text)
+			return tok, nil
+		case r == '{':
+			text, err := pr.readUntil('}', true)
+			if err == io.ErrUnexpectedEOF {
+				return tok, pr.errorAt(tok, errors.New("unterminated comment"))
+			}
+			if err != nil {
+				return tok, err
+			}
+			tok.kind, tok.text = pgnComment, strings.TrimSpace(text)
+			return tok, nil
+		case r == '"':
+			text, err := pr.readString()
+			if err == io.ErrUnexpectedEOF {
+				return tok, pr.errorAt(tok, errors.New("unterminated string"))
+			}
+			if err != nil {
+				return tok, err
+			}
+			tok.kind, tok.text = pgnString, text
+			return tok, nil
+		case r == '[':
+			tok.kind = pgnLeftBracket
+			return tok, nil
+		case r == ']':
+			tok.kind = pgnRightBracket
+			return tok, nil
+		case r == '(':
+			tok.kind = pgnLeftParen
+			return tok, nil
+		case r == ')':
+			tok.kind = pgnRightParen
+			return tok, nil
+		case r == '.':
+			tok.kind = pgnPeriod
+			return tok, nil
+		case r == '*':
+			tok.kind = pgnAsterisk
+			return tok, nil
+		case r == '$':
+			text, err := pr.readWhile(unicode.IsDigit)
+			if err != nil {
+				return tok, err
+			}
+			tok.kind, tok.text = pgnNAG, "$"+text
+			return tok, nil
+		case r == '!' || r == '?':
+			text, err := pr.readWhile(func(r rune) bool { return r == '!' || r == '?' })
+			if err != nil {
+				return tok, err
+			}
+			tok.kind, tok.text = pgnSuffix, string(r)+text
+			return tok, nil
+		case isSymbolRune(r):
+			text, err := pr.readWhile(isSymbolRune)
+			if err != nil {
+				return tok, err
+			}
+			tok.kind, tok.text = pgnSymbol, string(r)+text
+			return tok, nil
+		default:
+			return tok, pr.errorAt(tok, fmt.Errorf("unexpected character %q", r))
+		}
+	}
+}
+
+func (pr *PGNReader) skipLine() error {
+	_, err := pr.readUntil('\n', false)
+	return err
+}
+
+// readUntil reads up to and including end, returning the text before it. If
+// the stream ends first, it returns io.ErrUnexpectedEOF when required is set.
+func (pr *PGNReader) readUntil(end rune, required bool) (string, error) {
+	var sb strings.Builder
+	for {
+		r, err := pr.readRune()
+		if err == io.EOF {
+			if required {
+				return sb.String(), io.ErrUnexpectedEOF
+			}
+			return sb.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if r == end {
+			return sb.String(), nil
+		}
+		sb.WriteRune(r)
+	}
+}
+
+func (pr *PGNReader) readString() (string, error) {
+	var sb strings.Builder
+	for {
+		r, err := pr.readRune()
+		if err == io.EOF {
+			return sb.String(), io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case '"':
+			return sb.String(), nil
+		case '\\':
+			next, err := pr.readRune()
+			if err == io.EOF {
+				return sb.String(), io.ErrUnexpectedEOF
+			}
+			if err != nil {
+				return "", err
+			}
+			sb.WriteRune(next)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+}
+
+func (pr *PGNReader) readWhile(accept func(rune) bool) (string, error) {
+	var sb strings.Builder
+	for {
+		r, err := pr.peekRune()
+		if err == io.EOF {
+			return sb.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if !accept(r) {
+			return sb.String(), nil
+		}
+		pr.readRune()
+		sb.WriteRune(r)
+	}
+}
@@ -0,0 +1,140 @@
+package chess
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const annotatedPGN = `[Event "Casual"]
+[White "A"]
+[Black "B"]
+[Result "1-0"]
+
+{Opening comment} 1. e4 $1 e5 ; after e5
+2. Nf3!? (2. f4 {King's Gambit} exf4 (2... d5) 3. Nf3) ({Quiet} 2. d3 {too quiet}) ({Anything}) 2... Nc6 {Main} 3. Bb5 1-0
+
+[Event "Second"]
+[SetUp "1"]
+[FEN "4k3/8/8/8/8/8/4P3/4K3 b - - 0 30"]
+
+30... Kd7 31. e4 *
+`
+
+func TestReadPGN(t *testing.T) {
+	games, err := ParsePGN(strings.NewReader(annotatedPGN))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != 2 {
+		t.Fatalf("read %d games", len(games))
+	}
+
+	game := games[0]
+	if game.Tag("Event") != "Casual" || game.Tag("Black") != "B" || game.Tag("Round") != "" || game.Result != "1-0" {
+		t.Errorf("tags %v, result %q", game.Tags, game.Result)
+	}
+	if !reflect.DeepEqual(game.Comments, []string{"Opening comment"}) {
+		t.Errorf("game comments %q", game.Comments)
+	}
+	texts := []string{}
+	for _, move := range game.Moves {
+		texts = append(texts, move.Text)
+	}
+	if !reflect.DeepEqual(texts, []string{"e4", "e5", "Nf3", "Nc6", "Bb5"}) {
+		t.Fatalf("main line %q", texts)
+	}
+	if !reflect.DeepEqual(game.Moves[0].NAGs, []int{1}) || !reflect.DeepEqual(game.Moves[2].NAGs, []int{5}) {
+		t.Errorf("NAGs %v and %v", game.Moves[0].NAGs, game.Moves[2].NAGs)
+	}
+	if !reflect.DeepEqual(game.Moves[1].Comments, []string{"after e5"}) || !reflect.DeepEqual(game.Moves[3].Comments, []string{"Main"}) {
+		t.Errorf("comments %q and %q", game.Moves[1].Comments, game.Moves[3].Comments)
+	}
+
+	variations := game.Moves[2].Variations
+	if len(variations) != 3 || len(variations[0].Moves) != 3 {
+		t.Fatalf("variations %+v", variations)
+	}
+	gambit := variations[0].Moves
+	if gambit[0].Text != "f4" || !reflect.DeepEqual(gambit[0].Comments, []string{"King's Gambit"}) || gambit[2].Text != "Nf3" {
+		t.Errorf("variation %+v", gambit)
+	}
+	if len(gambit[1].Variations) != 1 || gambit[1].Variations[0].Moves[0].Move.GetUCINotation() != "d7d5" {
+		t.Errorf("nested variation %+v", gambit[1].Variations)
+	}
+	quiet := variations[1]
+	if !reflect.DeepEqual(quiet.Comments, []string{"Quiet"}) || len(quiet.Moves) != 1 || !reflect.DeepEqual(quiet.Moves[0].Comments, []string{"too quiet"}) {
+		t.Errorf("variation %+v", quiet)
+	}
+	if comment := variations[2]; !reflect.DeepEqual(comment.Comments, []string{"Anything"}) || len(comment.Moves) != 0 {
+		t.Errorf("variation %+v", comment)
+	}
+	if got, want := game.GameState.FEN(), "r1bqkbnr/pppp1ppp/2n5/1B2p3/4P3/5N2/PPPP1PPP/RNBQK2R b KQkq - 3 3"; got != want {
+		t.Errorf("main line ends at %s, want %s", got, want)
+	}
+
+	game = games[1]
+	if game.Result != "*" || len(game.Moves) != 2 || game.GameState.FEN() != "8/3k4/8/8/4P3/8/8/4K3 b - e3 0 31" {
+		t.Errorf("game from FEN ends at %s after %d moves", game.GameState.FEN(), len(game.Moves))
+	}
+}
+
+func TestReadPGNErrors(t *testing.T) {
+	tests := []struct {
+		pgn    string
+		line   int
+		column int
+		err    error
+	}{
+		{"1. e4 e5 2. Ke3 *", 1, 13, ErrIllegalMove},
+		{"[Event \"x\"]\n\n1. e4\n   Nf6 Nf6 *", 4, 8, ErrIllegalMove},
+		{"1. e4 (1. d4) *", 0, 0, nil},
+		{"1. e4 {never closed", 1, 7, nil},
+		{"1. e4 e5 (1... c5", 1, 18, nil},
+		{"1. e4 e5 ) *", 1, 10, nil},
+		{"$3 1. e4 *", 1, 1, nil},
+		{"1. e4 e5", 1, 9, nil},
+		{"[FEN \"8/8/8/8/8/8/8/8 w - - 0 1\"]\n*", 1, 6, nil},
+	}
+	for _, test := range tests {
+		_, err := NewPGNReader(strings.NewReader(test.pgn)).Next()
+		if test.line == 0 {
+			if err != nil {
+				t.Errorf("%q: %v", test.pgn, err)
+			}
+			continue
+		}
+		var pgnErr *PGNError
+		if !errors.As(err, &pgnErr) {
+			t.Errorf("%q: got %v, want a PGNError", test.pgn, err)
+			continue
+		}
+		if pgnErr.Game != 1 || pgnErr.Line != test.line || pgnErr.Column != test.column {
+			t.Errorf("%q: error at game %d, line %d, column %d (%v), want line %d, column %d", test.pgn, pgnErr.Game, pgnErr.Line, pgnErr.Column, err, test.line, test.column)
+		}
+		if test.err != nil && !errors.Is(err, test.err) {
+			t.Errorf("%q: got %v, want %v", test.pgn, err, test.err)
+		}
+	}
+}
+
+// TestReadPGNSkipsBadGames checks that a bad game is reported with its index
+// and that reading carries on with the next one.
+func TestReadPGNSkipsBadGames(t *testing.T) {
+	pr := NewPGNReader(strings.NewReader("[Event \"1\"]\n1. e4 *\n\n[Event \"2\"]\n1. e5 *\n\n[Event \"3\"]\n1. d4 *\n"))
+	if game, err := pr.Next(); err != nil || game.Tag("Event") != "1" {
+		t.Fatalf("first game: %v", err)
+	}
+	var pgnErr *PGNError
+	if _, err := pr.Next(); !errors.As(err, &pgnErr) || pgnErr.Game != 2 || pgnErr.Line != 5 {
+		t.Fatalf("second game: %v", err)
+	}
+	if game, err := pr.Next(); err != nil || game.Tag("Event") != "3" || game.Moves[0].Text != "d4" {
+		t.Fatalf("third game: %v", err)
+	}
+	if _, err := pr.Next(); err != io.EOF {
+		t.Errorf("after the last game: %v", err)
+	}
+}
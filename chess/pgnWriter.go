@@ -0,0 +1,127 @@
+package chess
+
+import (
+	"io"
+	"strconv"
+	"strings"
+)
+
+// pgnLineLength is the longest movetext line written, as recommended by the
+// PGN export format.
+const pgnLineLength = 79
+
+var sevenTagRoster = []string{"Event", "Site", "Date", "Round", "White", "Black", "Result"}
+
+var sevenTagRosterDefaults = map[string]string{
+	"Event": "?", "Site": "?", "Date": "????.??.??", "Round": "?", "White": "?", "Black": "?",
+}
+
+type PGNTag struct {
+	Name  string
+	Value string
+}
+
+// Result returns the PGN result token for the current position, "*" unless
+// Outcome reports that the game is over.
+func (gs *GameState) Result() string {
+	return gs.Outcome().Result.String()
+}
+
+// PGN returns the game played from StartFEN as PGN text. See WritePGN.
+func (gs *GameState) PGN(tags []PGNTag) string {
+	var sb strings.Builder
+	gs.WritePGN(&sb, tags)
+	return sb.String()
+}
+
+// WritePGN writes the game played from StartFEN in PGN export format. The
+// Seven Tag Roster is always written first, using tags for any values that are
+// known and "?" for the rest, followed by the remaining tags in order. A
+// Result tag is only used when the position on the board does not decide the
+// game, for example after a resignation. Games that did not start from the
+// standard position get SetUp and FEN tags.
+func (gs *GameState) WritePGN(w io.Writer, tags []PGNTag) error {
+	values := map[string]string{}
+	for _, tag := range tags {
+		values[tag.Name] = tag.Value
+	}
+
+	result := gs.Result()
+	if result == "*" && values["Result"] != "" {
+		result = values["Result"]
+	}
+
+	var sb strings.Builder
+	for _, name := range sevenTagRoster {
+		value, ok := values[name]
+		if !ok {
+			value = sevenTagRosterDefaults[name]
+		}
+		if name == "Result" {
+			value = result
+		}
+		writePGNTag(&sb, name, value)
+	}
+	if gs.StartFEN != StartingPositionFEN {
+		writePGNTag(&sb, "SetUp", "1")
+		writePGNTag(&sb, "FEN", gs.StartFEN)
+	}
+	for _, tag := range tags {
+		if _, ok := sevenTagRosterDefaults[tag.Name]; ok || tag.Name == "Result" || tag.Name == "SetUp" || tag.Name == "FEN" {
+			continue
+		}
+		writePGNTag(&sb, tag.Name, tag.Value)
+	}
+	sb.WriteString("\n")
+
+	tokens, err := gs.movetextTokens()
+	if err != nil {
+		return err
+	}
+	tokens = append(tokens, result)
+
+	lineLength := 0
+	for _, token := range tokens {
+		if lineLength > 0 && lineLength+1+len(token) > pgnLineLength {
+			sb.WriteString("\n")
+			lineLength = 0
+		}
+		if lineLength > 0 {
+			sb.WriteString(" ")
+			lineLength++
+		}
+		sb.WriteString(token)
+		lineLength += len(token)
+	}
+	sb.WriteString("\n\n")
+
+	_, err = io.WriteString(w, sb.String())
+	return err
+}
+
+// movetextTokens replays MoveLog from StartFEN and returns the move numbers
+// and SAN moves that make up the movetext.
+func (gs *GameState) movetextTokens() ([]string, error) {
+	replay, err := NewGameStateFromFEN(gs.StartFEN)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := []string{}
+	for i, move := range gs.MoveLog {
+		if replay.WhiteToMove {
+			tokens = append(tokens, strconv.Itoa(replay.FullmoveNumber)+".")
+		} else if i == 0 {
+			tokens = append(tokens, strconv.Itoa(replay.FullmoveNumber)+"...")
+		}
+		tokens = append(tokens, replay.GetSAN(move))
+		replay.MakeMove(move)
+	}
+	return tokens, nil
+}
+
+func writePGNTag(sb *strings.Builder, name string, value string) {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	sb.WriteString("[" + name + " \"" + value + "\"]\n")
+}
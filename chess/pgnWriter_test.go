@@ -0,0 +1,95 @@
+package chess
+
+import (
+	"strings"
+	"testing"
+)
+
+// playMoves plays moves written in any notation ParseMove accepts.
+func playMoves(t *testing.T, gs *GameState, moves string) {
+	t.Helper()
+	for _, text := range strings.Fields(moves) {
+		move, err := gs.ParseMove(text)
+		if err != nil {
+			t.Fatalf("%s: %v", gs.FEN(), err)
+		}
+		gs.PlayMove(move)
+	}
+}
+
+func TestWritePGN(t *testing.T) {
+	gs := NewGameState()
+	playMoves(t, gs, "e4 e5 Bc4 Nc6 Qh5 Nf6 Qxf7#")
+	got := gs.PGN([]PGNTag{{"White", "Alice"}, {"Black", "Bob"}, {"Annotator", "Carol"}, {"Result", "0-1"}})
+	want := `[Event "?"]
+[Site "?"]
+[Date "????.??.??"]
+[Round "?"]
+[White "Alice"]
+[Black "Bob"]
+[Result "1-0"]
+[Annotator "Carol"]
+
+1. e4 e5 2. Bc4 Nc6 3. Qh5 Nf6 4. Qxf7# 1-0
+
+`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWritePGNResultAndSetUp(t *testing.T) {
+	gs, err := NewGameStateFromFEN(`4k3/8/8/8/8/8/4P3/4K3 b - - 0 30`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	playMoves(t, gs, "Kd7 e4")
+	got := gs.PGN([]PGNTag{{"Event", `The "Open"`}, {"Result", "1/2-1/2"}})
+	want := `[Event "The \"Open\""]
+[Site "?"]
+[Date "????.??.??"]
+[Round "?"]
+[White "?"]
+[Black "?"]
+[Result "1/2-1/2"]
+[SetUp "1"]
+[FEN "4k3/8/8/8/8/8/4P3/4K3 b - - 0 30"]
+
+30... Kd7 31. e4 1/2-1/2
+
+`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	gs = NewGameState()
+	playMoves(t, gs, "e4")
+	if !strings.Contains(gs.PGN(nil), "\n1. e4 *\n") {
+		t.Errorf("unfinished game without a Result tag:\n%s", gs.PGN(nil))
+	}
+}
+
+func TestWritePGNWrapsLines(t *testing.T) {
+	gs := NewGameState()
+	// Knights out and back, long enough to need several lines.
+	for i := 0; i < 10; i++ {
+		playMoves(t, gs, "Nf3 Nf6 Ng1 Ng8")
+	}
+	text := gs.PGN([]PGNTag{{"Result", "1/2-1/2"}})
+	movetext := text[strings.Index(text, "\n\n")+2:]
+	lines := strings.Split(strings.TrimRight(movetext, "\n"), "\n")
+	if len(lines) < 3 {
+		t.Fatalf("movetext not wrapped:\n%s", movetext)
+	}
+	for _, line := range lines {
+		if len(line) > pgnLineLength {
+			t.Errorf("line of %d characters: %q", len(line), line)
+		}
+		if strings.HasPrefix(line, " ") || strings.HasSuffix(line, " ") {
+			t.Errorf("line with surrounding spaces: %q", line)
+		}
+	}
+	if joined := strings.Join(lines, " "); !strings.HasPrefix(joined, "1. Nf3 Nf6 2. Ng1 Ng8 3. Nf3") || !strings.HasSuffix(joined, "20. Ng1 Ng8 1/2-1/2") {
+		t.Errorf("movetext %q", joined)
+	}
+}
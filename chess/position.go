@@ -0,0 +1,554 @@
+package chess
+
+const (
+	White = 0
+	Black = 1
+)
+
+const (
+	Pawn = iota
+	Knight
+	Bishop
+	Rook
+	Queen
+	King
+)
+
+const noPiece = -1
+
+// pieceCodes maps color*6+pieceType onto the piece names used by BoardState.
+var pieceCodes = [12]string{"wp", "wN", "wB", "wR", "wQ", "wK", "bp", "bN", "bB", "bR", "bQ", "bK"}
+
+// pieceIndex returns color*6+pieceType for a piece name such as "bN", or
+// noPiece for "--".
+func pieceIndex(code string) int {
+	if code == "--" || code == "" {
+		return noPiece
+	}
+	index := 0
+	if code[0] == 'b' {
+		index = 6
+	}
+	switch code[1] {
+	case 'N':
+		index += Knight
+	case 'B':
+		index += Bishop
+	case 'R':
+		index += Rook
+	case 'Q':
+		index += Queen
+	case 'K':
+		index += King
+	}
+	return index
+}
+
+// Position is a bitboard representation of a game. It follows the same rules
+// as GameState and makes and undoes the same Move values, but finds attacks
+// with set operations rather than by scanning the board, which makes it the
+// better choice when a very large number of positions has to be searched.
+type Position struct {
+	Pieces          [2][6]Bitboard
+	Colors          [2]Bitboard
+	WhiteToMove     bool
+	CastleRights    CastleRights
+	EnPassantSquare Square
+	HalfmoveClock   int
+	FullmoveNumber  int
+	MoveLog         []Move
+	Checkmate       bool
+	Stalemate       bool
+	// Hash is the same Zobrist key GameState.Hash has for the position, and
+	// PositionHistory holds the keys of the positions played so far, ending
+	// with this one.
+	Hash            uint64
+	PositionHistory []uint64
+
+	board   [64]int
+	undoLog []positionUndo
+}
+
+type positionUndo struct {
+	castleRights    CastleRights
+	enPassantSquare Square
+	halfmoveClock   int
+	hash            uint64
+}
+
+// NewPosition returns the bitboard equivalent of the current position of gs.
+// The moves of gs are not copied, but the keys in its PositionHistory are, so
+// RepetitionCount still counts positions from before.
+func NewPosition(gs *GameState) *Position {
+	p := &Position{
+		WhiteToMove:     gs.WhiteToMove,
+		CastleRights:    gs.CastleRights,
+		EnPassantSquare: gs.EnPassantSquare,
+		HalfmoveClock:   gs.HalfmoveClock,
+		FullmoveNumber:  gs.FullmoveNumber,
+	}
+	for sq := range p.board {
+		p.board[sq] = noPiece
+	}
+	for r := 0; r < 8; r++ {
+		for c := 0; c < 8; c++ {
+			if piece := pieceIndex(gs.Board[r][c]); piece != noPiece {
+				p.putPiece(r*8+c, piece)
+			}
+		}
+	}
+	p.Hash = p.ComputeHash()
+	p.PositionHistory = append([]uint64(nil), gs.PositionHistory...)
+	if len(p.PositionHistory) == 0 {
+		p.PositionHistory = []uint64{p.Hash}
+	}
+	return p
+}
+
+// Clone returns a copy of the position that shares no memory with it.
+func (p *Position) Clone() *Position {
+	clone := *p
+	clone.MoveLog = append([]Move(nil), p.MoveLog...)
+	clone.PositionHistory = append([]uint64(nil), p.PositionHistory...)
+	clone.undoLog = append([]positionUndo(nil), p.undoLog...)
+	return &clone
+}
+
+// GameState returns the position as a GameState, with the keys of
+// PositionHistory but none of the moves that led to it.
+func (p *Position) GameState() *GameState {
+	white, black := p.KingSquare(White), p.KingSquare(Black)
+	gs := &GameState{
+		Board:           p.BoardState(),
+		WhiteToMove:     p.WhiteToMove,
+		WhiteKingSquare: Square{white / 8, white % 8},
+		BlackKingSquare: Square{black / 8, black % 8},
+		EnPassantSquare: p.EnPassantSquare,
+		CastleRights:    p.CastleRights,
+		HalfmoveClock:   p.HalfmoveClock,
+		FullmoveNumber:  p.FullmoveNumber,
+		Hash:            p.Hash,
+		PositionHistory: append([]uint64(nil), p.PositionHistory...),
+		MoveLog:         []Move{},
+		UndoLog:         []UndoRecord{},
+	}
+	gs.StartFEN = gs.FEN()
+	return gs
+}
+
+func NewPositionFromFEN(fen string) (*Position, error) {
+	gs, err := NewGameStateFromFEN(fen)
+	if err != nil {
+		return nil, err
+	}
+	return NewPosition(gs), nil
+}
+
+// BoardState returns the position as a GameState board.
+func (p *Position) BoardState() BoardState {
+	var board BoardState
+	for sq, piece := range p.board {
+		if piece == noPiece {
+			board[sq/8][sq%8] = "--"
+		} else {
+			board[sq/8][sq%8] = pieceCodes[piece]
+		}
+	}
+	return board
+}
+
+func (p *Position) FEN() string {
+	gs := &GameState{
+		Board:           p.BoardState(),
+		WhiteToMove:     p.WhiteToMove,
+		CastleRights:    p.CastleRights,
+		EnPassantSquare: p.EnPassantSquare,
+		HalfmoveClock:   p.HalfmoveClock,
+		FullmoveNumber:  p.FullmoveNumber,
+	}
+	return gs.FEN()
+}
+
+// PieceAt returns the name of the piece on a square, or "--" if it is empty.
+func (p *Position) PieceAt(sq int) string {
+	if p.board[sq] == noPiece {
+		return "--"
+	}
+	return pieceCodes[p.board[sq]]
+}
+
+func (p *Position) Occupied() Bitboard {
+	return p.Colors[White] | p.Colors[Black]
+}
+
+func (p *Position) sideToMove() int {
+	if p.WhiteToMove {
+		return White
+	}
+	return Black
+}
+
+func (p *Position) putPiece(sq int, piece int) {
+	bit := Bitboard(1) << uint(sq)
+	p.Pieces[piece/6][piece%6] |= bit
+	p.Colors[piece/6] |= bit
+	p.board[sq] = piece
+	p.Hash ^= squarePieceKey(piece, sq)
+}
+
+func (p *Position) removePiece(sq int) {
+	piece := p.board[sq]
+	bit := Bitboard(1) << uint(sq)
+	p.Pieces[piece/6][piece%6] &^= bit
+	p.Colors[piece/6] &^= bit
+	p.board[sq] = noPiece
+	p.Hash ^= squarePieceKey(piece, sq)
+}
+
+func (p *Position) movePiece(from int, to int) {
+	piece := p.board[from]
+	p.removePiece(from)
+	p.putPiece(to, piece)
+}
+
+func (p *Position) KingSquare(color int) int {
+	return p.Pieces[color][King].First()
+}
+
+// AttackersOf returns the pieces of the given colour that attack sq.
+func (p *Position) AttackersOf(sq int, color int, occupied Bitboard) Bitboard {
+	pieces := &p.Pieces[color]
+	return PawnAttacks(1-color, sq)&pieces[Pawn] |
+		KnightAttacks(sq)&pieces[Knight] |
+		KingAttacks(sq)&pieces[King] |
+		BishopAttacks(sq, occupied)&(pieces[Bishop]|pieces[Queen]) |
+		RookAttacks(sq, occupied)&(pieces[Rook]|pieces[Queen])
+}
+
+// SquareAttacked reports whether the player who is not to move attacks sq.
+func (p *Position) SquareAttacked(sq int) bool {
+	return p.AttackersOf(sq, 1-p.sideToMove(), p.Occupied()) != 0
+}
+
+func (p *Position) InCheck() bool {
+	return p.SquareAttacked(p.KingSquare(p.sideToMove()))
+}
+
+func (p *Position) newMove(from int, to int) Move {
+	start, end := Square{from / 8, from % 8}, Square{to / 8, to % 8}
+	move := Move{
+		StartRow:      start.Row,
+		StartCol:      start.Col,
+		EndRow:        end.Row,
+		EndCol:        end.Col,
+		PieceMoved:    pieceCodes[p.board[from]],
+		PieceCaptured: p.PieceAt(to),
+		MoveId:        getMoveId(start, end, ""),
+	}
+	return move
+}
+
+func (p *Position) addPawnMoves(moves []Move, from int, to int) []Move {
+	if to/8 != 0 && to/8 != 7 {
+		return append(moves, p.newMove(from, to))
+	}
+	for i := 0; i < len(PromotionPieceTypes); i++ {
+		move := p.newMove(from, to)
+		move.IsPawnPromotion = true
+		move.PromotionPiece = move.PieceMoved[:1] + string(PromotionPieceTypes[i])
+		move.MoveId = getMoveId(Square{move.StartRow, move.StartCol}, Square{move.EndRow, move.EndCol}, move.PromotionPiece)
+		moves = append(moves, move)
+	}
+	return moves
+}
+
+// pseudoLegalMoves returns every move that follows the movement rules of the
+// pieces, including ones that leave the king in check. Castling moves are only
+// generated when they are fully legal.
+func (p *Position) pseudoLegalMoves() []Move {
+	moves := make([]Move, 0, 48)
+	us := p.sideToMove()
+	them := 1 - us
+	occupied := p.Occupied()
+	empty := ^occupied
+	targets := ^p.Colors[us]
+
+	pawns := p.Pieces[us][Pawn]
+	forward, startRow := -8, 6
+	if us == Black {
+		forward, startRow = 8, 1
+	}
+	epBit := Bitboard(0)
+	if p.EnPassantSquare != GetNullSquare() {
+		epBit = SquareBit(p.EnPassantSquare.Row, p.EnPassantSquare.Col)
+	}
+	for pawns != 0 {
+		from := pawns.PopFirst()
+		to := from + forward
+		if empty.Has(to) {
+			moves = p.addPawnMoves(moves, from, to)
+			if from/8 == startRow && empty.Has(to+forward) {
+				moves = append(moves, p.newMove(from, to+forward))
+			}
+		}
+		captures := PawnAttacks(us, from) & p.Colors[them]
+		for captures != 0 {
+			moves = p.addPawnMoves(moves, from, captures.PopFirst())
+		}
+		if PawnAttacks(us, from)&epBit != 0 {
+			move := p.newMove(from, epBit.First())
+			move.IsEnPassant = true
+			moves = append(moves, move)
+		}
+	}
+
+	for pieceType := Knight; pieceType <= King; pieceType++ {
+		pieces := p.Pieces[us][pieceType]
+		for pieces != 0 {
+			from := pieces.PopFirst()
+			var attacks Bitboard
+			switch pieceType {
+			case Knight:
+				attacks = KnightAttacks(from)
+			case Bishop:
+				attacks = BishopAttacks(from, occupied)
+			case Rook:
+				attacks = RookAttacks(from, occupied)
+			case Queen:
+				attacks = QueenAttacks(from, occupied)
+			case King:
+				attacks = KingAttacks(from)
+			}
+			attacks &= targets
+			for attacks != 0 {
+				moves = append(moves, p.newMove(from, attacks.PopFirst()))
+			}
+		}
+	}
+
+	return append(moves, p.castleMoves()...)
+}
+
+func (p *Position) castleMoves() []Move {
+	moves := []Move{}
+	row, kingSide, queenSide := 7, p.CastleRights.WKS, p.CastleRights.WQS
+	if !p.WhiteToMove {
+		row, kingSide, queenSide = 0, p.CastleRights.BKS, p.CastleRights.BQS
+	}
+	king := row*8 + 4
+	if !kingSide && !queenSide || p.SquareAttacked(king) {
+		return moves
+	}
+	occupied := p.Occupied()
+	if kingSide && !occupied.Has(king+1) && !occupied.Has(king+2) && !p.SquareAttacked(king+1) && !p.SquareAttacked(king+2) {
+		move := p.newMove(king, king+2)
+		move.IsCastleMove = true
+		moves = append(moves, move)
+	}
+	if queenSide && !occupied.Has(king-1) && !occupied.Has(king-2) && !occupied.Has(king-3) && !p.SquareAttacked(king-1) && !p.SquareAttacked(king-2) {
+		move := p.newMove(king, king-2)
+		move.IsCastleMove = true
+		moves = append(moves, move)
+	}
+	return moves
+}
+
+// GetValidMoves returns the legal moves and, like GameState.GetValidMoves,
+// sets Checkmate or Stalemate when there are none.
+func (p *Position) GetValidMoves() []Move {
+	us := p.sideToMove()
+	moves := p.pseudoLegalMoves()
+	legal := moves[:0]
+	for _, move := range moves {
+		p.MakeMove(move)
+		if p.AttackersOf(p.KingSquare(us), 1-us, p.Occupied()) == 0 {
+			legal = append(legal, move)
+		}
+		p.UndoMove()
+	}
+
+	p.Checkmate, p.Stalemate = false, false
+	if len(legal) == 0 {
+		if p.InCheck() {
+			p.Checkmate = true
+		} else {
+			p.Stalemate = true
+		}
+	}
+	return legal
+}
+
+func (p *Position) MakeMove(move Move) {
+	from := move.StartRow*8 + move.StartCol
+	to := move.EndRow*8 + move.EndCol
+
+	p.undoLog = append(p.undoLog, positionUndo{p.CastleRights, p.EnPassantSquare, p.HalfmoveClock, p.Hash})
+	p.MoveLog = append(p.MoveLog, move)
+	p.Hash ^= p.stateKey()
+
+	if move.IsEnPassant {
+		p.removePiece(move.StartRow*8 + move.EndCol)
+	} else if p.board[to] != noPiece {
+		p.removePiece(to)
+	}
+	p.movePiece(from, to)
+
+	if move.IsPawnPromotion {
+		p.removePiece(to)
+		p.putPiece(to, pieceIndex(move.PromotionPiece))
+	}
+
+	if move.IsCastleMove {
+		if to > from {
+			p.movePiece(to+1, to-1)
+		} else {
+			p.movePiece(to-2, to+1)
+		}
+	}
+
+	p.updateCastleRights(from)
+	p.updateCastleRights(to)
+
+	p.EnPassantSquare = GetNullSquare()
+	if move.PieceMoved[1] == 'p' && (to-from == 16 || from-to == 16) {
+		p.EnPassantSquare = Square{(move.StartRow + move.EndRow) / 2, move.StartCol}
+	}
+
+	if move.PieceMoved[1] == 'p' || move.PieceCaptured != "--" {
+		p.HalfmoveClock = 0
+	} else {
+		p.HalfmoveClock++
+	}
+	if !p.WhiteToMove {
+		p.FullmoveNumber++
+	}
+	p.WhiteToMove = !p.WhiteToMove
+	p.Hash ^= polyglotRandom[polyglotTurnOffset] ^ p.stateKey()
+	p.PositionHistory = append(p.PositionHistory, p.Hash)
+}
+
+// updateCastleRights removes the castling rights that depend on a king or rook
+// still standing on sq, after a move from or to it.
+func (p *Position) updateCastleRights(sq int) {
+	switch sq {
+	case 60:
+		p.CastleRights.WKS, p.CastleRights.WQS = false, false
+	case 63:
+		p.CastleRights.WKS = false
+	case 56:
+		p.CastleRights.WQS = false
+	case 4:
+		p.CastleRights.BKS, p.CastleRights.BQS = false, false
+	case 7:
+		p.CastleRights.BKS = false
+	case 0:
+		p.CastleRights.BQS = false
+	}
+}
+
+func (p *Position) UndoMove() {
+	if len(p.MoveLog) == 0 {
+		return
+	}
+	move := p.MoveLog[len(p.MoveLog)-1]
+	undo := p.undoLog[len(p.undoLog)-1]
+	p.MoveLog = p.MoveLog[:len(p.MoveLog)-1]
+	p.undoLog = p.undoLog[:len(p.undoLog)-1]
+
+	from := move.StartRow*8 + move.StartCol
+	to := move.EndRow*8 + move.EndCol
+
+	p.WhiteToMove = !p.WhiteToMove
+	if !p.WhiteToMove {
+		p.FullmoveNumber--
+	}
+	p.CastleRights = undo.castleRights
+	p.EnPassantSquare = undo.enPassantSquare
+	p.HalfmoveClock = undo.halfmoveClock
+
+	if move.IsCastleMove {
+		if to > from {
+			p.movePiece(to-1, to+1)
+		} else {
+			p.movePiece(to+1, to-2)
+		}
+	}
+
+	if move.IsPawnPromotion {
+		p.removePiece(to)
+		p.putPiece(to, pieceIndex(move.PieceMoved))
+	}
+	p.movePiece(to, from)
+
+	if move.IsEnPassant {
+		capturedPawn := "bp"
+		if move.PieceMoved[0] == 'b' {
+			capturedPawn = "wp"
+		}
+		p.putPiece(move.StartRow*8+move.EndCol, pieceIndex(capturedPawn))
+	} else if move.PieceCaptured != "--" {
+		p.putPiece(to, pieceIndex(move.PieceCaptured))
+	}
+	p.Hash = undo.hash
+	p.PositionHistory = p.PositionHistory[:len(p.PositionHistory)-1]
+}
+
+// MakeNullMove passes the turn without moving a piece, as
+// GameState.MakeNullMove does, and must be taken back with UndoNullMove.
+func (p *Position) MakeNullMove() {
+	p.undoLog = append(p.undoLog, positionUndo{p.CastleRights, p.EnPassantSquare, p.HalfmoveClock, p.Hash})
+	p.Hash ^= p.stateKey()
+	p.EnPassantSquare = GetNullSquare()
+	p.WhiteToMove = !p.WhiteToMove
+	p.Hash ^= polyglotRandom[polyglotTurnOffset] ^ p.stateKey()
+}
+
+// UndoNullMove takes back MakeNullMove.
+func (p *Position) UndoNullMove() {
+	undo := p.undoLog[len(p.undoLog)-1]
+	p.undoLog = p.undoLog[:len(p.undoLog)-1]
+	p.EnPassantSquare = undo.enPassantSquare
+	p.Hash = undo.hash
+	p.WhiteToMove = !p.WhiteToMove
+}
+
+// RepetitionCount returns how many times the current position has occurred,
+// counting this occurrence. Only the positions since the last capture or pawn
+// move are looked at, as no earlier one can be the same.
+func (p *Position) RepetitionCount() int {
+	count := 0
+	for i := len(p.PositionHistory) - 1; i >= 0 && i >= len(p.PositionHistory)-1-p.HalfmoveClock; i-- {
+		if p.PositionHistory[i] == p.Hash {
+			count++
+		}
+	}
+	if count == 0 {
+		// After a null move the position is not in the history.
+		count = 1
+	}
+	return count
+}
+
+// PieceCount returns the number of pieces on the board, kings and pawns
+// included.
+func (p *Position) PieceCount() int {
+	return p.Occupied().Count()
+}
+
+// Perft counts the positions reached after every sequence of depth legal moves.
+func (p *Position) Perft(depth int) int {
+	if depth == 0 {
+		return 1
+	}
+	moves := p.GetValidMoves()
+	if depth == 1 {
+		return len(moves)
+	}
+	nodes := 0
+	for _, move := range moves {
+		p.MakeMove(move)
+		nodes += p.Perft(depth - 1)
+		p.UndoMove()
+	}
+	return nodes
+}
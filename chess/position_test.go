@@ -0,0 +1,114 @@
+package chess
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestPositionPerft(t *testing.T) {
+	for _, position := range perftPositions {
+		t.Run(position.name, func(t *testing.T) {
+			p, err := NewPositionFromFEN(position.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, want := range position.counts {
+				if testing.Short() && want > perftShortLimit {
+					break
+				}
+				depth := i + 1
+				if got := p.Perft(depth); got != want {
+					t.Errorf("perft(%d) = %d, want %d", depth, got, want)
+				}
+			}
+			if fen := p.FEN(); fen != position.fen {
+				t.Errorf("position after perft = %q, want %q", fen, position.fen)
+			}
+		})
+	}
+}
+
+func TestPositionNullMove(t *testing.T) {
+	gs, _ := NewGameStateFromFEN("rnbqkbnr/ppp1pppp/8/2Pp4/8/8/PP1PPPPP/RNBQKBNR w KQkq d6 0 2")
+	p := NewPosition(gs)
+	before := p.Clone()
+	p.MakeNullMove()
+	gs.MakeNullMove()
+	if p.WhiteToMove || p.EnPassantSquare != GetNullSquare() || p.Hash != gs.Hash || p.Hash != p.ComputeHash() {
+		t.Fatalf("after a null move: %s, hash %x, want %x", p.FEN(), p.Hash, gs.Hash)
+	}
+	p.UndoNullMove()
+	if p.FEN() != before.FEN() || p.Hash != before.Hash {
+		t.Errorf("UndoNullMove left %s", p.FEN())
+	}
+}
+
+func TestPositionRepetition(t *testing.T) {
+	gs := NewGameState()
+	p := NewPosition(gs)
+	for _, uci := range []string{"g1f3", "g8f6", "f3g1", "f6g8", "g1f3"} {
+		for _, move := range p.GetValidMoves() {
+			if move.GetUCINotation() == uci {
+				p.MakeMove(move)
+			}
+		}
+	}
+	// Converting back and forth keeps the history.
+	p = NewPosition(p.GameState())
+	if got := p.RepetitionCount(); got != 2 {
+		t.Errorf("Nf3 position occurred %d times, want 2", got)
+	}
+	if gs := p.GameState(); gs.RepetitionCount() != 2 || gs.FEN() != p.FEN() || gs.Hash != p.Hash {
+		t.Errorf("GameState() gives %s, repeated %d times", gs.FEN(), gs.RepetitionCount())
+	}
+}
+
+func sortedMoveIds(moves []Move) []int {
+	ids := []int{}
+	for _, move := range moves {
+		ids = append(ids, move.MoveId)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// TestPositionMatchesGameState plays random games with both representations
+// side by side and checks they agree on every legal move list.
+func TestPositionMatchesGameState(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, position := range perftPositions {
+		for game := 0; game < 20; game++ {
+			gs, _ := NewGameStateFromFEN(position.fen)
+			p := NewPosition(gs)
+			for ply := 0; ply < 80; ply++ {
+				gsMoves := gs.GetValidMoves()
+				pMoves := p.GetValidMoves()
+				gsIds, pIds := sortedMoveIds(gsMoves), sortedMoveIds(pMoves)
+				if len(gsIds) != len(pIds) {
+					t.Fatalf("%s: GameState has %d moves, Position has %d", gs.FEN(), len(gsIds), len(pIds))
+				}
+				for i := range gsIds {
+					if gsIds[i] != pIds[i] {
+						t.Fatalf("%s: move lists differ: %v and %v", gs.FEN(), gsIds, pIds)
+					}
+				}
+				if p.Checkmate != gs.Checkmate || p.Stalemate != gs.Stalemate {
+					t.Fatalf("%s: end of game differs", gs.FEN())
+				}
+				if len(gsMoves) == 0 {
+					break
+				}
+				move := gsMoves[rng.Intn(len(gsMoves))]
+				gs.MakeMove(move)
+				p.MakeMove(move)
+				if gs.FEN() != p.FEN() {
+					t.Fatalf("after %s: GameState is %q, Position is %q", move.GetUCINotation(), gs.FEN(), p.FEN())
+				}
+				if p.Hash != gs.Hash || p.Hash != p.ComputeHash() || p.RepetitionCount() != gs.RepetitionCount() {
+					t.Fatalf("after %s: hash %x, want %x", move.GetUCINotation(), p.Hash, gs.Hash)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package chess
+
+import "strings"
+
+// GetSAN returns the Standard Algebraic Notation for a move, which must be
+// legal in the current position. Disambiguation is worked out against the
+// other legal moves and the check and mate suffixes by playing the move.
+func (gs *GameState) GetSAN(move Move) string {
+	var sb strings.Builder
+
+	if move.IsCastleMove {
+		if move.EndCol-move.StartCol == 2 {
+			sb.WriteString("O-O")
+		} else {
+			sb.WriteString("O-O-O")
+		}
+	} else {
+		pieceType := move.PieceMoved[1]
+
+		if pieceType == 'p' {
+			if isCapture(move) {
+				sb.WriteByte(byte('a' + move.StartCol))
+			}
+		} else {
+			sb.WriteByte(pieceType)
+			sb.WriteString(gs.disambiguation(move))
+		}
+
+		if isCapture(move) {
+			sb.WriteByte('x')
+		}
+		sb.WriteString(SquareNotation(Square{move.EndRow, move.EndCol}))
+
+		if move.IsPawnPromotion {
+			sb.WriteString("=" + move.PromotionPiece[1:])
+		}
+	}
+
+	gs.MakeMove(move)
+	gs.GetValidMoves()
+	if gs.Checkmate {
+		sb.WriteByte('#')
+	} else if gs.CurrentPlayerInCheck {
+		sb.WriteByte('+')
+	}
+	gs.UndoMove()
+
+	return sb.String()
+}
+
+// disambiguation returns the file, rank or square needed to tell a move apart
+// from other legal moves of the same kind of piece to the same square.
+func (gs *GameState) disambiguation(move Move) string {
+	saved := gs.saveScratchState()
+	moves := gs.GetValidMoves()
+	gs.restoreScratchState(saved)
+
+	ambiguous, sameFile, sameRank := false, false, false
+	for _, other := range moves {
+		if other.PieceMoved != move.PieceMoved || other.EndRow != move.EndRow || other.EndCol != move.EndCol {
+			continue
+		}
+		if other.StartRow == move.StartRow && other.StartCol == move.StartCol {
+			continue
+		}
+		ambiguous = true
+		if other.StartCol == move.StartCol {
+			sameFile = true
+		}
+		if other.StartRow == move.StartRow {
+			sameRank = true
+		}
+	}
+
+	start := SquareNotation(Square{move.StartRow, move.StartCol})
+	switch {
+	case !ambiguous:
+		return ""
+	case !sameFile:
+		return start[:1]
+	case !sameRank:
+		return start[1:]
+	default:
+		return start
+	}
+}
+
+// scratchState holds the fields that GetValidMoves and MakeMove overwrite, so
+// that a move can be tried out without disturbing the position.
+type scratchState struct {
+	currentPlayerInCheck bool
+	checkmate            bool
+	stalemate            bool
+	pins                 []AttactedSquare
+	checks               []AttactedSquare
+	enPassantSquare      Square
+	validMoves           []Move
+}
+
+func (gs *GameState) saveScratchState() scratchState {
+	return scratchState{
+		currentPlayerInCheck: gs.CurrentPlayerInCheck,
+		checkmate:            gs.Checkmate,
+		stalemate:            gs.Stalemate,
+		pins:                 gs.Pins,
+		checks:               gs.Checks,
+		enPassantSquare:      gs.EnPassantSquare,
+		validMoves:           gs.ValidMoves,
+	}
+}
+
+func (gs *GameState) restoreScratchState(s scratchState) {
+	gs.CurrentPlayerInCheck = s.currentPlayerInCheck
+	gs.Checkmate = s.checkmate
+	gs.Stalemate = s.stalemate
+	gs.Pins = s.pins
+	gs.Checks = s.checks
+	gs.EnPassantSquare = s.enPassantSquare
+	gs.ValidMoves = s.validMoves
+}
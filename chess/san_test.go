@@ -0,0 +1,61 @@
+package chess
+
+import "testing"
+
+// findMove returns the legal move in gs written in UCI notation.
+func findMove(t *testing.T, gs *GameState, uci string) Move {
+	t.Helper()
+	for _, move := range gs.GetValidMoves() {
+		if move.GetUCINotation() == uci {
+			return move
+		}
+	}
+	t.Fatalf("%s: %s is not a legal move", gs.FEN(), uci)
+	return Move{}
+}
+
+func TestGetSAN(t *testing.T) {
+	tests := []struct {
+		fen  string
+		move string
+		want string
+	}{
+		{StartingPositionFEN, "g1f3", "Nf3"},
+		{StartingPositionFEN, "e2e4", "e4"},
+		{"4k3/8/8/3p4/4P3/8/8/4K3 w - - 0 1", "e4d5", "exd5"},
+		{"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "e5d6", "exd6"},
+		// Two rooks on a rank tell apart by file, two on a file by rank.
+		{"4k3/8/8/8/8/8/4K3/R6R w - - 0 1", "a1d1", "Rad1"},
+		{"4k3/8/8/8/8/8/4K3/R6R w - - 0 1", "h1d1", "Rhd1"},
+		{"4k3/8/8/R7/8/8/4K3/R7 w - - 0 1", "a1a3", "R1a3"},
+		{"4k3/8/8/R7/8/8/4K3/R7 w - - 0 1", "a5a3", "R5a3"},
+		// Three queens reach b2: each needs as little as tells it apart.
+		{"6k1/8/8/8/8/Q1Q5/8/Q6K w - - 0 1", "c3b2", "Qcb2"},
+		{"6k1/8/8/8/8/Q1Q5/8/Q6K w - - 0 1", "a1b2", "Q1b2"},
+		{"6k1/8/8/8/8/Q1Q5/8/Q6K w - - 0 1", "a3b2", "Qa3b2"},
+		// A pinned knight cannot reach the square, so the other needs no
+		// disambiguation.
+		{"4k3/8/8/b7/8/8/3N4/4K1N1 w - - 0 1", "g1f3", "Nf3"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "e1g1", "O-O"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "e8c8", "O-O-O"},
+		{"4k3/P7/8/8/8/8/8/4K3 w - - 0 1", "a7a8q", "a8=Q+"},
+		{"4k3/P7/8/8/8/8/8/4K3 w - - 0 1", "a7a8n", "a8=N"},
+		{"1n2k3/P7/8/8/8/8/8/4K3 w - - 0 1", "a7b8r", "axb8=R+"},
+		{"4k3/8/8/8/8/8/8/R3K3 w - - 0 1", "a1a8", "Ra8+"},
+		{"6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1", "a1a8", "Ra8#"},
+		{"8/8/8/8/8/6k1/r7/6K1 b - - 0 1", "a2a1", "Ra1#"},
+	}
+	for _, test := range tests {
+		gs, err := NewGameStateFromFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		before := gs.FEN()
+		if got := gs.GetSAN(findMove(t, gs, test.move)); got != test.want {
+			t.Errorf("%s %s: GetSAN = %q, want %q", test.fen, test.move, got, test.want)
+		}
+		if gs.FEN() != before {
+			t.Errorf("%s: GetSAN changed the position to %s", test.fen, gs.FEN())
+		}
+	}
+}
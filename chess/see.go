@@ -0,0 +1,69 @@
+package chess
+
+// SEEPieceValues are the values in centipawns that SEE counts material in,
+// indexed by piece type.
+var SEEPieceValues = [6]int{Pawn: 100, Knight: 320, Bishop: 330, Rook: 500, Queen: 900, King: 20000}
+
+// SEE is the static exchange evaluation of move: the material the side to
+// move ends up winning, in centipawns, if it plays move and both sides then
+// keep recapturing on the same square with their least valuable piece for as
+// long as that pays. A negative result means the capture loses material. It
+// looks at nothing but the one square, so it ignores pins and checks
+// elsewhere on the board, but it sees pieces lined up behind each other.
+func (gs *GameState) SEE(move Move) int {
+	return NewPosition(gs).SEE(move)
+}
+
+// SEE is the static exchange evaluation of move; see GameState.SEE.
+func (p *Position) SEE(move Move) int {
+	from := move.StartRow*8 + move.StartCol
+	to := move.EndRow*8 + move.EndCol
+	occupied := p.Occupied() &^ (Bitboard(1) << uint(from))
+
+	var gain [32]int
+	if move.IsEnPassant {
+		gain[0] = SEEPieceValues[Pawn]
+		occupied &^= Bitboard(1) << uint(move.StartRow*8+move.EndCol)
+	} else if move.PieceCaptured != "--" {
+		gain[0] = SEEPieceValues[pieceIndex(move.PieceCaptured)%6]
+	}
+	onSquare := SEEPieceValues[pieceIndex(move.PieceMoved)%6]
+	if move.IsPawnPromotion {
+		onSquare = SEEPieceValues[pieceIndex(move.PromotionPiece)%6]
+		gain[0] += onSquare - SEEPieceValues[Pawn]
+	}
+
+	side := pieceIndex(move.PieceMoved)/6 ^ 1
+	d := 0
+	for d < len(gain)-1 {
+		attackers := p.AttackersOf(to, side, occupied) & occupied
+		if attackers == 0 {
+			break
+		}
+		piece, sq := Pawn, 0
+		for ; piece <= King; piece++ {
+			if mine := attackers & p.Pieces[side][piece]; mine != 0 {
+				sq = mine.First()
+				break
+			}
+		}
+		// The king may only take last, when nothing can take it back.
+		if piece == King && p.AttackersOf(to, side^1, occupied)&occupied != 0 {
+			break
+		}
+
+		d++
+		gain[d] = onSquare - gain[d-1]
+		onSquare = SEEPieceValues[piece]
+		occupied &^= Bitboard(1) << uint(sq)
+		side ^= 1
+	}
+
+	// Either side may stop recapturing when going on would cost it.
+	for ; d > 0; d-- {
+		if gain[d] > -gain[d-1] {
+			gain[d-1] = -gain[d]
+		}
+	}
+	return gain[0]
+}
@@ -0,0 +1,41 @@
+package chess
+
+import "testing"
+
+func TestSEE(t *testing.T) {
+	tests := []struct {
+		fen  string
+		move string
+		want int
+	}{
+		// An undefended pawn.
+		{"1k1r4/1pp4p/p7/4p3/8/P5P1/1PP4P/2K1R3 w - - 0 1", "Rxe5", 100},
+		// A pawn defended by a pawn, taken by a rook.
+		{"1k6/8/3p4/4p3/8/8/8/1K2R3 w - - 0 1", "Rxe5", 100 - 500},
+		// A knight for a pawn.
+		{"1k6/8/3p4/4n3/3P4/8/8/1K6 w - - 0 1", "dxe5", 320 - 100},
+		// Both sides' pieces stacked on the d-file take in turn.
+		{"1k1r3q/1ppn3p/p4b2/4p3/8/P2N2P1/1PP1R1BP/2K1Q3 w - - 0 1", "Nxe5", 100 - 320},
+		// The queen behind the rook makes taking twice pay.
+		{"1k6/8/8/3p4/8/8/3R4/1K1Q4 w - - 0 1", "Rxd5", 100},
+		// The king may only take back a piece nothing else defends.
+		{"8/8/2k5/3p4/8/8/8/3RK3 w - - 0 1", "Rxd5", 100 - 500},
+		{"8/8/2k5/3p4/8/5B2/8/3RK3 w - - 0 1", "Rxd5", 100},
+		// Promoting while taking.
+		{"1n2k3/P7/8/8/8/8/8/4K3 w - - 0 1", "axb8=Q", 320 + 800},
+		{"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "exd6", 100},
+	}
+	for _, test := range tests {
+		gs, err := NewGameStateFromFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		move, err := gs.ParseMove(test.move)
+		if err != nil {
+			t.Fatalf("%s: %v", test.fen, err)
+		}
+		if got := gs.SEE(move); got != test.want {
+			t.Errorf("%s %s: SEE = %d, want %d", test.fen, test.move, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package chess
+
+import "fmt"
+
+type Square struct {
+	Row int
+	Col int
+}
+
+// SquareNotation returns the algebraic name of a square, e.g. "e4".
+func SquareNotation(square Square) string {
+	return string(rune('a'+square.Col)) + string(rune('8'-square.Row))
+}
+
+// SquareFromNotation parses an algebraic square name such as "e4".
+func SquareFromNotation(notation string) (Square, error) {
+	if len(notation) != 2 || notation[0] < 'a' || notation[0] > 'h' || notation[1] < '1' || notation[1] > '8' {
+		return GetNullSquare(), fmt.Errorf("%q is not a square", notation)
+	}
+	return Square{Row: int('8' - notation[1]), Col: int(notation[0] - 'a')}, nil
+}
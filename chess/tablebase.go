@@ -0,0 +1,89 @@
+package chess
+
+// WDL is what an endgame tablebase knows about a position: whether the side
+// to move wins, draws or loses with perfect play.
+type WDL int
+
+const (
+	WDLLoss WDL = -2
+	// WDLBlessedLoss is a loss the fifty-move rule turns into a draw.
+	WDLBlessedLoss WDL = -1
+	WDLDraw        WDL = 0
+	// WDLCursedWin is a win the fifty-move rule turns into a draw.
+	WDLCursedWin WDL = 1
+	WDLWin       WDL = 2
+)
+
+func (w WDL) String() string {
+	switch w {
+	case WDLLoss:
+		return "loss"
+	case WDLBlessedLoss:
+		return "blessed loss"
+	case WDLCursedWin:
+		return "cursed win"
+	case WDLWin:
+		return "win"
+	default:
+		return "draw"
+	}
+}
+
+// Tablebase is a source of perfect play for positions with few pieces left.
+type Tablebase interface {
+	// MaxPieces is the most pieces, kings included, a position may have for
+	// the tablebase to know it.
+	MaxPieces() int
+	// ProbeWDL returns the result for the side to move, as if the halfmove
+	// clock had just been reset. ok is false if the tablebase does not know
+	// the position.
+	ProbeWDL(gs *GameState) (wdl WDL, ok bool)
+	// ProbeDistance returns how many plies it takes the winning side to
+	// win, or 0 for a draw. Depending on the tablebase that is the distance
+	// to mate, or to the capture or pawn move that reaches a won position in
+	// a smaller table.
+	ProbeDistance(gs *GameState) (plies int, ok bool)
+}
+
+// PieceCount returns the number of pieces on the board, kings and pawns
+// included.
+func (gs *GameState) PieceCount() int {
+	count := 0
+	for r := 0; r < 8; r++ {
+		for c := 0; c < 8; c++ {
+			if gs.Board[r][c] != "--" {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// tablebaseOutcome adjudicates the game from gs.Tablebase, if it knows the
+// position.
+func (gs *GameState) tablebaseOutcome() (Outcome, bool) {
+	if gs.Tablebase == nil || gs.PieceCount() > gs.Tablebase.MaxPieces() {
+		return Outcome{}, false
+	}
+	wdl, ok := gs.Tablebase.ProbeWDL(gs)
+	if !ok {
+		return Outcome{}, false
+	}
+	// The distance is left at 0 if the tablebase only has results.
+	distance, known := gs.Tablebase.ProbeDistance(gs)
+	if (wdl == WDLWin || wdl == WDLLoss) && gs.HalfmoveClock != 0 && !known {
+		// ProbeWDL ignores the halfmove clock, so without a distance there is
+		// no telling whether the fifty-move rule comes first.
+		return Outcome{}, false
+	}
+	outcome := Outcome{Result: Draw, Reason: TablebaseResult, Distance: distance}
+	switch {
+	case gs.HalfmoveClock+distance > 100:
+		// The fifty-move rule draws the game before the win comes through.
+	case wdl == WDLWin && gs.WhiteToMove, wdl == WDLLoss && !gs.WhiteToMove:
+		outcome.Result = WhiteWins
+	case wdl == WDLLoss && gs.WhiteToMove, wdl == WDLWin && !gs.WhiteToMove:
+		outcome.Result = BlackWins
+	}
+	return outcome, true
+}
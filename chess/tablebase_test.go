@@ -0,0 +1,65 @@
+package chess
+
+import "testing"
+
+// fixedTablebase knows every position with up to three pieces, and says the
+// side to move has result wdl in distance plies.
+type fixedTablebase struct {
+	wdl      WDL
+	distance int
+	// noDistance makes ProbeDistance fail, as it does for a tablebase with
+	// only results.
+	noDistance bool
+}
+
+func (tb fixedTablebase) MaxPieces() int { return 3 }
+
+func (tb fixedTablebase) ProbeWDL(gs *GameState) (WDL, bool) { return tb.wdl, true }
+
+func (tb fixedTablebase) ProbeDistance(gs *GameState) (int, bool) {
+	if tb.noDistance {
+		return 0, false
+	}
+	return tb.distance, true
+}
+
+func TestTablebaseOutcome(t *testing.T) {
+	tests := []struct {
+		fen  string
+		tb   fixedTablebase
+		want Outcome
+	}{
+		{"4k3/8/8/8/8/8/8/3QK3 w - - 0 1", fixedTablebase{wdl: WDLWin, distance: 19}, Outcome{Result: WhiteWins, Reason: TablebaseResult, Distance: 19}},
+		{"4k3/8/8/8/8/8/8/3QK3 b - - 0 1", fixedTablebase{wdl: WDLLoss, distance: 18}, Outcome{Result: WhiteWins, Reason: TablebaseResult, Distance: 18}},
+		{"4k3/8/8/8/8/8/8/3qK3 w - - 0 1", fixedTablebase{wdl: WDLLoss, distance: 20}, Outcome{Result: BlackWins, Reason: TablebaseResult, Distance: 20}},
+		{"4k3/8/8/8/8/8/8/3QK3 w - - 0 1", fixedTablebase{wdl: WDLCursedWin, distance: 120}, Outcome{Result: Draw, Reason: TablebaseResult, Distance: 120}},
+		// The fifty-move rule comes before mate.
+		{"4k3/8/8/8/8/8/8/3QK3 w - - 82 80", fixedTablebase{wdl: WDLWin, distance: 19}, Outcome{Result: Draw, Reason: TablebaseResult, Distance: 19}},
+		{"4k3/8/8/8/8/8/8/3qK3 w - - 81 80", fixedTablebase{wdl: WDLLoss, distance: 20}, Outcome{Result: Draw, Reason: TablebaseResult, Distance: 20}},
+		{"4k3/8/8/8/8/8/8/3QK3 w - - 81 80", fixedTablebase{wdl: WDLWin, distance: 19}, Outcome{Result: WhiteWins, Reason: TablebaseResult, Distance: 19}},
+		// Without a distance a win is only known to come through from a
+		// reset halfmove clock.
+		{"4k3/8/8/8/8/8/8/3QK3 w - - 0 1", fixedTablebase{wdl: WDLWin, noDistance: true}, Outcome{Result: WhiteWins, Reason: TablebaseResult}},
+		{"4k3/8/8/8/8/8/8/3QK3 w - - 10 20", fixedTablebase{wdl: WDLWin, noDistance: true}, Outcome{}},
+		// Too many pieces for the tablebase.
+		{"4k3/8/8/8/8/8/8/2RQK3 w - - 0 1", fixedTablebase{wdl: WDLWin, distance: 5}, Outcome{}},
+		// A game that is over is not adjudicated.
+		{"4k3/4Q3/4K3/8/8/8/8/8 b - - 0 1", fixedTablebase{wdl: WDLWin, distance: 5}, Outcome{Result: WhiteWins, Reason: Checkmate}},
+	}
+	for _, test := range tests {
+		gs, err := NewGameStateFromFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gs.Tablebase = test.tb
+		if got := gs.Outcome(); got != test.want {
+			t.Errorf("%s: got %+v, want %+v", test.fen, got, test.want)
+		}
+	}
+}
+
+func TestPieceCount(t *testing.T) {
+	if got := NewGameState().PieceCount(); got != 32 {
+		t.Errorf("start position has %d pieces", got)
+	}
+}
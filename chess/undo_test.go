@@ -0,0 +1,136 @@
+package chess
+
+import (
+	"reflect"
+	"testing"
+)
+
+// checkUndo makes and undoes every move below gs and fails if UndoMove does
+// not return the game state to exactly what it was.
+func checkUndo(t *testing.T, gs *GameState, depth int) {
+	if depth == 0 {
+		return
+	}
+	for _, move := range gs.GetValidMoves() {
+		before := *gs
+		gs.MakeMove(move)
+		checkUndo(t, gs, depth-1)
+		gs.UndoMove()
+		if !reflect.DeepEqual(*gs, before) {
+			t.Fatalf("%s: undoing %s changed the game state", before.FEN(), move.GetUCINotation())
+		}
+	}
+}
+
+func TestUndoMoveRestoresEverything(t *testing.T) {
+	for _, position := range perftPositions {
+		t.Run(position.name, func(t *testing.T) {
+			gs, _ := NewGameStateFromFEN(position.fen)
+			gs.ValidMoves = gs.GetValidMoves()
+			checkUndo(t, gs, 2)
+		})
+	}
+}
+
+func TestUndoMoveRestoresCheckmate(t *testing.T) {
+	gs := NewGameState()
+	for _, text := range []string{"f3", "e5", "g4"} {
+		move, err := gs.ParseMove(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gs.MakeMove(move)
+	}
+	gs.ValidMoves = gs.GetValidMoves()
+	validMoves := len(gs.ValidMoves)
+
+	mate, _ := gs.ParseMove("Qh4#")
+	gs.MakeMove(mate)
+	gs.ValidMoves = gs.GetValidMoves()
+	if !gs.Checkmate {
+		t.Fatal("expected checkmate")
+	}
+
+	gs.UndoMove()
+	if gs.Checkmate || gs.CurrentPlayerInCheck {
+		t.Errorf("after UndoMove Checkmate = %v, CurrentPlayerInCheck = %v", gs.Checkmate, gs.CurrentPlayerInCheck)
+	}
+	if len(gs.ValidMoves) != validMoves {
+		t.Errorf("after UndoMove len(ValidMoves) = %d, want %d", len(gs.ValidMoves), validMoves)
+	}
+}
+
+func TestRedoStack(t *testing.T) {
+	gs := NewGameState()
+	play := func(text string) {
+		t.Helper()
+		move, err := gs.ParseMove(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gs.PlayMove(move)
+	}
+	for _, text := range []string{"e4", "e5", "Nf3", "Nc6"} {
+		play(text)
+	}
+	final := gs.FEN()
+
+	if !gs.TakeBackMove() || !gs.TakeBackMove() || !gs.TakeBackMove() {
+		t.Fatal("TakeBackMove returned false with moves to take back")
+	}
+	if len(gs.RedoStack) != 3 {
+		t.Fatalf("len(RedoStack) = %d, want 3", len(gs.RedoStack))
+	}
+
+	// Playing the move that was taken back keeps the rest of the redo stack.
+	play("e5")
+	if len(gs.RedoStack) != 2 {
+		t.Fatalf("len(RedoStack) = %d after replaying a move, want 2", len(gs.RedoStack))
+	}
+	for gs.RedoMove() {
+	}
+	if gs.FEN() != final {
+		t.Fatalf("position after redoing everything = %q, want %q", gs.FEN(), final)
+	}
+
+	gs.TakeBackMove()
+	play("d6")
+	if len(gs.RedoStack) != 0 {
+		t.Errorf("len(RedoStack) = %d after a different move, want 0", len(gs.RedoStack))
+	}
+	if gs.RedoMove() {
+		t.Error("RedoMove returned true with nothing to redo")
+	}
+
+	for gs.TakeBackMove() {
+	}
+	if gs.FEN() != StartingPositionFEN {
+		t.Errorf("position after taking back everything = %q", gs.FEN())
+	}
+}
+
+func TestNullMove(t *testing.T) {
+	// Black has just pushed a pawn two squares, so there is an en passant
+	// square for the null move to clear.
+	gs, _ := NewGameStateFromFEN("rnbqkbnr/ppp1pppp/8/2Pp4/8/8/PP1PPPPP/RNBQKBNR w KQkq d6 0 2")
+	gs.ValidMoves = gs.GetValidMoves()
+	before := *gs
+
+	gs.MakeNullMove()
+	if gs.WhiteToMove || gs.EnPassantSquare != GetNullSquare() {
+		t.Fatalf("after a null move: %s", gs.FEN())
+	}
+	if gs.Hash != gs.ComputeHash() {
+		t.Errorf("hash %x after a null move, want %x", gs.Hash, gs.ComputeHash())
+	}
+	for _, move := range gs.GetValidMoves() {
+		if move.PieceMoved[0] != 'b' {
+			t.Fatalf("white can play %s after passing", move.GetUCINotation())
+		}
+	}
+
+	gs.UndoNullMove()
+	if !reflect.DeepEqual(*gs, before) {
+		t.Error("UndoNullMove did not restore the game state")
+	}
+}
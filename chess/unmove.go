@@ -0,0 +1,102 @@
+package chess
+
+// NewEmptyPosition returns a position with no pieces on the board, no
+// castling rights and no en passant square, for building up with PutPiece.
+func NewEmptyPosition(whiteToMove bool) *Position {
+	p := &Position{WhiteToMove: whiteToMove, EnPassantSquare: GetNullSquare(), FullmoveNumber: 1}
+	for sq := range p.board {
+		p.board[sq] = noPiece
+	}
+	return p
+}
+
+// PutPiece puts the piece named code, such as "wN", on sq, which must be
+// empty.
+func (p *Position) PutPiece(sq int, code string) {
+	p.putPiece(sq, pieceIndex(code))
+}
+
+// RemovePiece takes whatever piece is on sq off the board.
+func (p *Position) RemovePiece(sq int) {
+	if p.board[sq] != noPiece {
+		p.removePiece(sq)
+	}
+}
+
+// GetUnmoves returns the moves the side that is not to move could have just
+// played to reach the position, each from the square the piece came from to
+// the one it stands on. Only moves that capture nothing are included, and
+// not promotions, castling or en passant, since undoing those needs more than
+// the position to know what was there before. Every unmove leads back to a
+// legal position.
+func (p *Position) GetUnmoves() []Move {
+	them := p.sideToMove()
+	us := 1 - them
+	occupied := p.Occupied()
+	empty := ^occupied
+	unmoves := []Move{}
+
+	pawns := p.Pieces[us][Pawn]
+	back, startRow, doubleRow := 8, 6, 4
+	if us == Black {
+		back, startRow, doubleRow = -8, 1, 3
+	}
+	for pawns != 0 {
+		to := pawns.PopFirst()
+		from := to + back
+		if to/8 == startRow || !empty.Has(from) {
+			continue
+		}
+		unmoves = p.addUnmove(unmoves, from, to, them)
+		if to/8 == doubleRow && empty.Has(from+back) {
+			unmoves = p.addUnmove(unmoves, from+back, to, them)
+		}
+	}
+
+	for pieceType := Knight; pieceType <= King; pieceType++ {
+		pieces := p.Pieces[us][pieceType]
+		for pieces != 0 {
+			to := pieces.PopFirst()
+			var from Bitboard
+			switch pieceType {
+			case Knight:
+				from = KnightAttacks(to)
+			case Bishop:
+				from = BishopAttacks(to, occupied)
+			case Rook:
+				from = RookAttacks(to, occupied)
+			case Queen:
+				from = QueenAttacks(to, occupied)
+			case King:
+				from = KingAttacks(to)
+			}
+			from &= empty
+			for from != 0 {
+				unmoves = p.addUnmove(unmoves, from.PopFirst(), to, them)
+			}
+		}
+	}
+	return unmoves
+}
+
+// addUnmove adds the move from from to to, unless the side to move, them,
+// would have been left in check before it, which cannot happen in a legal
+// game.
+func (p *Position) addUnmove(unmoves []Move, from int, to int, them int) []Move {
+	p.movePiece(to, from)
+	legal := p.AttackersOf(p.KingSquare(them), 1-them, p.Occupied()) == 0
+	p.movePiece(from, to)
+	if !legal {
+		return unmoves
+	}
+	start, end := Square{from / 8, from % 8}, Square{to / 8, to % 8}
+	return append(unmoves, Move{
+		StartRow:      start.Row,
+		StartCol:      start.Col,
+		EndRow:        end.Row,
+		EndCol:        end.Col,
+		PieceMoved:    p.PieceAt(to),
+		PieceCaptured: "--",
+		MoveId:        getMoveId(start, end, ""),
+	})
+}
@@ -0,0 +1,57 @@
+package chess
+
+import "testing"
+
+// TestUnmoves checks GetUnmoves against GetValidMoves: every quiet move
+// played from a position must be an unmove of the position it leads to, and
+// every unmove must lead back to a position where it is a legal move.
+func TestUnmoves(t *testing.T) {
+	fens := []string{
+		"4k3/8/8/8/8/8/4P3/4K3 w - - 0 1",
+		"8/8/8/2k5/8/8/3PQ3/4K3 w - - 0 1",
+		"4k3/1p6/8/2n5/8/8/8/4K2R b - - 0 1",
+		"r3k3/8/8/8/8/8/6B1/1N2K3 w - - 0 1",
+		"8/8/8/8/3k4/8/8/3QK3 b - - 0 1",
+	}
+	quiet := func(move Move) bool {
+		return move.PieceCaptured == "--" && !move.IsPawnPromotion && !move.IsCastleMove && !move.IsEnPassant
+	}
+	for _, fen := range fens {
+		p, err := NewPositionFromFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, move := range p.GetValidMoves() {
+			if !quiet(move) {
+				continue
+			}
+			p.MakeMove(move)
+			found := false
+			for _, unmove := range p.GetUnmoves() {
+				found = found || unmove.MoveId == move.MoveId
+			}
+			if !found {
+				t.Errorf("%s: %s is not an unmove of %s", fen, move.GetUCINotation(), p.FEN())
+			}
+			p.UndoMove()
+		}
+
+		for _, unmove := range p.GetUnmoves() {
+			before := NewEmptyPosition(!p.WhiteToMove)
+			for sq := 0; sq < 64; sq++ {
+				if piece := p.PieceAt(sq); piece != "--" {
+					before.PutPiece(sq, piece)
+				}
+			}
+			before.RemovePiece(unmove.EndRow*8 + unmove.EndCol)
+			before.PutPiece(unmove.StartRow*8+unmove.StartCol, unmove.PieceMoved)
+			found := false
+			for _, move := range before.GetValidMoves() {
+				found = found || move.MoveId == unmove.MoveId
+			}
+			if !found {
+				t.Errorf("%s: unmove %s cannot be played from %s", fen, unmove.GetUCINotation(), before.FEN())
+			}
+		}
+	}
+}
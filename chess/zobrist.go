@@ -0,0 +1,158 @@
+package chess
+
+import "strings"
+
+const (
+	polyglotCastleOffset    = 768
+	polyglotEnPassantOffset = 772
+	polyglotTurnOffset      = 780
+)
+
+// pieceKey returns the Zobrist key for piece standing on Square{row, col}.
+// Polyglot numbers the piece kinds bp, wp, bn, wn, ... bk, wk and the squares
+// from a1, so row 7 is its rank 0.
+func pieceKey(piece string, row int, col int) uint64 {
+	kind := strings.IndexByte("pNBRQK", piece[1]) * 2
+	if piece[0] == 'w' {
+		kind++
+	}
+	return polyglotRandom[64*kind+8*(7-row)+col]
+}
+
+// squarePieceKey is pieceKey for the piece numbering and squares of Position.
+// Flipping the row bits of sq gives Polyglot's square.
+func squarePieceKey(piece int, sq int) uint64 {
+	color, pieceType := piece/6, piece%6
+	return polyglotRandom[64*(2*pieceType+1-color)+(sq^56)]
+}
+
+func castleRightsKey(cr CastleRights) uint64 {
+	var key uint64
+	if cr.WKS {
+		key ^= polyglotRandom[polyglotCastleOffset]
+	}
+	if cr.WQS {
+		key ^= polyglotRandom[polyglotCastleOffset+1]
+	}
+	if cr.BKS {
+		key ^= polyglotRandom[polyglotCastleOffset+2]
+	}
+	if cr.BQS {
+		key ^= polyglotRandom[polyglotCastleOffset+3]
+	}
+	return key
+}
+
+// enPassantKey follows Polyglot in only hashing the en passant file when a
+// pawn of the side to move could actually make the capture.
+func (gs *GameState) enPassantKey() uint64 {
+	if !gs.enPassantCapturePossible() {
+		return 0
+	}
+	return polyglotRandom[polyglotEnPassantOffset+gs.EnPassantSquare.Col]
+}
+
+// enPassantCapturePossible reports whether a pawn of the side to move stands
+// next to the pawn that can be captured en passant.
+func (gs *GameState) enPassantCapturePossible() bool {
+	if gs.EnPassantSquare == GetNullSquare() {
+		return false
+	}
+	pawn, row := "wp", gs.EnPassantSquare.Row+1
+	if !gs.WhiteToMove {
+		pawn, row = "bp", gs.EnPassantSquare.Row-1
+	}
+	for _, col := range []int{gs.EnPassantSquare.Col - 1, gs.EnPassantSquare.Col + 1} {
+		if col >= 0 && col < 8 && gs.Board[row][col] == pawn {
+			return true
+		}
+	}
+	return false
+}
+
+// ComputeHash returns the Zobrist key of the position from scratch. MakeMove
+// and UndoMove keep Hash up to date incrementally; ComputeHash is there to
+// check that they agree.
+func (gs *GameState) ComputeHash() uint64 {
+	var key uint64
+	for r := 0; r < 8; r++ {
+		for c := 0; c < 8; c++ {
+			if gs.Board[r][c] != "--" {
+				key ^= pieceKey(gs.Board[r][c], r, c)
+			}
+		}
+	}
+	key ^= castleRightsKey(gs.CastleRights)
+	key ^= gs.enPassantKey()
+	if gs.WhiteToMove {
+		key ^= polyglotRandom[polyglotTurnOffset]
+	}
+	return key
+}
+
+// ComputeHash returns the Zobrist key of the position from scratch, as
+// GameState.ComputeHash does.
+func (p *Position) ComputeHash() uint64 {
+	var key uint64
+	for sq, piece := range p.board {
+		if piece != noPiece {
+			key ^= squarePieceKey(piece, sq)
+		}
+	}
+	key ^= p.stateKey()
+	if p.WhiteToMove {
+		key ^= polyglotRandom[polyglotTurnOffset]
+	}
+	return key
+}
+
+func (p *Position) stateKey() uint64 {
+	key := castleRightsKey(p.CastleRights)
+	if p.EnPassantSquare != GetNullSquare() {
+		us := p.sideToMove()
+		ep := p.EnPassantSquare.Row*8 + p.EnPassantSquare.Col
+		if PawnAttacks(1-us, ep)&p.Pieces[us][Pawn] != 0 {
+			key ^= polyglotRandom[polyglotEnPassantOffset+p.EnPassantSquare.Col]
+		}
+	}
+	return key
+}
+
+// stateKey covers the parts of the key besides the pieces and the side to
+// move. MakeMove removes it before playing a move and adds it back after.
+func (gs *GameState) stateKey() uint64 {
+	return castleRightsKey(gs.CastleRights) ^ gs.enPassantKey()
+}
+
+// moveKey returns the change in piece keys made by move.
+func moveKey(move Move) uint64 {
+	key := pieceKey(move.PieceMoved, move.StartRow, move.StartCol)
+	if move.IsPawnPromotion {
+		key ^= pieceKey(move.PromotionPiece, move.EndRow, move.EndCol)
+	} else {
+		key ^= pieceKey(move.PieceMoved, move.EndRow, move.EndCol)
+	}
+
+	if move.IsEnPassant {
+		key ^= pieceKey(opponentPawn(move.PieceMoved), move.StartRow, move.EndCol)
+	} else if move.PieceCaptured != "--" {
+		key ^= pieceKey(move.PieceCaptured, move.EndRow, move.EndCol)
+	}
+
+	if move.IsCastleMove {
+		rook := move.PieceMoved[:1] + "R"
+		if move.EndCol-move.StartCol == 2 {
+			key ^= pieceKey(rook, move.EndRow, 7) ^ pieceKey(rook, move.EndRow, move.EndCol-1)
+		} else {
+			key ^= pieceKey(rook, move.EndRow, 0) ^ pieceKey(rook, move.EndRow, move.EndCol+1)
+		}
+	}
+	return key
+}
+
+func opponentPawn(piece string) string {
+	if piece[0] == 'w' {
+		return "bp"
+	}
+	return "wp"
+}
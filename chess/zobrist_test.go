@@ -0,0 +1,70 @@
+package chess
+
+import (
+	"strings"
+	"testing"
+)
+
+// Keys from the Polyglot book format specification.
+var polyglotKeyTests = []struct {
+	moves string
+	key   uint64
+}{
+	{"", 0x463b96181691fc9c},
+	{"e2e4", 0x823c9b50fd114196},
+	{"e2e4 d7d5", 0x0756b94461c50fb0},
+	{"e2e4 d7d5 e4e5", 0x662fafb965db29d4},
+	{"e2e4 d7d5 e4e5 f7f5", 0x22a48b5a8e47ff78},
+	{"e2e4 d7d5 e4e5 f7f5 e1e2", 0x652a607ca3f242c1},
+	{"e2e4 d7d5 e4e5 f7f5 e1e2 e8f7", 0x00fdd303c946bdd9},
+	{"a2a4 b7b5 h2h4 b5b4 c2c4", 0x3c8123ea7b067637},
+	{"a2a4 b7b5 h2h4 b5b4 c2c4 b4c3 a1a3", 0x5c3f9b829b279560},
+}
+
+func TestPolyglotKeys(t *testing.T) {
+	for _, test := range polyglotKeyTests {
+		gs := NewGameState()
+		for _, text := range strings.Fields(test.moves) {
+			move, err := gs.ParseMove(text)
+			if err != nil {
+				t.Fatalf("%q: %v", test.moves, err)
+			}
+			gs.MakeMove(move)
+		}
+		if gs.Hash != test.key {
+			t.Errorf("%q: Hash = %016x, want %016x", test.moves, gs.Hash, test.key)
+		}
+		if hash := gs.ComputeHash(); hash != test.key {
+			t.Errorf("%q: ComputeHash() = %016x, want %016x", test.moves, hash, test.key)
+		}
+		fromFEN, _ := NewGameStateFromFEN(gs.FEN())
+		if fromFEN.Hash != test.key {
+			t.Errorf("%q: Hash from FEN = %016x, want %016x", test.moves, fromFEN.Hash, test.key)
+		}
+	}
+}
+
+// checkHash walks the move tree below gs and fails if the incrementally
+// updated hash ever differs from one computed from scratch.
+func checkHash(t *testing.T, gs *GameState, depth int) {
+	if gs.Hash != gs.ComputeHash() {
+		t.Fatalf("%s: Hash = %016x, ComputeHash() = %016x", gs.FEN(), gs.Hash, gs.ComputeHash())
+	}
+	if depth == 0 {
+		return
+	}
+	for _, move := range gs.GetValidMoves() {
+		gs.MakeMove(move)
+		checkHash(t, gs, depth-1)
+		gs.UndoMove()
+	}
+}
+
+func TestHashStaysInSync(t *testing.T) {
+	for _, position := range perftPositions {
+		t.Run(position.name, func(t *testing.T) {
+			gs, _ := NewGameStateFromFEN(position.fen)
+			checkHash(t, gs, 3)
+		})
+	}
+}
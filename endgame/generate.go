@@ -0,0 +1,250 @@
+package endgame
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mattellis91/go-chess/chess"
+)
+
+// maxDistance is the longest distance to mate a value byte can hold.
+const maxDistance = invalid - 2
+
+// Generator builds tables by retrograde analysis. Captures and promotions
+// lead into tables with other material, which it builds first, or takes from
+// its Tablebase if they are already there.
+type Generator struct {
+	tb *Tablebase
+	// OnTable, if set, is called after each table is generated.
+	OnTable func(t *Table)
+}
+
+// NewGenerator returns a generator that adds the tables it makes to tb, or to
+// a new Tablebase if tb is nil.
+func NewGenerator(tb *Tablebase) *Generator {
+	if tb == nil {
+		tb = NewTablebase()
+	}
+	return &Generator{tb: tb}
+}
+
+// Tablebase returns the tables generated so far, with those the generator
+// started with.
+func (g *Generator) Tablebase() *Tablebase {
+	return g.tb
+}
+
+// Generate returns the table for material, such as "KQvK", generating it and
+// every table it depends on unless they are already in the Tablebase.
+// Material with pawns on both sides is not supported, as those positions
+// would also depend on the right to capture en passant.
+func (g *Generator) Generate(material string) (*Table, error) {
+	t, err := newTable(material)
+	if err != nil {
+		return nil, err
+	}
+	if existing := g.tb.tables[t.Material]; existing != nil {
+		return existing, nil
+	}
+	sides := strings.Split(t.Material, "v")
+	if strings.Contains(sides[0], "P") && strings.Contains(sides[1], "P") {
+		return nil, fmt.Errorf("endgame: %s has pawns on both sides, which is not supported", t.Material)
+	}
+
+	for _, sub := range subMaterials(t.pieces) {
+		if _, err := g.Generate(sub); err != nil {
+			return nil, err
+		}
+	}
+	if err := g.retrograde(t); err != nil {
+		return nil, err
+	}
+	g.tb.Add(t)
+	if g.OnTable != nil {
+		g.OnTable(t)
+	}
+	return t, nil
+}
+
+// subMaterials returns the material left after any capture or promotion, as
+// long as more than the kings are left.
+func subMaterials(pieces []string) []string {
+	subs := []string{}
+	for i, piece := range pieces {
+		if piece[1] == 'K' {
+			continue
+		}
+		rest := append(append([]string(nil), pieces[:i]...), pieces[i+1:]...)
+		if len(rest) > 2 {
+			subs = append(subs, materialOf(rest))
+		}
+		if piece[1] == 'p' {
+			for _, promotion := range chess.PromotionPieceTypes {
+				subs = append(subs, materialOf(append(rest, piece[:1]+string(promotion))))
+			}
+		}
+	}
+	return subs
+}
+
+// retrograde fills in t. Mates, and positions whose captures and promotions
+// already decide them, are found first; then, a ply at a time, every position
+// that can move into a lost one is won, and every position whose every move
+// leads to a won one is lost. Positions left over at the end are draws.
+func (g *Generator) retrograde(t *Table) error {
+	size := t.size()
+	t.values = make([]byte, size)
+	// moves counts, for each position, the moves that are not yet known to
+	// lose. exitLoss is one more than the longest distance to mate among
+	// the captures and promotions that lose, as those are known from the
+	// start.
+	moves := make([]uint8, size)
+	exitLoss := make([]uint8, size)
+	// pending holds, for each distance, the positions that will have it
+	// unless they are found to have a shorter one first.
+	pending := make([][]int32, maxDistance+1)
+	schedule := func(index int, distance int) error {
+		if distance > maxDistance {
+			return fmt.Errorf("endgame: %s has a mate longer than %d plies", t.Material, maxDistance)
+		}
+		pending[distance] = append(pending[distance], int32(index))
+		return nil
+	}
+
+	p := chess.NewEmptyPosition(true)
+	squares := make([]int, len(t.pieces))
+	for index := 0; index < size; index++ {
+		t.decode(index, squares)
+		if !t.canonical(squares) {
+			t.values[index] = invalid
+			continue
+		}
+		t.setup(p, squares, index < size/2)
+		us := 0
+		if !p.WhiteToMove {
+			us = 1
+		}
+		if p.AttackersOf(p.KingSquare(1-us), us, p.Occupied()) != 0 {
+			// The side that just moved is in check.
+			t.values[index] = invalid
+			t.clear(p, squares)
+			continue
+		}
+
+		legal := p.GetValidMoves()
+		win := maxDistance + 1
+		count, loss := 0, 0
+		for _, move := range legal {
+			if move.PieceCaptured == "--" && !move.IsPawnPromotion {
+				count++
+				continue
+			}
+			p.MakeMove(move)
+			value, ok := g.tb.probe(p.PieceAt, p.WhiteToMove)
+			p.UndoMove()
+			if !ok {
+				return fmt.Errorf("endgame: %s: no table after %s", t.Material, move.GetUCINotation())
+			}
+			switch {
+			case value == draw:
+				count++
+			case wdl(value) == chess.WDLLoss:
+				count++
+				if int(value) < win {
+					win = int(value)
+				}
+			case int(value) > loss:
+				loss = int(value)
+			}
+		}
+		moves[index], exitLoss[index] = uint8(count), uint8(loss)
+
+		var err error
+		switch {
+		case len(legal) == 0 && p.Checkmate:
+			err = schedule(index, 0)
+		case len(legal) == 0:
+			// Stalemate.
+		case win <= maxDistance:
+			err = schedule(index, win)
+		case count == 0:
+			err = schedule(index, loss)
+		}
+		t.clear(p, squares)
+		if err != nil {
+			return err
+		}
+	}
+
+	for distance := 0; distance <= maxDistance; distance++ {
+		for _, index := range pending[distance] {
+			if t.values[index] != draw {
+				continue
+			}
+			t.values[index] = byte(distance + 1)
+
+			t.decode(int(index), squares)
+			t.setup(p, squares, int(index) < size/2)
+			for _, unmove := range p.GetUnmoves() {
+				previous := t.previous(squares, unmove, !p.WhiteToMove)
+				if t.values[previous] != draw {
+					continue
+				}
+				var err error
+				if distance%2 == 0 {
+					// Moving here mates, or wins in time.
+					err = schedule(previous, distance+1)
+				} else if moves[previous]--; moves[previous] == 0 {
+					// Every move loses, and this is the last to be found.
+					later := distance + 1
+					if int(exitLoss[previous]) > later {
+						later = int(exitLoss[previous])
+					}
+					err = schedule(previous, later)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			t.clear(p, squares)
+		}
+		pending[distance] = nil
+	}
+	return nil
+}
+
+// setup puts the pieces on squares onto the empty board of p.
+func (t *Table) setup(p *chess.Position, squares []int, whiteToMove bool) {
+	for i, sq := range squares {
+		p.PutPiece(sq, t.pieces[i])
+	}
+	p.WhiteToMove = whiteToMove
+}
+
+// clear empties the board of p again after setup.
+func (t *Table) clear(p *chess.Position, squares []int) {
+	for _, sq := range squares {
+		p.RemovePiece(sq)
+	}
+}
+
+// previous returns the index of the position before unmove was played from it,
+// with whiteToMove to move, when the pieces stand on squares after it.
+func (t *Table) previous(squares []int, unmove chess.Move, whiteToMove bool) int {
+	before := append(make([]int, 0, MaxPieces), squares...)
+	to, from := unmove.EndRow*8+unmove.EndCol, unmove.StartRow*8+unmove.StartCol
+	moved := 0
+	for i, sq := range before {
+		if sq == to {
+			before[i], moved = from, i
+		}
+	}
+	// Keep identical pieces in increasing order of square.
+	for i := moved; i > 0 && t.pieces[i-1] == t.pieces[i] && before[i-1] > before[i]; i-- {
+		before[i-1], before[i] = before[i], before[i-1]
+	}
+	for i := moved; i+1 < len(before) && t.pieces[i+1] == t.pieces[i] && before[i+1] < before[i]; i++ {
+		before[i+1], before[i] = before[i], before[i+1]
+	}
+	return t.index(before, whiteToMove)
+}
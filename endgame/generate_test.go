@@ -0,0 +1,255 @@
+package endgame
+
+import (
+	"bytes"
+	"flag"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/mattellis91/go-chess/chess"
+	"github.com/mattellis91/go-chess/engine"
+)
+
+var long = flag.Bool("long", false, "generate the larger tables")
+
+var (
+	testTablesOnce sync.Once
+	testTables     *Tablebase
+	testTablesErr  error
+)
+
+// generated returns a tablebase with KQvK, KRvK and KPvK, and the tables they
+// lead into, generating them once for all the tests.
+func generated(t *testing.T) *Tablebase {
+	t.Helper()
+	testTablesOnce.Do(func() {
+		g := NewGenerator(nil)
+		for _, material := range []string{"KQvK", "KRvK", "KPvK"} {
+			if _, testTablesErr = g.Generate(material); testTablesErr != nil {
+				return
+			}
+		}
+		testTables = g.Tablebase()
+	})
+	if testTablesErr != nil {
+		t.Fatal(testTablesErr)
+	}
+	return testTables
+}
+
+func longestMate(table *Table) int {
+	longest := 0
+	for _, value := range table.values {
+		if value != draw && value != invalid && int(value)-1 > longest {
+			longest = int(value) - 1
+		}
+	}
+	return longest
+}
+
+func TestGenerateLongestMates(t *testing.T) {
+	tb := generated(t)
+	for material, want := range map[string]int{"KQvK": 20, "KRvK": 32} {
+		// The longest mates are 10 and 16 moves by the stronger side, which
+		// the defender can only drag out when it is its turn.
+		if got := longestMate(tb.Table(material)); got != want {
+			t.Errorf("longest %s mate is %d plies, want %d", material, got, want)
+		}
+	}
+}
+
+func TestProbeGenerated(t *testing.T) {
+	tb := generated(t)
+	tests := []struct {
+		fen      string
+		wdl      chess.WDL
+		distance int
+	}{
+		{"4k3/4Q3/4K3/8/8/8/8/8 b - - 0 1", chess.WDLLoss, 0},
+		{"4k3/8/4K3/8/8/8/8/7Q w - - 0 1", chess.WDLWin, 1},
+		{"k7/8/1K6/8/8/8/8/7R w - - 0 1", chess.WDLWin, 1},
+		{"4k3/4P3/4K3/8/8/8/8/8 b - - 0 1", chess.WDLDraw, 0},
+		{"4k3/8/4P3/4K3/8/8/8/8 w - - 0 1", chess.WDLDraw, 0},
+		{"4k3/8/4K3/4P3/8/8/8/8 w - - 0 1", chess.WDLWin, -1},
+		{"4k3/8/4K3/4P3/8/8/8/8 b - - 0 1", chess.WDLLoss, -1},
+		// The same position with the colours reversed, found through the
+		// KPvK table.
+		{"8/8/8/8/4p3/4k3/8/4K3 w - - 0 1", chess.WDLLoss, -1},
+		{"8/8/8/3k4/8/3K4/8/8 w - - 0 1", chess.WDLDraw, 0},
+	}
+	for _, test := range tests {
+		gs, err := chess.NewGameStateFromFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wdl, ok := tb.ProbeWDL(gs)
+		if !ok || wdl != test.wdl {
+			t.Errorf("%s: got %v %v, want %v", test.fen, wdl, ok, test.wdl)
+		}
+		distance, ok := tb.ProbeDistance(gs)
+		if !ok || test.distance >= 0 && distance != test.distance {
+			t.Errorf("%s: distance %d %v, want %d", test.fen, distance, ok, test.distance)
+		}
+	}
+
+	gs, _ := chess.NewGameStateFromFEN("4k3/8/4K3/8/8/8/8/R6R w - - 0 1")
+	if _, ok := tb.ProbeWDL(gs); ok {
+		t.Error("probed KRRvK without its table")
+	}
+}
+
+// TestGeneratedConsistent checks that every position's value follows from the
+// values of the positions its moves lead to.
+func TestGeneratedConsistent(t *testing.T) {
+	tb := generated(t)
+	for _, material := range []string{"KRvK", "KPvK"} {
+		table := tb.Table(material)
+		p := chess.NewEmptyPosition(true)
+		squares := make([]int, len(table.pieces))
+		for index, value := range table.values {
+			if value == invalid {
+				continue
+			}
+			table.decode(index, squares)
+			table.setup(p, squares, index < table.size()/2)
+			legal := p.GetValidMoves()
+			// best is the value the position should have from its moves.
+			best := -1
+			for _, move := range legal {
+				p.MakeMove(move)
+				child, ok := tb.probe(p.PieceAt, p.WhiteToMove)
+				p.UndoMove()
+				if !ok {
+					t.Fatalf("%s: no value after %s", p.FEN(), move.GetUCINotation())
+				}
+				switch {
+				case child == draw:
+					if best < 0 || wdl(byte(best)) != chess.WDLWin {
+						best = draw
+					}
+				case wdl(child) == chess.WDLLoss:
+					if best < 0 || wdl(byte(best)) != chess.WDLWin || int(child)+1 < best {
+						best = int(child) + 1
+					}
+				case best < 0 || wdl(byte(best)) == chess.WDLLoss && int(child)+1 > best:
+					best = int(child) + 1
+				}
+			}
+			if len(legal) == 0 {
+				best = draw
+				if p.Checkmate {
+					best = 1
+				}
+			}
+			if int(value) != best {
+				t.Fatalf("%s: value %d, want %d", p.FEN(), value, best)
+			}
+			table.clear(p, squares)
+		}
+	}
+}
+
+func TestTableRoundTrip(t *testing.T) {
+	tb := generated(t)
+	table := tb.Table("KQvK")
+	var buf bytes.Buffer
+	if _, err := table.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() >= len(table.values)/3 {
+		t.Errorf("%d bytes compressed to %d", len(table.values), buf.Len())
+	}
+	read, err := ReadTable(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Material != "KQvK" || !bytes.Equal(read.values, table.values) {
+		t.Error("table read back differs")
+	}
+	if _, err := ReadTable(bytes.NewReader([]byte("not a table"))); err != ErrNotTable {
+		t.Errorf("got %v, want ErrNotTable", err)
+	}
+
+	dir := t.TempDir()
+	if err := table.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	opened, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(opened.Tables()) != 1 || opened.MaxPieces() != 3 || opened.Table("KQK") == nil {
+		t.Errorf("opened %d tables", len(opened.Tables()))
+	}
+}
+
+func TestRandomPosition(t *testing.T) {
+	tb := generated(t)
+	rng := rand.New(rand.NewSource(1))
+	gs, ok := tb.Table("KRvK").RandomPosition(rng, 25)
+	if !ok {
+		t.Fatal("no position")
+	}
+	wdl, _ := tb.ProbeWDL(gs)
+	distance, _ := tb.ProbeDistance(gs)
+	if !gs.WhiteToMove || wdl != chess.WDLWin || distance < 25 {
+		t.Errorf("%s: %v in %d", gs.FEN(), wdl, distance)
+	}
+	if _, ok := tb.Table("KRvK").RandomPosition(rng, 40); ok {
+		t.Error("found a KRvK mate longer than the longest")
+	}
+}
+
+func TestParseMaterial(t *testing.T) {
+	for input, want := range map[string]string{"KQvK": "KQvK", "kbnk": "KBNvK", "KNBK": "KBNvK", "KvKP": "KvKP"} {
+		if got, _, err := ParseMaterial(input); err != nil || got != want {
+			t.Errorf("ParseMaterial(%q) = %q, %v, want %q", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"KQ", "QvK", "KXvK", "KQRvKR"} {
+		if _, _, err := ParseMaterial(input); err == nil {
+			t.Errorf("ParseMaterial(%q) succeeded", input)
+		}
+	}
+	if _, err := NewGenerator(nil).Generate("KPvKP"); err == nil {
+		t.Error("generated KPvKP")
+	}
+}
+
+func TestGenerateKBNvK(t *testing.T) {
+	if !*long {
+		t.Skip("run with -long to generate KBNvK")
+	}
+	table, err := NewGenerator(generated(t)).Generate("KBNvK")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Mate with bishop and knight takes at most 33 moves.
+	if got := longestMate(table); got != 66 {
+		t.Errorf("longest KBNvK mate is %d plies, want 66", got)
+	}
+}
+
+// TestEngineWithTables checks that the engine, given the tables, mates as
+// fast as it can and defends as long as it can.
+func TestEngineWithTables(t *testing.T) {
+	tb := generated(t)
+	e := engine.NewEngine()
+	e.Tablebase = tb
+	gs, err := chess.NewGameStateFromFEN("8/8/8/3k4/8/8/8/R3K3 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	distance, _ := tb.ProbeDistance(gs)
+	for ; distance > 0; distance-- {
+		move := e.Search(gs, engine.Limits{Depth: 2}).Move
+		gs.PlayMove(move)
+		if got, _ := tb.ProbeDistance(gs); got != distance-1 {
+			t.Fatalf("after %s mate is %d plies away, want %d", move.GetUCINotation(), got, distance-1)
+		}
+	}
+	if outcome := gs.Outcome(); outcome.Reason != chess.Checkmate {
+		t.Errorf("game ended by %v", outcome.Reason)
+	}
+}
@@ -0,0 +1,414 @@
+// Package endgame generates and reads distance-to-mate tables for endgames
+// with a few pieces, such as KQvK, KRvK, KPvK or KBNvK, so the engine can play
+// them perfectly and players can practise them.
+package endgame
+
+import (
+	"bufio"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mattellis91/go-chess/chess"
+)
+
+// MaxPieces is the most pieces, kings included, a table can have.
+const MaxPieces = 4
+
+// Suffix is the file name extension of a table.
+const Suffix = ".gctb"
+
+const (
+	magic   = "GCTB"
+	version = 1
+
+	// Each position is stored in a byte: draw for a draw, invalid for a
+	// position that cannot occur, and otherwise the distance to mate in
+	// plies plus one. An even distance is a loss for the side to move, an
+	// odd one a win.
+	draw    = 0
+	invalid = 255
+)
+
+var ErrNotTable = errors.New("endgame: not a table")
+
+// Table holds the distance to mate of every position with some material,
+// white's pieces being the first side of Material.
+type Table struct {
+	// Material names the pieces, white's and then black's, strongest
+	// first, as in "KBNvK".
+	Material string
+	// pieces are the pieces as named on the board, in the order their
+	// squares make up an index.
+	pieces []string
+	values []byte
+}
+
+// ParseMaterial checks a material such as "KBNvK" or "KBNK" and returns it
+// in the form Table.Material uses, along with each side's pieces as named on
+// the board.
+func ParseMaterial(material string) (string, []string, error) {
+	material = strings.ToUpper(material)
+	if !strings.Contains(material, "V") {
+		if i := strings.LastIndexByte(material, 'K'); i > 0 {
+			material = material[:i] + "V" + material[i:]
+		}
+	}
+	sides := strings.Split(material, "V")
+	if len(sides) != 2 {
+		return "", nil, fmt.Errorf("endgame: bad material %q", material)
+	}
+	pieces := []string{}
+	for i, side := range sides {
+		color := "wb"[i : i+1]
+		if len(side) == 0 || side[0] != 'K' || strings.Trim(side[1:], "QRBNP") != "" {
+			return "", nil, fmt.Errorf("endgame: bad material %q", material)
+		}
+		sorted := []byte(side[1:])
+		sort.Slice(sorted, func(i, j int) bool {
+			return strings.IndexByte("QRBNP", sorted[i]) < strings.IndexByte("QRBNP", sorted[j])
+		})
+		sides[i] = "K" + string(sorted)
+		for _, piece := range sides[i] {
+			if piece == 'P' {
+				piece = 'p'
+			}
+			pieces = append(pieces, color+string(piece))
+		}
+	}
+	if len(pieces) > MaxPieces {
+		return "", nil, fmt.Errorf("endgame: %s has more than %d pieces", material, MaxPieces)
+	}
+	return sides[0] + "v" + sides[1], pieces, nil
+}
+
+func newTable(material string) (*Table, error) {
+	material, pieces, err := ParseMaterial(material)
+	if err != nil {
+		return nil, err
+	}
+	return &Table{Material: material, pieces: pieces}, nil
+}
+
+// size is the number of indexes: one for each side to move and square of
+// each piece.
+func (t *Table) size() int {
+	return 2 << (6 * len(t.pieces))
+}
+
+// index returns the index of the position with the pieces on squares, with
+// identical pieces on increasing squares.
+func (t *Table) index(squares []int, whiteToMove bool) int {
+	index := 0
+	if !whiteToMove {
+		index = 1
+	}
+	for _, sq := range squares {
+		index = index<<6 | sq
+	}
+	return index
+}
+
+// decode is the inverse of index.
+func (t *Table) decode(index int, squares []int) bool {
+	for i := len(t.pieces) - 1; i >= 0; i-- {
+		squares[i] = index & 63
+		index >>= 6
+	}
+	return index == 0
+}
+
+// canonical reports whether squares hold distinct squares, pawns off the
+// first and last ranks and identical pieces in increasing order, so that
+// each position has a single index.
+func (t *Table) canonical(squares []int) bool {
+	for i, sq := range squares {
+		if t.pieces[i][1] == 'p' && (sq/8 == 0 || sq/8 == 7) {
+			return false
+		}
+		for j := 0; j < i; j++ {
+			if squares[j] == sq || t.pieces[j] == t.pieces[i] && squares[j] > sq {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Tablebase is a set of tables, through which the engine and
+// GameState.Outcome can probe them as a chess.Tablebase. Positions with only
+// kings are known to be draws without a table.
+type Tablebase struct {
+	tables    map[string]*Table
+	maxPieces int
+}
+
+func NewTablebase(tables ...*Table) *Tablebase {
+	tb := &Tablebase{tables: map[string]*Table{}, maxPieces: 2}
+	for _, t := range tables {
+		tb.Add(t)
+	}
+	return tb
+}
+
+// Add adds t, replacing any table for the same material.
+func (tb *Tablebase) Add(t *Table) {
+	tb.tables[t.Material] = t
+	if len(t.pieces) > tb.maxPieces {
+		tb.maxPieces = len(t.pieces)
+	}
+}
+
+// Table returns the table for material, such as "KQvK", or nil.
+func (tb *Tablebase) Table(material string) *Table {
+	material, _, err := ParseMaterial(material)
+	if err != nil {
+		return nil
+	}
+	return tb.tables[material]
+}
+
+// Tables returns the tables in order of material.
+func (tb *Tablebase) Tables() []*Table {
+	tables := []*Table{}
+	for _, t := range tb.tables {
+		tables = append(tables, t)
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Material < tables[j].Material })
+	return tables
+}
+
+func (tb *Tablebase) MaxPieces() int {
+	return tb.maxPieces
+}
+
+// ProbeWDL returns whether the side to move wins, draws or loses. Distance
+// to mate takes no account of the fifty-move rule, so there are no cursed
+// wins or blessed losses.
+func (tb *Tablebase) ProbeWDL(gs *chess.GameState) (chess.WDL, bool) {
+	value, ok := tb.probeGameState(gs)
+	return wdl(value), ok
+}
+
+// ProbeDistance returns the number of plies to mate with best play, or 0 for
+// a draw.
+func (tb *Tablebase) ProbeDistance(gs *chess.GameState) (int, bool) {
+	value, ok := tb.probeGameState(gs)
+	if !ok || value == draw {
+		return 0, ok
+	}
+	return int(value) - 1, true
+}
+
+func wdl(value byte) chess.WDL {
+	switch {
+	case value == draw:
+		return chess.WDLDraw
+	case (value-1)%2 == 0:
+		return chess.WDLLoss
+	}
+	return chess.WDLWin
+}
+
+func (tb *Tablebase) probeGameState(gs *chess.GameState) (byte, bool) {
+	if gs.CastleRights != (chess.CastleRights{}) {
+		return 0, false
+	}
+	return tb.probe(func(sq int) string { return gs.Board[sq/8][sq%8] }, gs.WhiteToMove)
+}
+
+// probe looks up the position with the pieces pieceAt returns for each square,
+// trying the table with the colours reversed if there is none as it is.
+func (tb *Tablebase) probe(pieceAt func(sq int) string, whiteToMove bool) (byte, bool) {
+	type placed struct {
+		sq    int
+		piece string
+	}
+	pieces := make([]placed, 0, MaxPieces)
+	for sq := 0; sq < 64; sq++ {
+		if piece := pieceAt(sq); piece != "--" {
+			if len(pieces) == tb.maxPieces {
+				return 0, false
+			}
+			pieces = append(pieces, placed{sq, piece})
+		}
+	}
+	if len(pieces) == 2 {
+		return draw, true
+	}
+
+	codes := make([]string, len(pieces))
+	for i, p := range pieces {
+		codes[i] = p.piece
+	}
+	material := materialOf(codes)
+	t := tb.tables[material]
+	if t == nil {
+		// Look for the table with the colours swapped, and turn the board
+		// round to match.
+		sides := strings.Split(material, "v")
+		if t = tb.tables[sides[1]+"v"+sides[0]]; t == nil {
+			return 0, false
+		}
+		for i, p := range pieces {
+			color := "w"
+			if p.piece[0] == 'w' {
+				color = "b"
+			}
+			pieces[i] = placed{p.sq ^ 56, color + p.piece[1:]}
+		}
+		whiteToMove = !whiteToMove
+	}
+
+	// Give identical pieces their squares in increasing order.
+	sort.Slice(pieces, func(i, j int) bool { return pieces[i].sq < pieces[j].sq })
+	var squares [MaxPieces]int
+	var used [MaxPieces]bool
+	for i, piece := range t.pieces {
+		for j, p := range pieces {
+			if !used[j] && p.piece == piece {
+				used[j], squares[i] = true, p.sq
+				break
+			}
+		}
+	}
+	value := t.values[t.index(squares[:len(t.pieces)], whiteToMove)]
+	return value, value != invalid
+}
+
+// materialOf names the material of pieces, such as "wK", in the form
+// Table.Material uses.
+func materialOf(pieces []string) string {
+	var sides [2]string
+	for _, piece := range "KQRBNp" {
+		for _, p := range pieces {
+			if rune(p[1]) == piece {
+				side := 0
+				if p[0] == 'b' {
+					side = 1
+				}
+				sides[side] += strings.ToUpper(string(piece))
+			}
+		}
+	}
+	return sides[0] + "v" + sides[1]
+}
+
+// RandomPosition returns a random position from the table, with white to
+// move, that white wins in at least minPlies plies, or false if there is
+// none.
+func (t *Table) RandomPosition(rng *rand.Rand, minPlies int) (*chess.GameState, bool) {
+	candidates := []int{}
+	// White to move has the lower half of the indexes.
+	for index, value := range t.values[:t.size()/2] {
+		if value != draw && value != invalid && wdl(value) == chess.WDLWin && int(value)-1 >= minPlies {
+			candidates = append(candidates, index)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, false
+	}
+	squares := make([]int, len(t.pieces))
+	t.decode(candidates[rng.Intn(len(candidates))], squares)
+	p := chess.NewEmptyPosition(true)
+	for i, sq := range squares {
+		p.PutPiece(sq, t.pieces[i])
+	}
+	gs, err := chess.NewGameStateFromFEN(p.FEN())
+	return gs, err == nil
+}
+
+// WriteTo writes the table in its compressed on-disk form: a header with the
+// material, and then the value of every position compressed with zlib.
+func (t *Table) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	header := append([]byte(magic), version, byte(len(t.Material)))
+	if _, err := cw.Write(append(header, t.Material...)); err != nil {
+		return cw.n, err
+	}
+	zw := zlib.NewWriter(cw)
+	if _, err := zw.Write(t.values); err != nil {
+		return cw.n, err
+	}
+	err := zw.Close()
+	return cw.n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(b []byte) (int, error) {
+	n, err := cw.w.Write(b)
+	cw.n += int64(n)
+	return n, err
+}
+
+// ReadTable reads a table written by WriteTo.
+func ReadTable(r io.Reader) (*Table, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(magic)+2)
+	if _, err := io.ReadFull(br, header); err != nil || string(header[:len(magic)]) != magic || header[len(magic)] != version {
+		return nil, ErrNotTable
+	}
+	material := make([]byte, header[len(magic)+1])
+	if _, err := io.ReadFull(br, material); err != nil {
+		return nil, ErrNotTable
+	}
+	t, err := newTable(string(material))
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zlib.NewReader(br)
+	if err != nil {
+		return nil, ErrNotTable
+	}
+	defer zr.Close()
+	t.values = make([]byte, t.size())
+	if _, err := io.ReadFull(zr, t.values); err != nil {
+		return nil, fmt.Errorf("endgame: %s: %w", t.Material, err)
+	}
+	return t, nil
+}
+
+// Save writes the table to dir as Material plus Suffix.
+func (t *Table) Save(dir string) error {
+	f, err := os.Create(filepath.Join(dir, t.Material+Suffix))
+	if err != nil {
+		return err
+	}
+	if _, err := t.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Open reads every table in dir.
+func Open(dir string) (*Tablebase, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+Suffix))
+	if err != nil {
+		return nil, err
+	}
+	tb := NewTablebase()
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		t, err := ReadTable(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		tb.Add(t)
+	}
+	return tb, nil
+}
@@ -0,0 +1,102 @@
+package engine
+
+import (
+	"strings"
+
+	"github.com/mattellis91/go-chess/chess"
+)
+
+// pieceLetters gives the order of pieceValues and pieceSquareTables.
+const pieceLetters = "pNBRQK"
+
+// pieceValues are in centipawns.
+var pieceValues = [6]int{100, 320, 330, 500, 900, 0}
+
+func pieceType(piece string) int {
+	return strings.IndexByte(pieceLetters, piece[1])
+}
+
+// Piece-square tables are written from white's side of the board, row 0 being
+// the eighth rank like chess.BoardState. Black reads them upside down.
+var pieceSquareTables = [6][8][8]int{
+	{ // pawn
+		{0, 0, 0, 0, 0, 0, 0, 0},
+		{50, 50, 50, 50, 50, 50, 50, 50},
+		{10, 10, 20, 30, 30, 20, 10, 10},
+		{5, 5, 10, 25, 25, 10, 5, 5},
+		{0, 0, 0, 20, 20, 0, 0, 0},
+		{5, -5, -10, 0, 0, -10, -5, 5},
+		{5, 10, 10, -20, -20, 10, 10, 5},
+		{0, 0, 0, 0, 0, 0, 0, 0},
+	},
+	{ // knight
+		{-50, -40, -30, -30, -30, -30, -40, -50},
+		{-40, -20, 0, 0, 0, 0, -20, -40},
+		{-30, 0, 10, 15, 15, 10, 0, -30},
+		{-30, 5, 15, 20, 20, 15, 5, -30},
+		{-30, 0, 15, 20, 20, 15, 0, -30},
+		{-30, 5, 10, 15, 15, 10, 5, -30},
+		{-40, -20, 0, 5, 5, 0, -20, -40},
+		{-50, -40, -30, -30, -30, -30, -40, -50},
+	},
+	{ // bishop
+		{-20, -10, -10, -10, -10, -10, -10, -20},
+		{-10, 0, 0, 0, 0, 0, 0, -10},
+		{-10, 0, 5, 10, 10, 5, 0, -10},
+		{-10, 5, 5, 10, 10, 5, 5, -10},
+		{-10, 0, 10, 10, 10, 10, 0, -10},
+		{-10, 10, 10, 10, 10, 10, 10, -10},
+		{-10, 5, 0, 0, 0, 0, 5, -10},
+		{-20, -10, -10, -10, -10, -10, -10, -20},
+	},
+	{ // rook
+		{0, 0, 0, 0, 0, 0, 0, 0},
+		{5, 10, 10, 10, 10, 10, 10, 5},
+		{-5, 0, 0, 0, 0, 0, 0, -5},
+		{-5, 0, 0, 0, 0, 0, 0, -5},
+		{-5, 0, 0, 0, 0, 0, 0, -5},
+		{-5, 0, 0, 0, 0, 0, 0, -5},
+		{-5, 0, 0, 0, 0, 0, 0, -5},
+		{0, 0, 0, 5, 5, 0, 0, 0},
+	},
+	{ // queen
+		{-20, -10, -10, -5, -5, -10, -10, -20},
+		{-10, 0, 0, 0, 0, 0, 0, -10},
+		{-10, 0, 5, 5, 5, 5, 0, -10},
+		{-5, 0, 5, 5, 5, 5, 0, -5},
+		{0, 0, 5, 5, 5, 5, 0, -5},
+		{-10, 5, 5, 5, 5, 5, 0, -10},
+		{-10, 0, 5, 0, 0, 0, 0, -10},
+		{-20, -10, -10, -5, -5, -10, -10, -20},
+	},
+	{ // king
+		{-30, -40, -40, -50, -50, -40, -40, -30},
+		{-30, -40, -40, -50, -50, -40, -40, -30},
+		{-30, -40, -40, -50, -50, -40, -40, -30},
+		{-30, -40, -40, -50, -50, -40, -40, -30},
+		{-20, -30, -30, -40, -40, -30, -30, -20},
+		{-10, -20, -20, -20, -20, -20, -20, -10},
+		{20, 20, 0, 0, 0, 0, 20, 20},
+		{20, 30, 10, 0, 0, 10, 30, 20},
+	},
+}
+
+// Evaluate scores the position in centipawns from the point of view of the
+// side to move: material plus the piece-square tables.
+func Evaluate(p *chess.Position) int {
+	score := 0
+	for t := chess.Pawn; t <= chess.King; t++ {
+		for pieces := p.Pieces[chess.White][t]; pieces != 0; {
+			sq := pieces.PopFirst()
+			score += pieceValues[t] + pieceSquareTables[t][sq/8][sq%8]
+		}
+		for pieces := p.Pieces[chess.Black][t]; pieces != 0; {
+			sq := pieces.PopFirst()
+			score -= pieceValues[t] + pieceSquareTables[t][7-sq/8][sq%8]
+		}
+	}
+	if !p.WhiteToMove {
+		return -score
+	}
+	return score
+}
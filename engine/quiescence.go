@@ -0,0 +1,73 @@
+package engine
+
+import (
+	"sort"
+
+	"github.com/mattellis91/go-chess/chess"
+)
+
+// quiesce searches captures until the position is quiet, so the evaluation
+// is never taken halfway through an exchange. The side to move may stand pat
+// on the static evaluation rather than capture, and captures that SEE says
+// lose material are skipped.
+func (s *search) quiesce(p *chess.Position, ply int, alpha int, beta int) int {
+	s.countNode()
+	s.pvLength[ply] = 0
+	if s.aborted {
+		return 0
+	}
+
+	standPat := Evaluate(p)
+	if ply >= MaxDepth || standPat >= beta {
+		return standPat
+	}
+	if standPat > alpha {
+		alpha = standPat
+	}
+
+	moves := p.GetValidCaptures()
+	orderCaptures(moves)
+	for _, move := range moves {
+		if p.SEE(move) < 0 {
+			continue
+		}
+		p.MakeMove(move)
+		score := -s.quiesce(p, ply+1, -beta, -alpha)
+		p.UndoMove()
+		if s.aborted {
+			return 0
+		}
+
+		if score >= beta {
+			return score
+		}
+		if score > alpha {
+			alpha = score
+		}
+	}
+	return alpha
+}
+
+// orderCaptures sorts captures most valuable victim first, and for the same
+// victim least valuable attacker first.
+func orderCaptures(moves []chess.Move) {
+	sort.SliceStable(moves, func(i, j int) bool {
+		return mvvLva(moves[i]) > mvvLva(moves[j])
+	})
+}
+
+// mvvLva scores a capture or promotion for move ordering. A promotion counts
+// as capturing the piece it promotes to.
+func mvvLva(move chess.Move) int {
+	victim := 0
+	switch {
+	case move.IsEnPassant:
+		victim = pieceValues[0]
+	case move.PieceCaptured != "--":
+		victim = pieceValues[pieceType(move.PieceCaptured)]
+	}
+	if move.IsPawnPromotion {
+		victim += pieceValues[pieceType(move.PromotionPiece)]
+	}
+	return victim*10 - pieceType(move.PieceMoved)
+}
@@ -0,0 +1,34 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/mattellis91/go-chess/chess"
+)
+
+func TestQuiescenceSeesRecapture(t *testing.T) {
+	// At depth 1 taking the pawn looks good unless the recapture is seen.
+	result := searchFEN(t, "4k3/8/4p3/3p4/8/8/8/3QK3 w - - 0 1", 1)
+	if result.Move.GetUCINotation() == "d1d5" {
+		t.Errorf("queen took a defended pawn, scoring %d", result.Score)
+	}
+}
+
+func TestOrderCaptures(t *testing.T) {
+	gs, _ := chess.NewGameStateFromFEN("7k/8/2q1r3/1P6/3N4/8/8/K1Q5 w - - 0 1")
+	moves := gs.GetValidCaptures()
+	orderCaptures(moves)
+	var got []string
+	for _, move := range moves {
+		got = append(got, move.GetUCINotation())
+	}
+	want := []string{"b5c6", "d4c6", "c1c6", "d4e6"}
+	if len(got) < len(want) {
+		t.Fatalf("got %v, want it to start with %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want it to start with %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,599 @@
+// Package engine picks moves for a chess.GameState using an alpha-beta search,
+// which runs on a chess.Position copy of the game for speed.
+package engine
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mattellis91/go-chess/book"
+	"github.com/mattellis91/go-chess/chess"
+)
+
+const (
+	// MateScore is the score of delivering checkmate now. Mates further away
+	// score one less for every extra ply.
+	MateScore    = 100000
+	DefaultDepth = 4
+	// MaxDepth bounds how deep Search goes when Limits.Depth is not set.
+	MaxDepth = 64
+	infinity = MateScore + 1
+)
+
+// DefaultBookPlies is how far into the game NewEngine plays from Book.
+const DefaultBookPlies = 20
+
+type Engine struct {
+	// OnInfo, if set, is called after each completed depth.
+	OnInfo func(Info)
+	// TT is kept from one search to the next. It may be nil.
+	TT      *TranspositionTable
+	Options Options
+	// Threads is how many goroutines Search runs at once. They share TT, so
+	// each gains from what the others find. Below 2 it searches on the
+	// calling goroutine alone.
+	Threads int
+	// Book, if set, is consulted before searching in the first BookPlies
+	// plies of the game, or throughout if BookPlies is not positive.
+	Book          *book.Book
+	BookPlies     int
+	BookSelection book.Selection
+	// Tablebase, if set, scores positions it knows without searching them,
+	// and at the root narrows the search to the moves it says are best.
+	Tablebase chess.Tablebase
+}
+
+// Options switch parts of the search on and off, so what each one is worth
+// can be measured by playing the engine against itself with it turned off.
+type Options struct {
+	// PVS searches every move after the first with a null window.
+	PVS bool
+	// AspirationWindows start each depth with a narrow window around the
+	// last score.
+	AspirationWindows bool
+	// NullMove prunes positions where passing still fails high.
+	NullMove bool
+	// LateMoveReductions search quiet moves late in the order less deeply.
+	LateMoveReductions bool
+	// CheckExtensions search one ply deeper when in check.
+	CheckExtensions bool
+	// KillerMoves and HistoryHeuristic order quiet moves that caused
+	// cutoffs elsewhere in the tree first.
+	KillerMoves      bool
+	HistoryHeuristic bool
+}
+
+// DefaultOptions has everything switched on.
+func DefaultOptions() Options {
+	return Options{
+		PVS:                true,
+		AspirationWindows:  true,
+		NullMove:           true,
+		LateMoveReductions: true,
+		CheckExtensions:    true,
+		KillerMoves:        true,
+		HistoryHeuristic:   true,
+	}
+}
+
+func NewEngine() *Engine {
+	return &Engine{TT: NewTranspositionTable(DefaultHashMB), Options: DefaultOptions(), BookPlies: DefaultBookPlies}
+}
+
+// Limits tell Search when to stop. Search finishes the first depth whatever
+// the limits, so it always has a move to return, and then stops at whichever
+// limit it reaches first. With no limits set it searches to MaxDepth.
+//
+// On the clock it aims to spend its share of the time left until the next
+// time control, taking longer when the best move keeps changing or the score
+// drops, but it abandons a depth rather than go over a hard limit.
+type Limits struct {
+	Depth    int
+	MoveTime time.Duration
+	// WhiteTime and BlackTime are the time left on each clock and WhiteInc
+	// and BlackInc the increments, as in the UCI go command.
+	WhiteTime time.Duration
+	BlackTime time.Duration
+	WhiteInc  time.Duration
+	BlackInc  time.Duration
+	// MovesToGo is the number of moves until the next time control, or 0 if
+	// the rest of the game must be played on the clock.
+	MovesToGo int
+	// Infinite ignores every limit but Stop.
+	Infinite bool
+	// Stop ends the search when it is closed.
+	Stop <-chan struct{}
+}
+
+// Info describes the result of one completed depth.
+type Info struct {
+	Depth int
+	Score int
+	Nodes int
+	Time  time.Duration
+	PV    []chess.Move
+	// Hashfull is how full the transposition table is, in permille.
+	Hashfull int
+	// TBHits is how many positions were scored by the tablebase.
+	TBHits int
+}
+
+type SearchResult struct {
+	Move  chess.Move
+	Score int
+	Depth int
+	Nodes int
+	PV    []chess.Move
+	// FromBook is set when Move came from the opening book, unsearched.
+	FromBook bool
+}
+
+// search holds the state of one thread of a call to Search.
+type search struct {
+	pos      *chess.Position
+	shared   *shared
+	tt       *TranspositionTable
+	options  Options
+	limits   Limits
+	start    time.Time
+	time     *timeManager
+	deadline time.Time
+	nodes    int
+	// canStop is set once the first depth is complete.
+	canStop bool
+	helper  bool
+	aborted bool
+	prevPV  []chess.Move
+	// rootSearches counts the searches of the root, aspiration re-searches
+	// included.
+	rootSearches int
+	pv           [MaxDepth + 1][MaxDepth + 1]chess.Move
+	pvLength     [MaxDepth + 1]int
+	// killers holds the MoveIds of the last two quiet moves to cause a beta
+	// cutoff at each ply, and history how often each quiet move has, by side
+	// to move, start square and end square.
+	killers [MaxDepth + 1][2]int
+	history [2][64][64]int
+	// tablebase scores positions in the tree, and rootMoves, if not nil,
+	// are the only moves searched at the root.
+	tablebase chess.Tablebase
+	rootMoves []chess.Move
+}
+
+// shared is the state the threads of one call to Search have in common.
+type shared struct {
+	// stopped tells the helper threads the main thread has finished.
+	stopped atomic.Bool
+	nodes   atomic.Int64
+	tbHits  atomic.Int64
+}
+
+// Search looks ahead from the position in gs one depth at a time until limits
+// says to stop, and returns the best move from the last depth it completed
+// with its score for the side to move. gs itself is not modified, so Search
+// can run on its own goroutine while the caller keeps gs. If there are no
+// legal moves the result has a zero Move.
+//
+// With Threads above 1 the extra threads search the same position on copies
+// of gs, Lazy SMP style: they do not divide the work, but what they store in
+// the transposition table lets the main thread search faster. Only the main
+// thread decides when to stop and which move to play.
+//
+// If the position is in Book, Search returns a book move at once, unless the
+// search is infinite.
+func (e *Engine) Search(gs *chess.GameState, limits Limits) SearchResult {
+	if move, ok := e.bookMove(gs, limits); ok {
+		return SearchResult{Move: move, PV: []chess.Move{move}, FromBook: true}
+	}
+
+	sh := &shared{}
+	start := time.Now()
+	e.TT.newSearch()
+	rootMoves := tablebaseRootMoves(gs.Clone(), e.Tablebase)
+
+	var helpers sync.WaitGroup
+	for id := 1; id < e.Threads; id++ {
+		helper := e.newSearch(gs, limits, sh, start)
+		helper.rootMoves = rootMoves
+		// Helpers have no move to return, so they may stop at any time, and
+		// they leave managing the time to the main thread.
+		helper.canStop, helper.helper = true, true
+		helpers.Add(1)
+		go func(id int) {
+			defer helpers.Done()
+			// Starting odd helpers a depth further on spreads the threads
+			// across more of the tree.
+			helper.iterate(1+id%2, MaxDepth, nil)
+		}(id)
+	}
+
+	s := e.newSearch(gs, limits, sh, start)
+	s.rootMoves = rootMoves
+	maxDepth := MaxDepth
+	if limits.Depth > 0 && limits.Depth < MaxDepth && !limits.Infinite {
+		maxDepth = limits.Depth
+	}
+	result := s.iterate(1, maxDepth, e.OnInfo)
+
+	sh.stopped.Store(true)
+	helpers.Wait()
+	result.Nodes = int(sh.nodes.Load())
+	return result
+}
+
+func (e *Engine) bookMove(gs *chess.GameState, limits Limits) (chess.Move, bool) {
+	if e.Book == nil || limits.Infinite {
+		return chess.Move{}, false
+	}
+	ply := 2 * (gs.FullmoveNumber - 1)
+	if !gs.WhiteToMove {
+		ply++
+	}
+	if e.BookPlies > 0 && ply >= e.BookPlies {
+		return chess.Move{}, false
+	}
+	// A book that cannot be read is no worse than no book.
+	move, ok, err := e.Book.Choose(gs, e.BookSelection)
+	return move, ok && err == nil
+}
+
+func (e *Engine) newSearch(gs *chess.GameState, limits Limits, sh *shared, start time.Time) *search {
+	s := &search{
+		pos:       chess.NewPosition(gs),
+		shared:    sh,
+		tt:        e.TT,
+		options:   e.Options,
+		tablebase: e.Tablebase,
+		limits:    limits,
+		start:     start,
+		time:      newTimeManager(limits, gs.WhiteToMove),
+	}
+	if s.time.maximum > 0 {
+		s.deadline = start.Add(s.time.maximum)
+	}
+	return s
+}
+
+// iterate searches one depth at a time from firstDepth to lastDepth until it
+// is told to stop, calling onInfo, if it is not nil, after each depth.
+func (s *search) iterate(firstDepth int, lastDepth int, onInfo func(Info)) SearchResult {
+	defer func() {
+		// Count the nodes countNode has not yet added to the total.
+		s.shared.nodes.Add(int64(s.nodes & 1023))
+	}()
+
+	result := SearchResult{}
+	for depth := firstDepth; depth <= lastDepth; depth++ {
+		score := s.searchRoot(s.pos, depth, result.Score)
+		if s.aborted {
+			break
+		}
+
+		result.Score, result.Depth = score, depth
+		result.PV = append([]chess.Move(nil), s.pv[0][:s.pvLength[0]]...)
+		if len(result.PV) == 0 {
+			// Checkmate or stalemate: there is nothing to search.
+			break
+		}
+		result.Move = result.PV[0]
+		if onInfo != nil {
+			onInfo(Info{Depth: depth, Score: score, Nodes: s.totalNodes(), Time: time.Since(s.start), PV: result.PV, Hashfull: s.tt.Hashfull(), TBHits: int(s.shared.tbHits.Load())})
+		}
+
+		s.time.update(result.Move.MoveId, score)
+		if s.shouldStop() || (!s.helper && s.time.stopAfterDepth(time.Since(s.start))) {
+			break
+		}
+		s.canStop = true
+		s.prevPV = result.PV
+	}
+	return result
+}
+
+// countNode counts a node. Every 1024 nodes it adds them to the total for all
+// threads and checks whether to stop, unless this is the main thread and it
+// has yet to finish a depth, so there is always a move to return.
+func (s *search) countNode() {
+	s.nodes++
+	if s.nodes&1023 == 0 {
+		s.shared.nodes.Add(1024)
+		if s.canStop && s.shouldStop() {
+			s.aborted = true
+		}
+	}
+}
+
+// totalNodes returns the nodes searched so far by every thread, give or take
+// those the other threads have yet to add to the total.
+func (s *search) totalNodes() int {
+	return int(s.shared.nodes.Load()) + s.nodes&1023
+}
+
+// searchRoot searches the root to depth. With aspiration windows it first
+// tries a narrow window around the score of the previous depth, and widens it
+// each time the score falls outside.
+func (s *search) searchRoot(p *chess.Position, depth int, prevScore int) int {
+	if !s.options.AspirationWindows || depth < 4 {
+		return s.negamax(p, depth, 0, -infinity, infinity, false)
+	}
+	window := 50
+	alpha, beta := prevScore-window, prevScore+window
+	for {
+		s.rootSearches++
+		score := s.negamax(p, depth, 0, alpha, beta, false)
+		switch {
+		case s.aborted:
+			return 0
+		case score <= alpha:
+			window *= 2
+			alpha = prevScore - window
+		case score >= beta:
+			window *= 2
+			beta = prevScore + window
+		default:
+			return score
+		}
+		if window > 1000 {
+			alpha, beta = -infinity, infinity
+		}
+	}
+}
+
+// shouldStop reports whether the limits have been reached, or the main
+// thread has finished.
+func (s *search) shouldStop() bool {
+	if s.shared.stopped.Load() {
+		return true
+	}
+	select {
+	case <-s.limits.Stop:
+		return true
+	default:
+	}
+	return !s.deadline.IsZero() && time.Now().After(s.deadline)
+}
+
+// negamax returns the score of the position for the side to move, searching
+// depth more plies. ply counts the moves made since the root. afterNull is set
+// when the last move was a null move, so two are not made in a row.
+func (s *search) negamax(p *chess.Position, depth int, ply int, alpha int, beta int, afterNull bool) int {
+	s.countNode()
+	s.pvLength[ply] = 0
+	if s.aborted {
+		return 0
+	}
+
+	if ply > 0 && (p.HalfmoveClock >= 100 || p.RepetitionCount() > 1) {
+		return 0
+	}
+	inCheck := p.InCheck()
+	if inCheck && s.options.CheckExtensions {
+		// Look one ply further rather than stop while in check.
+		depth++
+	}
+	if depth <= 0 || ply >= MaxDepth {
+		return s.quiesce(p, ply, alpha, beta)
+	}
+	if ply > 0 {
+		if score, ok := s.probeTablebase(p, ply); ok {
+			return score
+		}
+	}
+
+	// A deep enough earlier search of this position may settle it. The root
+	// is always searched so it has a principal variation.
+	ttMove := 0
+	if entry, ok := s.tt.probe(p.Hash); ok {
+		ttMove = int(entry.move)
+		if ply > 0 && int(entry.depth) >= depth {
+			score := scoreFromTT(int(entry.score), ply)
+			switch Bound(entry.flags & 3) {
+			case BoundExact:
+				return score
+			case BoundLower:
+				if score >= beta {
+					return score
+				}
+			case BoundUpper:
+				if score <= alpha {
+					return score
+				}
+			}
+		}
+	}
+
+	// Null move pruning: if passing still scores at least beta, a real move
+	// surely would too. In zugzwang passing would be the best move, so it is
+	// not tried with only pawns left, nor twice in a row, nor in check.
+	if s.options.NullMove && !afterNull && !inCheck && ply > 0 && depth >= 3 &&
+		beta < TablebaseWinScore-MaxDepth && hasPieces(p) && Evaluate(p) >= beta {
+		reduction := 2
+		if depth > 6 {
+			reduction = 3
+		}
+		p.MakeNullMove()
+		score := -s.negamax(p, depth-1-reduction, ply+1, -beta, -beta+1, true)
+		p.UndoNullMove()
+		if s.aborted {
+			return 0
+		}
+		if score >= beta {
+			if score >= TablebaseWinScore-MaxDepth {
+				// A mate or tablebase win found after passing is not a
+				// real one.
+				return beta
+			}
+			return score
+		}
+	}
+
+	moves := p.GetValidMoves()
+	if ply == 0 && s.rootMoves != nil {
+		moves = append([]chess.Move(nil), s.rootMoves...)
+	}
+	if len(moves) == 0 {
+		if inCheck {
+			return -MateScore + ply
+		}
+		return 0
+	}
+	var pvMove chess.Move
+	if ply < len(s.prevPV) {
+		pvMove = s.prevPV[ply]
+	}
+	s.orderMoves(moves, p.WhiteToMove, ply, pvMove, ttMove)
+
+	alphaOrig := alpha
+	bestScore := -infinity
+	var bestMove chess.Move
+	for i, move := range moves {
+		quiet := !isTactical(move) && move.MoveId != s.killers[ply][0] && move.MoveId != s.killers[ply][1]
+		p.MakeMove(move)
+
+		// The first move is searched in full. With PVS the rest only have
+		// to be shown to be worse than it, which a null window does cheaply,
+		// and with LMR late quiet moves are searched less deeply at first.
+		// Either is searched again in full if the move turns out better.
+		reduction := 0
+		if s.options.LateMoveReductions && i >= 3 && depth >= 3 && quiet && !inCheck && !p.InCheck() {
+			reduction = 1
+			if i >= 6 && depth >= 6 {
+				reduction = 2
+			}
+		}
+		var score int
+		if i == 0 || (!s.options.PVS && reduction == 0) {
+			score = -s.negamax(p, depth-1, ply+1, -beta, -alpha, false)
+		} else {
+			windowBeta := beta
+			if s.options.PVS {
+				windowBeta = alpha + 1
+			}
+			score = -s.negamax(p, depth-1-reduction, ply+1, -windowBeta, -alpha, false)
+			if score > alpha && reduction > 0 {
+				score = -s.negamax(p, depth-1, ply+1, -windowBeta, -alpha, false)
+			}
+			if score > alpha && score < beta && windowBeta != beta {
+				score = -s.negamax(p, depth-1, ply+1, -beta, -alpha, false)
+			}
+		}
+		p.UndoMove()
+		if s.aborted {
+			return 0
+		}
+
+		if score > bestScore {
+			bestScore, bestMove = score, move
+		}
+		if score > alpha {
+			alpha = score
+			s.pv[ply][0] = move
+			copy(s.pv[ply][1:], s.pv[ply+1][:s.pvLength[ply+1]])
+			s.pvLength[ply] = s.pvLength[ply+1] + 1
+		}
+		if alpha >= beta {
+			if !isTactical(move) {
+				s.rememberCutoff(move, p.WhiteToMove, ply, depth)
+			}
+			break
+		}
+	}
+
+	switch {
+	case bestScore >= beta:
+		s.tt.store(p.Hash, depth, ply, bestScore, BoundLower, bestMove)
+	case bestScore > alphaOrig:
+		s.tt.store(p.Hash, depth, ply, bestScore, BoundExact, bestMove)
+	default:
+		// Every move failed low, so none of them is known to be best.
+		s.tt.store(p.Hash, depth, ply, bestScore, BoundUpper, chess.Move{})
+	}
+	return bestScore
+}
+
+// hasPieces reports whether the side to move has anything besides its king
+// and pawns.
+func hasPieces(p *chess.Position) bool {
+	pieces := &p.Pieces[side(p.WhiteToMove)]
+	return pieces[chess.Knight]|pieces[chess.Bishop]|pieces[chess.Rook]|pieces[chess.Queen] != 0
+}
+
+// rememberCutoff records a quiet move that caused a beta cutoff as a killer
+// for ply and in the history table, so it is tried early elsewhere.
+func (s *search) rememberCutoff(move chess.Move, whiteToMove bool, ply int, depth int) {
+	if s.options.KillerMoves && s.killers[ply][0] != move.MoveId {
+		s.killers[ply][1] = s.killers[ply][0]
+		s.killers[ply][0] = move.MoveId
+	}
+	if s.options.HistoryHeuristic {
+		h := &s.history[side(whiteToMove)][move.StartRow*8+move.StartCol][move.EndRow*8+move.EndCol]
+		*h += depth * depth
+		if *h >= maxHistory {
+			// Keep history below the killers by halving all of it.
+			for c := range s.history {
+				for from := range s.history[c] {
+					for to := range s.history[c][from] {
+						s.history[c][from][to] /= 2
+					}
+				}
+			}
+		}
+	}
+}
+
+func side(whiteToMove bool) int {
+	if whiteToMove {
+		return 0
+	}
+	return 1
+}
+
+const maxHistory = 1 << 16
+
+// orderMoves puts the transposition table's best move first, then the move
+// from the previous principal variation, then promotions and captures in
+// MVV-LVA order, then the killer moves and the other quiet moves by history,
+// so alpha-beta finds cutoffs sooner. ttMove is a MoveId, or 0 for none.
+func (s *search) orderMoves(moves []chess.Move, whiteToMove bool, ply int, pvMove chess.Move, ttMove int) {
+	order := make([]int, len(moves))
+	for i, move := range moves {
+		order[i] = s.moveOrder(move, whiteToMove, ply, pvMove, ttMove)
+	}
+	sort.Stable(byOrder{moves, order})
+}
+
+func (s *search) moveOrder(move chess.Move, whiteToMove bool, ply int, pvMove chess.Move, ttMove int) int {
+	switch {
+	case ttMove != 0 && move.MoveId == ttMove:
+		return 1 << 30
+	case pvMove.PieceMoved != "" && move.MoveId == pvMove.MoveId:
+		return 1 << 29
+	case isTactical(move):
+		return 1<<20 + mvvLva(move)
+	case move.MoveId == s.killers[ply][0]:
+		return maxHistory + 1
+	case move.MoveId == s.killers[ply][1]:
+		return maxHistory
+	default:
+		return s.history[side(whiteToMove)][move.StartRow*8+move.StartCol][move.EndRow*8+move.EndCol]
+	}
+}
+
+// byOrder sorts moves by their order, highest first.
+type byOrder struct {
+	moves []chess.Move
+	order []int
+}
+
+func (b byOrder) Len() int           { return len(b.moves) }
+func (b byOrder) Less(i, j int) bool { return b.order[i] > b.order[j] }
+func (b byOrder) Swap(i, j int) {
+	b.moves[i], b.moves[j] = b.moves[j], b.moves[i]
+	b.order[i], b.order[j] = b.order[j], b.order[i]
+}
+
+func isTactical(move chess.Move) bool {
+	return move.IsPawnPromotion || move.IsEnPassant || move.PieceCaptured != "--"
+}
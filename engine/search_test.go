@@ -0,0 +1,224 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/mattellis91/go-chess/book"
+	"github.com/mattellis91/go-chess/chess"
+)
+
+func searchFEN(t *testing.T, fen string, depth int) SearchResult {
+	t.Helper()
+	gs, err := chess.NewGameStateFromFEN(fen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := gs.FEN()
+	result := NewEngine().Search(gs, Limits{Depth: depth})
+	if gs.FEN() != before {
+		t.Errorf("Search changed the position to %q", gs.FEN())
+	}
+	return result
+}
+
+func TestSearchFindsMate(t *testing.T) {
+	tests := []struct {
+		fen   string
+		depth int
+		move  string
+		score int
+	}{
+		// Back rank mate.
+		{"6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1", 2, "a1a8", MateScore - 1},
+		// Scholar's mate.
+		{"r1bqkbnr/pppp1ppp/2n5/4p3/2B1P3/5Q2/PPPP1PPP/RNB1K1NR w KQkq - 2 3", 3, "f3f7", MateScore - 1},
+		// Mate in two with the rooks, which has more than one solution.
+		{"7k/8/8/8/8/8/R7/1R5K w - - 0 1", 4, "", MateScore - 3},
+	}
+	for _, test := range tests {
+		result := searchFEN(t, test.fen, test.depth)
+		if (test.move != "" && result.Move.GetUCINotation() != test.move) || result.Score != test.score {
+			t.Errorf("%s: got %s with score %d, want %s with score %d",
+				test.fen, result.Move.GetUCINotation(), result.Score, test.move, test.score)
+		}
+	}
+}
+
+func TestSearchWinsMaterial(t *testing.T) {
+	result := searchFEN(t, "4k3/8/8/8/3q4/8/4N3/4K3 w - - 0 1", 2)
+	if result.Move.GetUCINotation() != "e2d4" {
+		t.Errorf("got %s, want the free queen on d4", result.Move.GetUCINotation())
+	}
+}
+
+func TestSearchWithoutMoves(t *testing.T) {
+	result := searchFEN(t, "7k/5Q2/6K1/8/8/8/8/8 b - - 0 1", 3)
+	if result.Move.PieceMoved != "" || result.Score != 0 {
+		t.Errorf("stalemate: got move %q with score %d", result.Move.GetUCINotation(), result.Score)
+	}
+}
+
+func TestEvaluateIsSymmetric(t *testing.T) {
+	white, _ := chess.NewPositionFromFEN("r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3")
+	black, _ := chess.NewPositionFromFEN("rnbqkb1r/pppp1ppp/5n2/4p3/4P3/2N5/PPPP1PPP/R1BQKBNR b KQkq - 2 3")
+	if Evaluate(white) != Evaluate(black) {
+		t.Errorf("mirrored positions score %d and %d", Evaluate(white), Evaluate(black))
+	}
+}
+
+func TestSearchReportsEachDepth(t *testing.T) {
+	gs := chess.NewGameState()
+	e := NewEngine()
+	depths := []int{}
+	e.OnInfo = func(info Info) {
+		depths = append(depths, info.Depth)
+		if len(info.PV) == 0 || info.Nodes == 0 {
+			t.Errorf("depth %d: PV %v, %d nodes", info.Depth, info.PV, info.Nodes)
+		}
+	}
+	result := e.Search(gs, Limits{Depth: 3})
+	if len(depths) != 3 || depths[2] != 3 || result.Depth != 3 {
+		t.Errorf("reported depths %v, result depth %d", depths, result.Depth)
+	}
+	if result.Move.MoveId != result.PV[0].MoveId {
+		t.Errorf("best move %s is not the start of the PV", result.Move.GetUCINotation())
+	}
+}
+
+func TestSearchStops(t *testing.T) {
+	gs := chess.NewGameState()
+
+	start := time.Now()
+	result := NewEngine().Search(gs, Limits{MoveTime: 100 * time.Millisecond})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("movetime 100ms took %v", elapsed)
+	}
+	if result.Move.PieceMoved == "" {
+		t.Error("no move after movetime")
+	}
+
+	stop := make(chan struct{})
+	time.AfterFunc(100*time.Millisecond, func() { close(stop) })
+	start = time.Now()
+	result = NewEngine().Search(gs, Limits{Infinite: true, Stop: stop})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("stopping an infinite search took %v", elapsed)
+	}
+	if result.Move.PieceMoved == "" {
+		t.Error("no move after stop")
+	}
+}
+
+func TestSearchOptions(t *testing.T) {
+	mates := []struct {
+		fen   string
+		depth int
+		score int
+	}{
+		{"6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1", 2, MateScore - 1},
+		{"7k/8/8/8/8/8/R7/1R5K w - - 0 1", 4, MateScore - 3},
+		{"r1bqkbnr/pppp1ppp/2n5/4p3/2B1P3/5Q2/PPPP1PPP/RNB1K1NR w KQkq - 2 3", 5, MateScore - 1},
+	}
+	switches := map[string]func(*Options){
+		"PVS":                func(o *Options) { o.PVS = false },
+		"AspirationWindows":  func(o *Options) { o.AspirationWindows = false },
+		"NullMove":           func(o *Options) { o.NullMove = false },
+		"LateMoveReductions": func(o *Options) { o.LateMoveReductions = false },
+		"CheckExtensions":    func(o *Options) { o.CheckExtensions = false },
+		"KillerMoves":        func(o *Options) { o.KillerMoves = false },
+		"HistoryHeuristic":   func(o *Options) { o.HistoryHeuristic = false },
+		"everything":         func(o *Options) { *o = Options{} },
+	}
+	for name, turnOff := range switches {
+		e := NewEngine()
+		turnOff(&e.Options)
+		for _, mate := range mates {
+			gs, _ := chess.NewGameStateFromFEN(mate.fen)
+			e.TT.Clear()
+			if result := e.Search(gs, Limits{Depth: mate.depth}); result.Score != mate.score {
+				t.Errorf("without %s: %s scored %d, want %d", name, mate.fen, result.Score, mate.score)
+			}
+		}
+	}
+}
+
+func TestSearchOptionsSaveNodes(t *testing.T) {
+	gs, _ := chess.NewGameStateFromFEN("r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4")
+	plain := &Engine{TT: NewTranspositionTable(1)}
+	pruned := &Engine{TT: NewTranspositionTable(1), Options: DefaultOptions()}
+	plainResult := plain.Search(gs, Limits{Depth: 5})
+	prunedResult := pruned.Search(gs, Limits{Depth: 5})
+	if prunedResult.Nodes >= plainResult.Nodes {
+		t.Errorf("searched %d nodes with every option and %d with none", prunedResult.Nodes, plainResult.Nodes)
+	}
+}
+
+// TestAspirationWindowGrows checks that each re-search after the score falls
+// outside the window tries a wider one, by searching the root as if the last
+// depth had scored 500 above or below what it really is. The windows of 50,
+// 100, 200 and 400 either side all miss, and the fifth, of 800, holds it.
+func TestAspirationWindowGrows(t *testing.T) {
+	gs, _ := chess.NewGameStateFromFEN("r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4")
+	e := NewEngine()
+	s := e.newSearch(gs, Limits{}, &shared{}, time.Now())
+	score := s.negamax(s.pos, 4, 0, -infinity, infinity, false)
+
+	for _, jump := range []int{500, -500} {
+		e := NewEngine()
+		s := e.newSearch(gs, Limits{}, &shared{}, time.Now())
+		got := s.searchRoot(s.pos, 4, score+jump)
+		if s.rootSearches != 5 {
+			t.Errorf("score %d, %d from the last depth: searched the root %d times, want 5", got, -jump, s.rootSearches)
+		}
+	}
+}
+
+func TestHasPieces(t *testing.T) {
+	tests := map[string]bool{
+		"4k3/pppp4/8/8/8/8/PPPP4/4K3 w - - 0 1":   false,
+		"4k3/pppp4/8/8/8/8/PPPP4/4K1N1 w - - 0 1": true,
+		"4k3/pppp4/8/8/8/8/PPPP4/4K1N1 b - - 0 1": false,
+	}
+	for fen, want := range tests {
+		p, _ := chess.NewPositionFromFEN(fen)
+		if got := hasPieces(p); got != want {
+			t.Errorf("%s: hasPieces = %v, want %v", fen, got, want)
+		}
+	}
+}
+
+func TestSearchPlaysBookMoves(t *testing.T) {
+	gs := chess.NewGameState()
+	move, _ := gs.Clone().ParseMove("a3")
+	var data [16]byte
+	binary.BigEndian.PutUint64(data[:8], gs.Hash)
+	binary.BigEndian.PutUint16(data[8:10], book.EncodeMove(move))
+	binary.BigEndian.PutUint16(data[10:12], 1)
+	b, err := book.New(bytes.NewReader(data[:]), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := NewEngine()
+	e.Book = b
+	if result := e.Search(gs, Limits{Depth: 1}); !result.FromBook || result.Move.GetUCINotation() != "a2a3" {
+		t.Errorf("got %s from the book: %v", result.Move.GetUCINotation(), result.FromBook)
+	}
+	if result := e.Search(gs, Limits{Infinite: true, Depth: 1, Stop: closed()}); result.FromBook {
+		t.Error("an infinite search played from the book")
+	}
+	e.BookPlies = 1
+	gs.FullmoveNumber = 2
+	if result := e.Search(gs, Limits{Depth: 1}); result.FromBook {
+		t.Error("played from the book after BookPlies")
+	}
+}
+
+func closed() chan struct{} {
+	stop := make(chan struct{})
+	close(stop)
+	return stop
+}
@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/mattellis91/go-chess/chess"
+)
+
+func TestSearchThreads(t *testing.T) {
+	e := NewEngine()
+	e.Threads = 4
+	infoNodes := 0
+	e.OnInfo = func(info Info) {
+		infoNodes = info.Nodes
+	}
+
+	gs, _ := chess.NewGameStateFromFEN("7k/8/8/8/8/8/R7/1R5K w - - 0 1")
+	if result := e.Search(gs, Limits{Depth: 4}); result.Score != MateScore-3 {
+		t.Errorf("with 4 threads the mate in two scored %d", result.Score)
+	}
+
+	gs = chess.NewGameState()
+	start := time.Now()
+	result := e.Search(gs, Limits{MoveTime: 300 * time.Millisecond})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("movetime 300ms took %v with 4 threads", elapsed)
+	}
+	if _, err := gs.Clone().ParseMove(result.Move.GetUCINotation()); err != nil {
+		t.Errorf("with 4 threads the move was %q: %v", result.Move.GetUCINotation(), err)
+	}
+	if infoNodes == 0 || result.Nodes < infoNodes {
+		t.Errorf("search reported %d nodes after its last depth and %d at the end", infoNodes, result.Nodes)
+	}
+
+	// The helpers count too: more threads search more nodes in the same time.
+	if runtime.NumCPU() < 2 {
+		return
+	}
+	single := NewEngine().Search(gs, Limits{MoveTime: 300 * time.Millisecond})
+	if result.Nodes <= single.Nodes {
+		t.Errorf("4 threads searched %d nodes and 1 thread %d", result.Nodes, single.Nodes)
+	}
+}
+
+func TestSearchThreadsStop(t *testing.T) {
+	e := NewEngine()
+	e.Threads = 3
+	stop := make(chan struct{})
+	time.AfterFunc(100*time.Millisecond, func() { close(stop) })
+	start := time.Now()
+	result := e.Search(chess.NewGameState(), Limits{Infinite: true, Stop: stop})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("stopping 3 threads took %v", elapsed)
+	}
+	if result.Move.PieceMoved == "" {
+		t.Error("no move after stop")
+	}
+}
@@ -0,0 +1,116 @@
+package engine
+
+import "github.com/mattellis91/go-chess/chess"
+
+// TablebaseWinScore is the score of a position the tablebase says is won,
+// less a ply for every move from the root. It is below every mate score, so
+// the search still prefers a mate it can see.
+const TablebaseWinScore = MateScore - 2*MaxDepth
+
+// TablebaseWinCentipawns is what a tablebase win is reported as to a GUI,
+// which would take TablebaseWinScore for a mate or a misprint.
+const TablebaseWinCentipawns = 20000
+
+// ReportedCentipawns returns a score that is not a mate as centipawns for a
+// GUI, bringing tablebase wins and losses down to TablebaseWinCentipawns less
+// a centipawn for each ply to reach them.
+func ReportedCentipawns(score int) int {
+	switch {
+	case score >= TablebaseWinScore-MaxDepth:
+		return score - TablebaseWinScore + TablebaseWinCentipawns
+	case score <= -TablebaseWinScore+MaxDepth:
+		return score + TablebaseWinScore - TablebaseWinCentipawns
+	}
+	return score
+}
+
+// probeTablebase returns the score of p from the tablebase, if it knows the
+// position. Tablebases assume the halfmove clock was just reset, so they are
+// only asked right after a capture or pawn move.
+func (s *search) probeTablebase(p *chess.Position, ply int) (int, bool) {
+	if s.tablebase == nil || p.HalfmoveClock != 0 || p.PieceCount() > s.tablebase.MaxPieces() {
+		return 0, false
+	}
+	wdl, ok := s.tablebase.ProbeWDL(p.GameState())
+	if !ok {
+		return 0, false
+	}
+	s.shared.tbHits.Add(1)
+	switch wdl {
+	case chess.WDLWin:
+		return TablebaseWinScore - ply, true
+	case chess.WDLLoss:
+		return -TablebaseWinScore + ply, true
+	}
+	// Cursed wins and blessed losses are draws, but a draw the other side
+	// has to work for.
+	return int(wdl), true
+}
+
+// zeroingTablebase is a tablebase whose distances, like Syzygy's DTZ, run to
+// the next capture or pawn move rather than to mate.
+type zeroingTablebase interface {
+	DistanceToZeroing() bool
+}
+
+// tablebaseRootMoves returns the moves from gs that keep the tablebase result
+// for the side to move, or nil if the tablebase does not know every position
+// they lead to. If it knows the distances too, it keeps only the moves that
+// win fastest or lose slowest, so the search cannot wander about a won
+// position without making progress.
+func tablebaseRootMoves(gs *chess.GameState, tb chess.Tablebase) []chess.Move {
+	legal := gs.GetValidMoves()
+	if tb == nil || len(legal) == 0 || gs.PieceCount() > tb.MaxPieces() {
+		return nil
+	}
+	// Where the distances run to a capture or pawn move, making one is as
+	// far as a distance goes.
+	zeroing, _ := tb.(zeroingTablebase)
+	toZeroing := zeroing != nil && zeroing.DistanceToZeroing()
+	type rootMove struct {
+		move     chess.Move
+		wdl      chess.WDL
+		distance int
+	}
+	moves := []rootMove{}
+	best, haveDistances := chess.WDLLoss, true
+	for _, move := range legal {
+		gs.MakeMove(move)
+		wdl, ok := tb.ProbeWDL(gs)
+		distance, hasDistance := tb.ProbeDistance(gs)
+		gs.UndoMove()
+		if !ok {
+			return nil
+		}
+		if toZeroing && (move.PieceCaptured != "--" || move.IsEnPassant || move.PieceMoved[1] == 'p') {
+			distance = 0
+		}
+		haveDistances = haveDistances && hasDistance
+		moves = append(moves, rootMove{move, -wdl, distance})
+		if -wdl > best {
+			best = -wdl
+		}
+	}
+
+	kept := []chess.Move{}
+	bestDistance := -1
+	for _, m := range moves {
+		if m.wdl != best {
+			continue
+		}
+		if haveDistances && best != chess.WDLDraw {
+			better := m.distance < bestDistance
+			if best < chess.WDLDraw {
+				better = m.distance > bestDistance
+			}
+			if bestDistance >= 0 && m.distance != bestDistance && !better {
+				continue
+			}
+			if bestDistance < 0 || better {
+				kept, bestDistance = kept[:0], m.distance
+			}
+		}
+		kept = append(kept, m.move)
+	}
+	return kept
+}
@@ -0,0 +1,139 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/mattellis91/go-chess/chess"
+)
+
+// materialTablebase knows positions with up to three pieces and pretends the
+// side with more material wins.
+type materialTablebase struct{}
+
+func (materialTablebase) MaxPieces() int { return 3 }
+
+func (materialTablebase) ProbeWDL(gs *chess.GameState) (chess.WDL, bool) {
+	if gs.PieceCount() > 3 {
+		return 0, false
+	}
+	balance := 0
+	for r := 0; r < 8; r++ {
+		for c := 0; c < 8; c++ {
+			piece := gs.Board[r][c]
+			if piece == "--" || piece[1] == 'K' {
+				continue
+			}
+			if (piece[0] == 'w') == gs.WhiteToMove {
+				balance++
+			} else {
+				balance--
+			}
+		}
+	}
+	switch {
+	case balance > 0:
+		return chess.WDLWin, true
+	case balance < 0:
+		return chess.WDLLoss, true
+	}
+	return chess.WDLDraw, true
+}
+
+func (materialTablebase) ProbeDistance(gs *chess.GameState) (int, bool) { return 0, false }
+
+func TestSearchProbesTablebase(t *testing.T) {
+	gs, _ := chess.NewGameStateFromFEN("4k3/8/8/8/3r4/8/3Q4/4K3 w - - 0 1")
+	e := NewEngine()
+	e.Tablebase = materialTablebase{}
+	var last Info
+	e.OnInfo = func(info Info) { last = info }
+	result := e.Search(gs, Limits{Depth: 3})
+	if result.Move.GetUCINotation() != "d2d4" || result.Score != TablebaseWinScore-1 {
+		t.Errorf("got %s with score %d, want d2d4 with score %d", result.Move.GetUCINotation(), result.Score, TablebaseWinScore-1)
+	}
+	if last.TBHits == 0 {
+		t.Error("no tablebase hits reported")
+	}
+}
+
+// cornerTablebase knows positions with up to three pieces and pretends the
+// side to move loses when the white king is in the corner on a1, and draws
+// otherwise.
+type cornerTablebase struct{}
+
+func (cornerTablebase) MaxPieces() int { return 3 }
+
+func (cornerTablebase) ProbeWDL(gs *chess.GameState) (chess.WDL, bool) {
+	if gs.PieceCount() > 3 {
+		return 0, false
+	}
+	if gs.Board[7][0] == "wK" {
+		return chess.WDLLoss, true
+	}
+	return chess.WDLDraw, true
+}
+
+func (cornerTablebase) ProbeDistance(gs *chess.GameState) (int, bool) { return 0, false }
+
+func TestSearchKeepsToTablebaseMoves(t *testing.T) {
+	gs, _ := chess.NewGameStateFromFEN("4k3/8/8/8/8/8/1K6/7R w - - 0 1")
+	e := NewEngine()
+	if result := e.Search(gs, Limits{Depth: 3}); result.Move.GetUCINotation() == "b2a1" {
+		t.Fatal("the search went to the corner without the tablebase")
+	}
+	e.Tablebase = cornerTablebase{}
+	if result := e.Search(gs, Limits{Depth: 3}); result.Move.GetUCINotation() != "b2a1" {
+		t.Errorf("got %s, want b2a1", result.Move.GetUCINotation())
+	}
+}
+
+func TestTablebaseRootMovesByDistance(t *testing.T) {
+	gs, _ := chess.NewGameStateFromFEN("4k3/8/8/8/8/8/1K6/7R w - - 0 1")
+	moves := tablebaseRootMoves(gs, distanceTablebase{})
+	if len(moves) != 1 || moves[0].GetUCINotation() != "h1h8" {
+		t.Errorf("got %v, want only h1h8", moves)
+	}
+}
+
+// distanceTablebase pretends the side to move loses a rook ending, sooner the
+// nearer the rook is to the eighth rank.
+type distanceTablebase struct{}
+
+func (distanceTablebase) MaxPieces() int { return 3 }
+
+func (distanceTablebase) ProbeWDL(gs *chess.GameState) (chess.WDL, bool) {
+	if gs.WhiteToMove {
+		return chess.WDLWin, true
+	}
+	return chess.WDLLoss, true
+}
+
+func (distanceTablebase) ProbeDistance(gs *chess.GameState) (int, bool) {
+	for r := 0; r < 8; r++ {
+		for c := 0; c < 8; c++ {
+			if gs.Board[r][c] == "wR" {
+				return r, true
+			}
+		}
+	}
+	return 0, true
+}
+
+// dtzTablebase is a distanceTablebase whose distances run to the next
+// capture or pawn move.
+type dtzTablebase struct{ distanceTablebase }
+
+func (dtzTablebase) MaxPieces() int { return 4 }
+
+func (dtzTablebase) DistanceToZeroing() bool { return true }
+
+func TestTablebaseRootMovesToZeroing(t *testing.T) {
+	gs, _ := chess.NewGameStateFromFEN("4k3/8/8/8/8/8/1KP5/7R w - - 0 1")
+	got := map[string]bool{}
+	for _, move := range tablebaseRootMoves(gs, dtzTablebase{}) {
+		got[move.GetUCINotation()] = true
+	}
+	if len(got) != 3 || !got["c2c3"] || !got["c2c4"] || !got["h1h8"] {
+		t.Errorf("got %v, want the pawn moves and h1h8", got)
+	}
+}
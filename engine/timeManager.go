@@ -0,0 +1,110 @@
+package engine
+
+import "time"
+
+const (
+	// defaultMovesToGo is how many more moves Search assumes it must make on
+	// the clock when Limits.MovesToGo is not set.
+	defaultMovesToGo = 30
+	// maxTimeScale bounds how far an unstable search may stretch its optimum.
+	maxTimeScale = 2.5
+)
+
+// timeManager decides when a search on the clock should stop. optimum is the
+// time it would like to spend on the move and maximum the most it may spend.
+// The optimum is stretched while the best move keeps changing or the score is
+// falling, since those are the moves where thinking longer pays off.
+type timeManager struct {
+	optimum time.Duration
+	maximum time.Duration
+	// fixed is set for MoveTime, which is used in full.
+	fixed bool
+
+	instability float64
+	scoreDrop   int
+	lastMove    int
+	lastScore   int
+	iterations  int
+}
+
+// newTimeManager returns the time manager for limits with whiteToMove to play.
+// Both limits are 0 when there is no time limit.
+func newTimeManager(limits Limits, whiteToMove bool) *timeManager {
+	t := &timeManager{}
+	switch {
+	case limits.Infinite:
+	case limits.MoveTime > 0:
+		t.optimum, t.maximum, t.fixed = limits.MoveTime, limits.MoveTime, true
+	default:
+		left, inc := limits.WhiteTime, limits.WhiteInc
+		if !whiteToMove {
+			left, inc = limits.BlackTime, limits.BlackInc
+		}
+		if left <= 0 {
+			break
+		}
+		movesToGo := limits.MovesToGo
+		if movesToGo <= 0 || movesToGo > defaultMovesToGo {
+			movesToGo = defaultMovesToGo
+		}
+		t.optimum = left/time.Duration(movesToGo) + inc/2
+		// Never plan to use more than three quarters of the clock, even for
+		// the last move before the time control.
+		t.maximum = t.optimum * 5
+		if limit := left * 3 / 4; t.maximum > limit {
+			t.maximum = limit
+		}
+		if t.optimum > t.maximum {
+			t.optimum = t.maximum
+		}
+	}
+	return t
+}
+
+// update records the result of a completed depth.
+func (t *timeManager) update(moveId int, score int) {
+	t.instability /= 2
+	t.scoreDrop /= 2
+	if t.iterations > 0 {
+		if moveId != t.lastMove {
+			t.instability++
+		}
+		if drop := t.lastScore - score; drop > 0 {
+			t.scoreDrop += drop
+		}
+	}
+	t.lastMove, t.lastScore = moveId, score
+	t.iterations++
+}
+
+// scaled returns the optimum stretched for the instability seen so far.
+func (t *timeManager) scaled() time.Duration {
+	scale := 1 + t.instability/2
+	if t.scoreDrop > 0 {
+		drop := t.scoreDrop
+		if drop > 100 {
+			drop = 100
+		}
+		scale *= 1 + float64(drop)/200
+	}
+	if scale > maxTimeScale {
+		scale = maxTimeScale
+	}
+	if budget := time.Duration(float64(t.optimum) * scale); budget < t.maximum {
+		return budget
+	}
+	return t.maximum
+}
+
+// stopAfterDepth reports whether to stop rather than start another depth,
+// elapsed into the search. The next depth usually takes longer than all the
+// ones before it, so it is not started once half the budget is gone.
+func (t *timeManager) stopAfterDepth(elapsed time.Duration) bool {
+	switch {
+	case t.maximum == 0:
+		return false
+	case t.fixed:
+		return elapsed >= t.maximum
+	}
+	return elapsed >= t.scaled()/2
+}
@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattellis91/go-chess/chess"
+)
+
+func TestTimeBudget(t *testing.T) {
+	tests := []struct {
+		limits           Limits
+		whiteToMove      bool
+		optimum, maximum time.Duration
+	}{
+		{Limits{}, true, 0, 0},
+		{Limits{Infinite: true, WhiteTime: time.Minute}, true, 0, 0},
+		{Limits{MoveTime: time.Second, WhiteTime: time.Minute}, true, time.Second, time.Second},
+		{Limits{WhiteTime: time.Minute, BlackTime: time.Second}, true, 2 * time.Second, 10 * time.Second},
+		{Limits{WhiteTime: time.Second, BlackTime: time.Minute, BlackInc: 2 * time.Second}, false, 3 * time.Second, 15 * time.Second},
+		{Limits{WhiteTime: time.Minute, MovesToGo: 10}, true, 6 * time.Second, 30 * time.Second},
+		// The last move before the time control keeps something in hand.
+		{Limits{WhiteTime: 8 * time.Second, MovesToGo: 1}, true, 6 * time.Second, 6 * time.Second},
+	}
+	for _, test := range tests {
+		tm := newTimeManager(test.limits, test.whiteToMove)
+		if tm.optimum != test.optimum || tm.maximum != test.maximum {
+			t.Errorf("%+v: optimum %v maximum %v, want %v and %v", test.limits, tm.optimum, tm.maximum, test.optimum, test.maximum)
+		}
+	}
+}
+
+func TestTimeManagerExtends(t *testing.T) {
+	limits := Limits{WhiteTime: time.Minute}
+
+	stable := newTimeManager(limits, true)
+	for depth := 1; depth <= 5; depth++ {
+		stable.update(1234, 20)
+	}
+	if !stable.stopAfterDepth(time.Second) {
+		t.Error("a stable search carried on past half its optimum")
+	}
+
+	changing := newTimeManager(limits, true)
+	for depth := 1; depth <= 5; depth++ {
+		changing.update(1234+depth, 20)
+	}
+	if changing.stopAfterDepth(time.Second) {
+		t.Error("a search whose best move keeps changing did not take longer")
+	}
+
+	dropping := newTimeManager(limits, true)
+	for depth := 1; depth <= 5; depth++ {
+		dropping.update(1234, 20-40*depth)
+	}
+	if dropping.stopAfterDepth(time.Second) {
+		t.Error("a search whose score keeps dropping did not take longer")
+	}
+	if !dropping.stopAfterDepth(dropping.maximum) {
+		t.Error("extending went past the maximum")
+	}
+
+	fixed := newTimeManager(Limits{MoveTime: time.Second}, true)
+	if fixed.stopAfterDepth(900 * time.Millisecond) {
+		t.Error("movetime stopped early")
+	}
+}
+
+func TestSearchOnTheClock(t *testing.T) {
+	gs := chess.NewGameState()
+	start := time.Now()
+	result := NewEngine().Search(gs, Limits{WhiteTime: time.Second, BlackTime: time.Second, MovesToGo: 5})
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("used %v of a one second clock", elapsed)
+	}
+	if result.Move.PieceMoved == "" || result.Depth == 0 {
+		t.Error("no move on the clock")
+	}
+}
@@ -0,0 +1,189 @@
+package engine
+
+import (
+	"sync/atomic"
+
+	"github.com/mattellis91/go-chess/chess"
+)
+
+// DefaultHashMB is the size of the transposition table NewEngine makes.
+const DefaultHashMB = 16
+
+// Bound says how a stored score relates to the true score of a position.
+type Bound uint8
+
+const (
+	// BoundExact scores were searched with a full window.
+	BoundExact Bound = iota + 1
+	// BoundLower scores failed high: the position is worth at least this.
+	BoundLower
+	// BoundUpper scores failed low: the position is worth at most this.
+	BoundUpper
+)
+
+// ttEntry is what the table holds about a position. flags holds the Bound in
+// its low two bits and the generation of the search that stored it in the
+// rest.
+type ttEntry struct {
+	key   uint64
+	score int32
+	move  uint16
+	depth int8
+	flags uint8
+}
+
+// ttSlot stores an entry in two words: everything but the key packed into
+// data, and the key XORed with data in check. Threads read and write the
+// words without locking, so a slot may be torn between two writes, but then
+// check no longer matches the key and the entry is ignored.
+type ttSlot struct {
+	check atomic.Uint64
+	data  atomic.Uint64
+}
+
+const ttEntrySize = 16
+
+func (e ttEntry) pack() uint64 {
+	return uint64(uint32(e.score)) | uint64(e.move)<<32 | uint64(uint8(e.depth))<<48 | uint64(e.flags)<<56
+}
+
+func unpack(key uint64, data uint64) ttEntry {
+	return ttEntry{key: key, score: int32(uint32(data)), move: uint16(data >> 32), depth: int8(data >> 48), flags: uint8(data >> 56)}
+}
+
+// TranspositionTable remembers what earlier searches found out about
+// positions, keyed by Position.Hash, so the same position reached by a
+// different move order is not searched again. Any number of goroutines may
+// probe and store at once while a search runs, but Resize and Clear must not
+// be called during a search.
+type TranspositionTable struct {
+	entries    []ttSlot
+	mask       uint64
+	generation uint8
+}
+
+// NewTranspositionTable returns a table of at most mb megabytes.
+func NewTranspositionTable(mb int) *TranspositionTable {
+	t := &TranspositionTable{}
+	t.Resize(mb)
+	return t
+}
+
+// Resize empties the table and gives it room for as many entries as fit in mb
+// megabytes, rounded down to a power of two.
+func (t *TranspositionTable) Resize(mb int) {
+	if mb < 1 {
+		mb = 1
+	}
+	n := uint64(1)
+	for n*2*ttEntrySize <= uint64(mb)<<20 {
+		n *= 2
+	}
+	t.entries = make([]ttSlot, n)
+	t.mask = n - 1
+	t.generation = 0
+}
+
+// Clear forgets every position, as between games.
+func (t *TranspositionTable) Clear() {
+	for i := range t.entries {
+		t.entries[i].check.Store(0)
+		t.entries[i].data.Store(0)
+	}
+	t.generation = 0
+}
+
+// Hashfull returns how full the table is in permille, counting only entries
+// stored by the current search, as UCI's hashfull does.
+func (t *TranspositionTable) Hashfull() int {
+	if t == nil {
+		return 0
+	}
+	sample := len(t.entries)
+	if sample > 1000 {
+		sample = 1000
+	}
+	used := 0
+	for i := range t.entries[:sample] {
+		if flags := uint8(t.entries[i].data.Load() >> 56); flags != 0 && flags>>2 == t.generation {
+			used++
+		}
+	}
+	return used * 1000 / sample
+}
+
+// newSearch starts a new generation, so entries from earlier searches are
+// the first to be replaced.
+func (t *TranspositionTable) newSearch() {
+	if t != nil {
+		t.generation = (t.generation + 1) & 0x3f
+	}
+}
+
+// probe returns the entry for hash, if there is one.
+func (t *TranspositionTable) probe(hash uint64) (ttEntry, bool) {
+	if t == nil {
+		return ttEntry{}, false
+	}
+	slot := &t.entries[hash&t.mask]
+	data := slot.data.Load()
+	if slot.check.Load()^data != hash {
+		return ttEntry{}, false
+	}
+	entry := unpack(hash, data)
+	return entry, entry.flags != 0
+}
+
+// store records what a search of depth plies found at the position with hash,
+// ply moves from the root. An entry for another position is only replaced by
+// a search at least as deep, unless it was stored by an earlier search.
+func (t *TranspositionTable) store(hash uint64, depth int, ply int, score int, bound Bound, move chess.Move) {
+	if t == nil {
+		return
+	}
+	slot := &t.entries[hash&t.mask]
+	data := slot.data.Load()
+	entry := unpack(slot.check.Load()^data, data)
+	if entry.key != hash && entry.flags>>2 == t.generation && int(entry.depth) > depth {
+		return
+	}
+	moveId := uint16(move.MoveId)
+	if move.PieceMoved == "" {
+		moveId = 0
+		if entry.key == hash {
+			// Keep the best move from an earlier search of this position.
+			moveId = entry.move
+		}
+	}
+	data = ttEntry{
+		score: int32(scoreToTT(score, ply)),
+		move:  moveId,
+		depth: int8(depth),
+		flags: t.generation<<2 | uint8(bound),
+	}.pack()
+	slot.data.Store(data)
+	slot.check.Store(hash ^ data)
+}
+
+// Mate and tablebase scores count plies from the root, but a position can be
+// reached at different plies, so they are stored counting from the position
+// itself.
+func scoreToTT(score int, ply int) int {
+	switch {
+	case score >= TablebaseWinScore-MaxDepth:
+		return score + ply
+	case score <= -TablebaseWinScore+MaxDepth:
+		return score - ply
+	}
+	return score
+}
+
+func scoreFromTT(score int, ply int) int {
+	switch {
+	case score >= TablebaseWinScore-MaxDepth:
+		return score - ply
+	case score <= -TablebaseWinScore+MaxDepth:
+		return score + ply
+	}
+	return score
+}
@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/mattellis91/go-chess/chess"
+)
+
+func TestTranspositionTableSize(t *testing.T) {
+	for mb, want := range map[int]int{1: 1 << 16, 3: 1 << 17, 16: 1 << 20} {
+		if got := len(NewTranspositionTable(mb).entries); got != want {
+			t.Errorf("%d MB holds %d entries, want %d", mb, got, want)
+		}
+	}
+}
+
+func TestTranspositionTableMateScores(t *testing.T) {
+	tt := NewTranspositionTable(1)
+	// Mate in two found three plies from the root is mate in two from
+	// wherever the position turns up again.
+	tt.store(42, 4, 3, MateScore-6, BoundExact, chess.Move{})
+	entry, ok := tt.probe(42)
+	if !ok {
+		t.Fatal("entry was not stored")
+	}
+	if got := scoreFromTT(int(entry.score), 1); got != MateScore-4 {
+		t.Errorf("mate score read at ply 1 is %d, want %d", got, MateScore-4)
+	}
+	tt.store(42, 4, 3, -MateScore+5, BoundExact, chess.Move{})
+	entry, _ = tt.probe(42)
+	if got := scoreFromTT(int(entry.score), 5); got != -MateScore+7 {
+		t.Errorf("mated score read at ply 5 is %d, want %d", got, -MateScore+7)
+	}
+	// Tablebase wins move with the ply the same way.
+	tt.store(42, 4, 3, TablebaseWinScore-3, BoundExact, chess.Move{})
+	entry, _ = tt.probe(42)
+	if got := scoreFromTT(int(entry.score), 1); got != TablebaseWinScore-1 {
+		t.Errorf("tablebase win read at ply 1 is %d, want %d", got, TablebaseWinScore-1)
+	}
+	tt.store(42, 4, 3, -TablebaseWinScore+3, BoundExact, chess.Move{})
+	entry, _ = tt.probe(42)
+	if got := scoreFromTT(int(entry.score), 5); got != -TablebaseWinScore+5 {
+		t.Errorf("tablebase loss read at ply 5 is %d, want %d", got, -TablebaseWinScore+5)
+	}
+	tt.store(42, 4, 3, 150, BoundExact, chess.Move{})
+	entry, _ = tt.probe(42)
+	if got := scoreFromTT(int(entry.score), 5); got != 150 {
+		t.Errorf("score read at ply 5 is %d, want 150", got)
+	}
+}
+
+func TestTranspositionTableReplacement(t *testing.T) {
+	tt := NewTranspositionTable(1)
+	tt.newSearch()
+	collision := uint64(7) + tt.mask + 1
+	move := chess.Move{PieceMoved: "wp", MoveId: 6444}
+
+	tt.store(7, 6, 0, 10, BoundExact, move)
+	tt.store(collision, 2, 0, 20, BoundExact, chess.Move{})
+	if _, ok := tt.probe(7); !ok {
+		t.Error("a shallower search replaced a deeper one")
+	}
+
+	// Storing the same position again without a move keeps the old one.
+	tt.store(7, 3, 0, 30, BoundUpper, chess.Move{})
+	if entry, _ := tt.probe(7); entry.move != 6444 || entry.score != 30 {
+		t.Errorf("got move %d score %d, want move 6444 score 30", entry.move, entry.score)
+	}
+
+	tt.store(7, 6, 0, 10, BoundExact, move)
+	tt.newSearch()
+	tt.store(collision, 2, 0, 20, BoundExact, chess.Move{})
+	if _, ok := tt.probe(collision); !ok {
+		t.Error("an entry from an earlier search was not replaced")
+	}
+
+	tt.Clear()
+	if _, ok := tt.probe(collision); ok || tt.Hashfull() != 0 {
+		t.Error("Clear left entries behind")
+	}
+}
+
+func TestTranspositionTableSavesNodes(t *testing.T) {
+	gs, _ := chess.NewGameStateFromFEN("r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3")
+	without := &Engine{}
+	with := &Engine{TT: NewTranspositionTable(1)}
+	plain := without.Search(gs, Limits{Depth: 5})
+	hashed := with.Search(gs, Limits{Depth: 5})
+	if hashed.Nodes >= plain.Nodes {
+		t.Errorf("searched %d nodes with the table and %d without", hashed.Nodes, plain.Nodes)
+	}
+	if with.TT.Hashfull() == 0 {
+		t.Error("Hashfull is 0 after a search")
+	}
+
+	// A second search of the same position starts from what the first found.
+	again := with.Search(gs, Limits{Depth: 5})
+	if again.Nodes >= hashed.Nodes {
+		t.Errorf("searched %d nodes the second time and %d the first", again.Nodes, hashed.Nodes)
+	}
+}
@@ -1,14 +1,27 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"image/color"
 	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/hajimehoshi/ebiten/v2/vector"
+	"github.com/mattellis91/go-chess/book"
+	"github.com/mattellis91/go-chess/chess"
+	"github.com/mattellis91/go-chess/endgame"
+	"github.com/mattellis91/go-chess/engine"
+	"github.com/mattellis91/go-chess/syzygy"
+	"github.com/mattellis91/go-chess/uci"
+	"github.com/mattellis91/go-chess/xboard"
 )
 
 const (
@@ -23,23 +36,80 @@ var whiteSquareColor = color.RGBA{238, 238, 210, 255}
 var BlackSquareColor = color.RGBA{118, 150, 86, 255}
 var selectedPieceSquareColor = color.RGBA{255, 255, 0, 50}
 var higlightedSquareColor = color.RGBA{255, 0, 0, 50}
+var promotionChooserColor = color.RGBA{255, 255, 255, 230}
 
 type Game struct {
-	GameState *GameState
+	GameState         *chess.GameState
+	SquareSelected    chess.Square
+	PlayerClicks      []chess.Square
+	HiglightedSquares []chess.Square
+	MoveMade          bool
+	PGNPath           string
+	PromotionChoices  []chess.Move
+	Outcome           chess.Outcome
+	ClaimableDraw     chess.Reason
+	Engine            *engine.Engine
+	EngineLimits      engine.Limits
+	ComputerWhite     bool
+	ComputerBlack     bool
+	Thinking          bool
+	EngineMoves       chan engineReply
+	External          *uci.Client
+	Analyser          *uci.Client
+	Analysis          string
+	AnalysisUpdates   chan analysisUpdate
+	AnalysisStop      chan struct{}
+	AnalysisDone      chan struct{}
+	Book              *book.Book
+	BookPanel         string
+	ShowBook          bool
+	Trainer           *endgame.Tablebase
+	TrainerDistance   int
+	TrainerVerdict    string
 }
-type Square struct {
-	row int
-	col int
+
+// engineReply is the move chosen by the engine, or why it could not choose.
+type engineReply struct {
+	Move chess.Move
+	Err  error
+}
+
+// analysisUpdate is an info line from the analyser for the position with
+// the given hash, or the error that stopped it.
+type analysisUpdate struct {
+	Hash uint64
+	Info engine.Info
+	Err  error
 }
 
 func (g *Game) Update() error {
+	if ebiten.IsWindowBeingClosed() {
+		stopAnalysis(g)
+		if g.Book != nil {
+			g.Book.Close()
+		}
+		for _, client := range []*uci.Client{g.External, g.Analyser} {
+			if client != nil {
+				client.Close()
+			}
+		}
+		if g.PGNPath != "" {
+			tags := []chess.PGNTag{{Name: "Result", Value: g.Outcome.Result.String()}}
+			if err := os.WriteFile(g.PGNPath, []byte(g.GameState.PGN(tags)), 0644); err != nil {
+				log.Printf("Error saving game: %v", err)
+			}
+		}
+		return ebiten.Termination
+	}
 	handleInput(g)
 	return nil
 }
 
 func (g *Game) Draw(screen *ebiten.Image) {
 	drawBoard(screen)
-	drawPieces(screen, g.GameState)
+	drawPieces(screen, g)
+	drawPromotionChooser(screen, g)
+	drawStatus(screen, g)
 }
 
 func (g *Game) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int) {
@@ -48,40 +118,73 @@ func (g *Game) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeigh
 
 func (g *Game) Init() {
 	loadAssets()
+	g.SquareSelected = chess.GetNullSquare()
 	g.GameState.ValidMoves = g.GameState.GetValidMoves()
+	updateOutcome(g)
+	startAnalysis(g)
+	updateBookPanel(g)
+	updateTrainer(g)
 }
 
 func handleInput(g *Game) {
-	if inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonLeft) {
+	select {
+	case reply := <-g.EngineMoves:
+		g.Thinking = false
+		if reply.Err != nil {
+			log.Printf("%v; the built-in engine takes over", reply.Err)
+			go g.External.Close()
+			g.External = nil
+		} else if g.Outcome.Result == chess.NoResult {
+			applyMove(g, reply.Move)
+		}
+	default:
+	}
+
+	select {
+	case update := <-g.AnalysisUpdates:
+		if update.Err != nil {
+			log.Printf("%v; analysis stopped", update.Err)
+			go g.Analyser.Close()
+			g.Analyser = nil
+			g.Analysis = ""
+		} else if update.Hash == g.GameState.Hash {
+			g.Analysis = formatAnalysis(g.GameState, update.Info)
+		}
+	default:
+	}
+
+	if inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonLeft) && g.Outcome.Result == chess.NoResult && !computerToMove(g) {
 		fmt.Println("Mouse button pressed")
 		mouseX, mouseY := ebiten.CursorPosition()
 		row := mouseY / SQUARE_SIZE
 		col := mouseX / SQUARE_SIZE
 
-		if g.GameState.SquareSelected.row == row && g.GameState.SquareSelected.col == col {
-			resetClicks(g.GameState)
+		if len(g.PromotionChoices) > 0 {
+			choosePromotion(g, row, col)
+		} else if g.SquareSelected.Row == row && g.SquareSelected.Col == col {
+			resetClicks(g)
 		} else {
-			g.GameState.SquareSelected = Square{row, col}
-			g.GameState.PlayerClicks = append(g.GameState.PlayerClicks, g.GameState.SquareSelected)
+			g.SquareSelected = chess.Square{Row: row, Col: col}
+			g.PlayerClicks = append(g.PlayerClicks, g.SquareSelected)
 		}
 
-		if len(g.GameState.PlayerClicks) == 2 {
-			m := NewMove(g.GameState.PlayerClicks[0], g.GameState.PlayerClicks[1], g.GameState.Board, false, false)
+		if len(g.PlayerClicks) == 2 {
+			m := chess.NewMove(g.PlayerClicks[0], g.PlayerClicks[1], g.GameState.Board, false, false)
 
 			for _, move := range g.GameState.ValidMoves {
-				if  m.MoveId == move.MoveId {
-					g.GameState.MakeMove(move)
-					g.GameState.MoveMade = true
-					resetClicks(g.GameState)
+				if m.IsPawnPromotion && move.StartRow == m.StartRow && move.StartCol == m.StartCol && move.EndRow == m.EndRow && move.EndCol == m.EndCol {
+					g.PromotionChoices = append(g.PromotionChoices, move)
+				} else if m.MoveId == move.MoveId {
+					applyMove(g, move)
 					break
 				}
 			}
 
-			if !g.GameState.MoveMade {
-				g.GameState.PlayerClicks = []Square{g.GameState.SquareSelected}
+			if len(g.PromotionChoices) > 0 {
+				resetClicks(g)
+			} else if !g.MoveMade {
+				g.PlayerClicks = []chess.Square{g.SquareSelected}
 			}
-			
-			fmt.Println(m.GetChessNotation())
 		}
 	}
 
@@ -89,27 +192,411 @@ func handleInput(g *Game) {
 		mouseX, mouseY := ebiten.CursorPosition()
 		row := mouseY / SQUARE_SIZE
 		col := mouseX / SQUARE_SIZE
-		selectedSquare := Square{row, col}
-		if !g.GameState.SquareAlreadyHighlighted(selectedSquare) {
-			g.GameState.HiglightedSquares = append(g.GameState.HiglightedSquares, selectedSquare)
+		selectedSquare := chess.Square{Row: row, Col: col}
+		if !g.SquareAlreadyHighlighted(selectedSquare) {
+			g.HiglightedSquares = append(g.HiglightedSquares, selectedSquare)
 		} else {
-			g.GameState.HiglightedSquares = g.GameState.RemoveSquareFromSlice(g.GameState.HiglightedSquares, selectedSquare)
+			g.HiglightedSquares = removeSquareFromSlice(g.HiglightedSquares, selectedSquare)
 		}
 	}
 
-	if inpututil.IsKeyJustPressed(ebiten.KeyZ) {
-		g.GameState.UndoMove()
-		g.GameState.MoveMade = true
+	if inpututil.IsKeyJustPressed(ebiten.KeyZ) && !g.Thinking && takeBack(g) {
+		resetClicks(g)
+		g.PromotionChoices = nil
+		g.TrainerVerdict = ""
+		g.MoveMade = true
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyX) && !g.Thinking && redo(g) {
+		resetClicks(g)
+		g.PromotionChoices = nil
+		g.MoveMade = true
 	}
 
 	if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
-		g.GameState.HiglightedSquares = []Square{}
+		g.HiglightedSquares = []chess.Square{}
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyB) {
+		g.ShowBook = !g.ShowBook
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyD) && g.ClaimableDraw != chess.NoReason {
+		g.Outcome = chess.Outcome{Result: chess.Draw, Reason: g.ClaimableDraw}
+		g.ClaimableDraw = chess.NoReason
 	}
 
-	if g.GameState.MoveMade {
+	if g.MoveMade {
 		g.GameState.ValidMoves = g.GameState.GetValidMoves()
-		g.GameState.MoveMade = false
+		updateOutcome(g)
+		startAnalysis(g)
+		updateBookPanel(g)
+		updateTrainer(g)
+		g.MoveMade = false
+	}
+
+	if computerToMove(g) && !g.Thinking && g.Outcome.Result == chess.NoResult {
+		startEngine(g)
+	}
+}
+
+func computerToMove(g *Game) bool {
+	if g.GameState.WhiteToMove {
+		return g.ComputerWhite
+	}
+	return g.ComputerBlack
+}
+
+func computerOnly(g *Game) bool {
+	return g.ComputerWhite && g.ComputerBlack
+}
+
+// startEngine searches for the computer's move on another goroutine, so the
+// window keeps drawing while it thinks. The move comes back on EngineMoves
+// and is played by handleInput like a move made with the mouse. An external
+// engine is used if there is one.
+func startEngine(g *Game) {
+	g.Thinking = true
+	gs := g.GameState.Clone()
+	external := g.External
+	go func() {
+		if external != nil {
+			move, err := external.Go(gs, g.EngineLimits, nil)
+			g.EngineMoves <- engineReply{Move: move, Err: err}
+			return
+		}
+		g.EngineMoves <- engineReply{Move: g.Engine.Search(gs, g.EngineLimits).Move}
+	}()
+}
+
+// startAnalysis has the analyser think about the current position until the
+// next call. The client runs one search at a time, so each analysis waits for
+// the one before it to stop.
+func startAnalysis(g *Game) {
+	stopAnalysis(g)
+	g.Analysis = ""
+	if g.Analyser == nil || g.Outcome.Result != chess.NoResult {
+		return
+	}
+
+	stop, done, previous := make(chan struct{}), make(chan struct{}), g.AnalysisDone
+	g.AnalysisStop, g.AnalysisDone = stop, done
+	gs := g.GameState.Clone()
+	analyser := g.Analyser
+	go func() {
+		defer close(done)
+		if previous != nil {
+			<-previous
+		}
+		_, err := analyser.Go(gs, engine.Limits{Infinite: true, Stop: stop}, func(info engine.Info) {
+			select {
+			case g.AnalysisUpdates <- analysisUpdate{Hash: gs.Hash, Info: info}:
+			default:
+			}
+		})
+		if err != nil {
+			g.AnalysisUpdates <- analysisUpdate{Hash: gs.Hash, Err: err}
+		}
+	}()
+}
+
+func stopAnalysis(g *Game) {
+	if g.AnalysisStop != nil {
+		close(g.AnalysisStop)
+		g.AnalysisStop = nil
+	}
+}
+
+// formatAnalysis describes an info line from the analyser as a score from
+// white's point of view and the start of the principal variation in SAN.
+func formatAnalysis(gs *chess.GameState, info engine.Info) string {
+	score := info.Score
+	if !gs.WhiteToMove {
+		score = -score
+	}
+	text := fmt.Sprintf("depth %d  %+.2f ", info.Depth, float64(engine.ReportedCentipawns(score))/100)
+	if score >= engine.MateScore-engine.MaxDepth || score <= -engine.MateScore+engine.MaxDepth {
+		text = fmt.Sprintf("depth %d  mate ", info.Depth)
+	}
+
+	replay := gs.Clone()
+	for i, move := range info.PV {
+		if i == 6 {
+			text += " ..."
+			break
+		}
+		text += " " + replay.GetSAN(move)
+		replay.MakeMove(move)
+	}
+	return text
+}
+
+// updateBookPanel lists the book moves for the current position in SAN, with
+// each one's share of the total weight.
+func updateBookPanel(g *Game) {
+	g.BookPanel = ""
+	if g.Book == nil {
+		return
+	}
+	entries, err := g.Book.Lookup(g.GameState)
+	if err != nil {
+		log.Printf("Error reading book: %v", err)
+		return
+	}
+	if len(entries) == 0 {
+		g.BookPanel = "Out of book"
+		return
+	}
+	total := 0
+	for _, entry := range entries {
+		total += entry.Weight
+	}
+	g.BookPanel = "Book"
+	for i, entry := range entries {
+		if i == 8 {
+			g.BookPanel += "\n..."
+			break
+		}
+		percent := 0
+		if total > 0 {
+			percent = entry.Weight * 100 / total
+		}
+		g.BookPanel += fmt.Sprintf("\n%-7s %3d%%", g.GameState.GetSAN(entry.Move), percent)
+	}
+}
+
+// takeBack takes back the last move, and when playing the computer also its
+// reply, so it is the player's turn again.
+func takeBack(g *Game) bool {
+	if !g.GameState.TakeBackMove() {
+		return false
+	}
+	for computerToMove(g) && !computerOnly(g) && g.GameState.TakeBackMove() {
+	}
+	return true
+}
+
+// redo replays moves taken back by takeBack up to the player's next turn.
+func redo(g *Game) bool {
+	if !g.GameState.RedoMove() {
+		return false
+	}
+	for computerToMove(g) && !computerOnly(g) && g.GameState.RedoMove() {
+	}
+	return true
+}
+
+func updateOutcome(g *Game) {
+	g.Outcome = g.GameState.Outcome()
+	g.ClaimableDraw = chess.NoReason
+	if g.Outcome.Reason == chess.TablebaseResult {
+		fmt.Println(formatTablebaseOutcome(g.Outcome))
+	} else if g.Outcome.Result != chess.NoResult {
+		fmt.Printf("%v (%v)\n", g.Outcome.Result, g.Outcome.Reason)
+	} else {
+		g.ClaimableDraw = g.GameState.ClaimableDraw()
+	}
+}
+
+// formatTablebaseOutcome describes a game adjudicated by the tablebase, such
+// as "1-0 (tablebase win in 23 plies)".
+func formatTablebaseOutcome(outcome chess.Outcome) string {
+	if outcome.Result == chess.Draw {
+		return fmt.Sprintf("%v (tablebase draw)", outcome.Result)
+	}
+	if outcome.Distance == 0 {
+		return fmt.Sprintf("%v (tablebase win)", outcome.Result)
+	}
+	return fmt.Sprintf("%v (tablebase win in %d plies)", outcome.Result, outcome.Distance)
+}
+
+// updateTrainer shows, in the endgame trainer, how many plies white needs to
+// mate from the position on the board, and after each of white's moves how it
+// compared with the best one.
+func updateTrainer(g *Game) {
+	if g.Trainer == nil {
+		return
+	}
+	wdl, ok := g.Trainer.ProbeWDL(g.GameState)
+	distance, _ := g.Trainer.ProbeDistance(g.GameState)
+	if !ok {
+		return
+	}
+	if g.GameState.WhiteToMove {
+		if wdl == chess.WDLWin {
+			g.TrainerDistance = distance
+		}
+		return
+	}
+	switch {
+	case wdl != chess.WDLLoss:
+		g.TrainerVerdict = "That lets the win slip: it is a draw now"
+	case g.TrainerDistance == 0:
+		g.TrainerVerdict = ""
+	case distance == g.TrainerDistance-1:
+		g.TrainerVerdict = "Best move"
+	default:
+		g.TrainerVerdict = fmt.Sprintf("Still winning, but %d plies slower than the best move", distance-g.TrainerDistance+1)
+	}
+	g.TrainerDistance = distance
+}
+
+// formatTrainer describes the position in the endgame trainer.
+func formatTrainer(g *Game) string {
+	wdl, ok := g.Trainer.ProbeWDL(g.GameState)
+	distance, _ := g.Trainer.ProbeDistance(g.GameState)
+	whiteWins := wdl == chess.WDLWin
+	if !g.GameState.WhiteToMove {
+		whiteWins = wdl == chess.WDLLoss
+	}
+	status := ""
+	if ok && whiteWins {
+		status = fmt.Sprintf("White mates in %d plies", distance)
+	} else if ok {
+		status = "Draw"
+	}
+	if g.TrainerVerdict != "" {
+		status += "\n" + g.TrainerVerdict
+	}
+	return status
+}
+
+func applyMove(g *Game, move chess.Move) {
+	fmt.Println(g.GameState.GetSAN(move))
+	g.GameState.PlayMove(move)
+	g.MoveMade = true
+	resetClicks(g)
+}
+
+// promotionChoiceSquare returns the square the i'th promotion choice is shown
+// on, counting from the promotion square towards the middle of the board.
+func promotionChoiceSquare(move chess.Move, i int) chess.Square {
+	if move.EndRow == 0 {
+		return chess.Square{Row: move.EndRow + i, Col: move.EndCol}
+	}
+	return chess.Square{Row: move.EndRow - i, Col: move.EndCol}
+}
+
+func choosePromotion(g *Game, row int, col int) {
+	choices := g.PromotionChoices
+	g.PromotionChoices = nil
+	for i, move := range choices {
+		square := promotionChoiceSquare(move, i)
+		if square.Row == row && square.Col == col {
+			applyMove(g, move)
+			return
+		}
+	}
+}
+
+func (g *Game) SquareAlreadyHighlighted(square chess.Square) bool {
+	for _, currentSquare := range g.HiglightedSquares {
+		if square == currentSquare {
+			return true
+		}
+	}
+	return false
+}
+
+func removeSquareFromSlice(squares []chess.Square, square chess.Square) []chess.Square {
+	for i, currentSquare := range squares {
+		if currentSquare == square {
+			return append(squares[:i], squares[i+1:]...)
+		}
+	}
+	return squares
+}
+
+func runPerft(gs *chess.GameState, depth int) {
+	start := time.Now()
+	divide := gs.Divide(depth)
+	elapsed := time.Since(start)
+
+	moves := []string{}
+	total := 0
+	for move, nodes := range divide {
+		moves = append(moves, move)
+		total += nodes
+	}
+	sort.Strings(moves)
+	for _, move := range moves {
+		fmt.Printf("%s: %d\n", move, divide[move])
+	}
+	fmt.Printf("\nNodes: %d\nTime: %v\nNodes/s: %.0f\n", total, elapsed, float64(total)/elapsed.Seconds())
+}
+
+// buildBook counts the moves of the first plies of every game in the PGN files
+// and writes those that pass the filters to path as a Polyglot book.
+func buildBook(path string, pgnPaths []string, plies int, minGames int, minScore float64) error {
+	builder := book.NewBuilder()
+	builder.MaxPlies, builder.MinGames, builder.MinScore = plies, minGames, minScore
+	for _, pgnPath := range pgnPaths {
+		f, err := os.Open(pgnPath)
+		if err != nil {
+			return err
+		}
+		added, skipped, err := builder.AddPGN(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", pgnPath, err)
+		}
+		fmt.Printf("%s: %d games, %d skipped\n", pgnPath, added, skipped)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	n, err := builder.WriteTo(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: %d moves\n", path, n/16)
+	return nil
+}
+
+// loadTablebase reads the endgame tables in dir, if it is not empty, and
+// generates any of materials that are missing, saving them to dir.
+func loadTablebase(dir string, materials []string) (*endgame.Tablebase, error) {
+	tb := endgame.NewTablebase()
+	if dir != "" {
+		var err error
+		if tb, err = endgame.Open(dir); err != nil {
+			return nil, err
+		}
+	}
+	generator := endgame.NewGenerator(tb)
+	generator.OnTable = func(t *endgame.Table) {
+		fmt.Printf("Generated %s\n", t.Material)
+		if dir != "" {
+			if err := t.Save(dir); err != nil {
+				log.Printf("Error saving %s: %v", t.Material, err)
+			}
+		}
+	}
+	for _, material := range materials {
+		if _, err := generator.Generate(material); err != nil {
+			return nil, err
+		}
+	}
+	return tb, nil
+}
+
+func loadPGN(path string) (*chess.GameState, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	game, err := chess.NewPGNReader(f).Next()
+	if err != nil {
+		return nil, err
 	}
+	return game.GameState, nil
 }
 
 func loadAssets() {
@@ -123,9 +610,9 @@ func loadAssets() {
 	}
 }
 
-func resetClicks(gs *GameState) {
-	gs.SquareSelected = GetNullSquare()
-	gs.PlayerClicks = []Square{}
+func resetClicks(g *Game) {
+	g.SquareSelected = chess.GetNullSquare()
+	g.PlayerClicks = []chess.Square{}
 }
 
 func drawBoard(screen *ebiten.Image) {
@@ -140,14 +627,15 @@ func drawBoard(screen *ebiten.Image) {
 	}
 }
 
-func drawPieces(screen *ebiten.Image, gs *GameState) {
+func drawPieces(screen *ebiten.Image, g *Game) {
+	gs := g.GameState
 
-	if gs.SquareSelected.row != -1 && gs.SquareSelected.col != -1 {
-		vector.DrawFilledRect(screen, float32(gs.SquareSelected.col*SQUARE_SIZE), float32(gs.SquareSelected.row*SQUARE_SIZE), float32(SQUARE_SIZE), float32(SQUARE_SIZE), selectedPieceSquareColor, false)
+	if g.SquareSelected.Row != -1 && g.SquareSelected.Col != -1 {
+		vector.DrawFilledRect(screen, float32(g.SquareSelected.Col*SQUARE_SIZE), float32(g.SquareSelected.Row*SQUARE_SIZE), float32(SQUARE_SIZE), float32(SQUARE_SIZE), selectedPieceSquareColor, false)
 	}
 
-	for _, square := range gs.HiglightedSquares {
-		vector.DrawFilledRect(screen, float32(square.col*SQUARE_SIZE), float32(square.row*SQUARE_SIZE), float32(SQUARE_SIZE), float32(SQUARE_SIZE), higlightedSquareColor, false)
+	for _, square := range g.HiglightedSquares {
+		vector.DrawFilledRect(screen, float32(square.Col*SQUARE_SIZE), float32(square.Row*SQUARE_SIZE), float32(SQUARE_SIZE), float32(SQUARE_SIZE), higlightedSquareColor, false)
 	}
 
 	for r := 0; r < DIMENSIONS; r++ {
@@ -163,11 +651,196 @@ func drawPieces(screen *ebiten.Image, gs *GameState) {
 	}
 }
 
+func drawPromotionChooser(screen *ebiten.Image, g *Game) {
+	for i, move := range g.PromotionChoices {
+		square := promotionChoiceSquare(move, i)
+		vector.DrawFilledRect(screen, float32(square.Col*SQUARE_SIZE), float32(square.Row*SQUARE_SIZE), float32(SQUARE_SIZE), float32(SQUARE_SIZE), promotionChooserColor, false)
+		op := &ebiten.DrawImageOptions{}
+		op.GeoM.Translate(float64(square.Col*SQUARE_SIZE), float64(square.Row*SQUARE_SIZE))
+		screen.DrawImage(pieceImages[move.PromotionPiece], op)
+	}
+}
+
+func drawStatus(screen *ebiten.Image, g *Game) {
+	if g.Outcome.Reason == chess.TablebaseResult {
+		ebitenutil.DebugPrint(screen, "Game over: "+formatTablebaseOutcome(g.Outcome))
+	} else if g.Outcome.Result != chess.NoResult {
+		ebitenutil.DebugPrint(screen, fmt.Sprintf("Game over: %v (%v)", g.Outcome.Result, g.Outcome.Reason))
+	} else if g.Thinking {
+		ebitenutil.DebugPrint(screen, "Thinking...")
+	} else if g.ClaimableDraw != chess.NoReason {
+		ebitenutil.DebugPrint(screen, fmt.Sprintf("Press D to claim a draw by %v", g.ClaimableDraw))
+	}
+	if g.Trainer != nil && g.Outcome.Result == chess.NoResult && !g.Thinking {
+		ebitenutil.DebugPrint(screen, formatTrainer(g))
+	}
+	if g.Analysis != "" {
+		ebitenutil.DebugPrintAt(screen, g.Analysis, 0, HEIGHT-16)
+	}
+	if g.ShowBook && g.BookPanel != "" {
+		ebitenutil.DebugPrintAt(screen, g.BookPanel, WIDTH-80, 0)
+	}
+}
+
 func main() {
+	fen := flag.String("fen", chess.StartingPositionFEN, "FEN of the position to start from")
+	pgnPath := flag.String("pgn", "", "file to save the game to as PGN when the window is closed")
+	openPath := flag.String("open", "", "PGN file whose first game is loaded instead of -fen")
+	perftDepth := flag.Int("perft", 0, "print the perft count below each move to this depth and exit")
+	computer := flag.String("computer", "", "colour the computer plays: white, black or both")
+	depth := flag.Int("depth", engine.DefaultDepth, "how many plies the computer looks ahead")
+	uciMode := flag.Bool("uci", false, "run as a UCI engine on stdin and stdout instead of opening the window")
+	xboardMode := flag.Bool("xboard", false, "run as an XBoard engine on stdin and stdout instead of opening the window")
+	enginePath := flag.String("engine", "", "UCI engine program the computer plays with instead of the built-in engine")
+	analyse := flag.Bool("analyse", false, "show the -engine's analysis of the position instead of playing against it")
+	moveTime := flag.Int("movetime", 0, "milliseconds the computer thinks per move, instead of searching to -depth")
+	bookPath := flag.String("book", "", "Polyglot opening book the computer plays from and the B key shows")
+	bookPlies := flag.Int("bookplies", engine.DefaultBookPlies, "plies into the game the computer plays from -book, or -makebook counts moves, or 0 for no limit")
+	syzygyPath := flag.String("syzygy", "", "directories of Syzygy tablebases to adjudicate endgames and guide the computer with")
+	makeBook := flag.String("makebook", "", "write a Polyglot book of the moves in the PGN files named after the flags to this file and exit")
+	minGames := flag.Int("mingames", 1, "leave moves played in fewer games out of -makebook")
+	minScore := flag.Float64("minscore", 0, "leave moves that scored less than this, from 0 to 1, out of -makebook")
+	tbPath := flag.String("tb", "", "directory of endgame tables made with -gentb to adjudicate endgames and guide the computer with")
+	genTables := flag.String("gentb", "", "generate the endgame tables for these materials, such as KQvK,KBNvK, into the -tb directory and exit")
+	train := flag.String("train", "", "practise mating with this material, such as KRvK, from a random position against the computer")
+	trainPlies := flag.Int("trainplies", 15, "fewest plies to mate in the positions -train sets up")
+	bookBest := flag.Bool("bookbest", false, "always play the book move with the highest weight instead of choosing at random by weight")
+	flag.Parse()
+
+	if *uciMode {
+		if err := uci.Run(os.Stdin, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if *xboardMode {
+		if err := xboard.Run(os.Stdin, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *makeBook != "" {
+		if err := buildBook(*makeBook, flag.Args(), *bookPlies, *minGames, *minScore); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *genTables != "" {
+		if *tbPath == "" {
+			log.Fatal("-gentb needs a -tb directory to write the tables to")
+		}
+		if err := os.MkdirAll(*tbPath, 0755); err != nil {
+			log.Fatal(err)
+		}
+		if _, err := loadTablebase(*tbPath, strings.Split(*genTables, ",")); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *syzygyPath != "" && *tbPath != "" {
+		log.Fatal("-syzygy and -tb cannot be used together")
+	}
+
+	if *computer != "" && *computer != "white" && *computer != "black" && *computer != "both" {
+		log.Fatalf("-computer must be white, black or both, not %q", *computer)
+	}
+
+	gs, err := chess.NewGameStateFromFEN(*fen)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *openPath != "" {
+		gs, err = loadPGN(*openPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var trainer *endgame.Tablebase
+	if *train != "" {
+		trainer, err = loadTablebase(*tbPath, []string{*train})
+		if err != nil {
+			log.Fatal(err)
+		}
+		position, ok := trainer.Table(*train).RandomPosition(rand.New(rand.NewSource(time.Now().UnixNano())), *trainPlies)
+		if !ok {
+			log.Fatalf("%s has no position white mates in %d plies or more", *train, *trainPlies)
+		}
+		gs = position
+		*computer = "black"
+	}
+
+	if *perftDepth > 0 {
+		runPerft(gs, *perftDepth)
+		return
+	}
+
 	ebiten.SetWindowSize(WIDTH, HEIGHT)
 	ebiten.SetWindowTitle("Hello, World!")
-	gs := NewGameState()
-	g := &Game{gs}
+	ebiten.SetWindowClosingHandled(true)
+	g := &Game{
+		GameState:       gs,
+		PGNPath:         *pgnPath,
+		Engine:          engine.NewEngine(),
+		EngineLimits:    engine.Limits{Depth: *depth},
+		ComputerWhite:   *computer == "white" || *computer == "both",
+		ComputerBlack:   *computer == "black" || *computer == "both",
+		EngineMoves:     make(chan engineReply, 1),
+		AnalysisUpdates: make(chan analysisUpdate, 1),
+	}
+	if *moveTime > 0 {
+		g.EngineLimits = engine.Limits{MoveTime: time.Duration(*moveTime) * time.Millisecond}
+	}
+	if *bookPath != "" {
+		b, err := book.Open(*bookPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		g.Book, g.ShowBook = b, true
+		g.Engine.Book, g.Engine.BookPlies = b, *bookPlies
+		if *bookBest {
+			g.Engine.BookSelection = book.BestOnly
+		}
+	}
+	if *syzygyPath != "" {
+		tables, err := syzygy.Open(*syzygyPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		wdl, dtz := tables.Count()
+		log.Printf("Found %d WDL and %d DTZ tables of up to %d pieces", wdl, dtz, tables.MaxPieces())
+		g.GameState.Tablebase, g.Engine.Tablebase = tables, tables
+	}
+	if *tbPath != "" && trainer == nil {
+		tables, err := loadTablebase(*tbPath, nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		g.GameState.Tablebase, g.Engine.Tablebase = tables, tables
+	}
+	if trainer != nil {
+		// The trainer plays the game out to mate rather than adjudicating
+		// it, with the computer defending as long as it can.
+		g.Trainer, g.Engine.Tablebase = trainer, trainer
+	}
+	if *enginePath != "" {
+		client := uci.NewClient(*enginePath)
+		if err := client.Start(); err != nil {
+			log.Fatal(err)
+		}
+		if err := client.NewGame(); err != nil {
+			log.Fatal(err)
+		}
+		if *analyse {
+			g.Analyser = client
+		} else {
+			g.External = client
+		}
+	}
 	g.Init()
 	if err := ebiten.RunGame(g); err != nil {
 		log.Fatal(err)
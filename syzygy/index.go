@@ -0,0 +1,295 @@
+package syzygy
+
+// The tables number squares from a1 = 0 to h8 = 63, rank by rank, which is
+// the chess package's numbering with the ranks turned round.
+func tbSquare(sq int) int {
+	return sq ^ 56
+}
+
+func fileOf(sq int) int {
+	return sq & 7
+}
+
+func rankOf(sq int) int {
+	return sq >> 3
+}
+
+// offDiagonal is above zero for squares above the a1-h8 diagonal, zero on it
+// and below zero under it.
+func offDiagonal(sq int) int {
+	return rankOf(sq) - fileOf(sq)
+}
+
+// touching reports whether kings on a and b would stand next to each other,
+// or on the same square.
+func touching(a int, b int) bool {
+	files, ranks := fileOf(a)-fileOf(b), rankOf(a)-rankOf(b)
+	return files >= -1 && files <= 1 && ranks >= -1 && ranks <= 1
+}
+
+var (
+	// mapB1H1H7 numbers the 28 squares under the a1-h8 diagonal.
+	mapB1H1H7 [64]int
+	// mapA1D1D4 numbers the a1-d1-d4 triangle, the 6 squares under the
+	// diagonal first and the 4 on it last.
+	mapA1D1D4 [64]int
+	// mapKK numbers the 462 ways to place two kings that are not mirror
+	// images of each other, the first in the triangle.
+	mapKK [10][64]int
+	// binomial[k][n] is the number of ways to choose k squares from n.
+	binomial [6][64]int
+	// mapPawns numbers the squares a pawn can stand on so that the leading
+	// pawn, nearest the edge and then the lowest, has the highest number.
+	mapPawns [64]int
+	// leadPawnIdx and leadPawnsSize give, for a number of leading pawns, the
+	// first index for the leading pawn on each square and the number of
+	// indexes for each file.
+	leadPawnIdx   [6][64]int
+	leadPawnsSize [6][4]int
+)
+
+func init() {
+	code := 0
+	for sq := 0; sq < 64; sq++ {
+		if offDiagonal(sq) < 0 {
+			mapB1H1H7[sq] = code
+			code++
+		}
+	}
+
+	var triangle [10]int
+	code = 0
+	diagonal := []int{}
+	for sq := 0; sq < 64; sq++ {
+		switch {
+		case fileOf(sq) > 3 || rankOf(sq) > 3:
+		case offDiagonal(sq) < 0:
+			mapA1D1D4[sq], triangle[code] = code, sq
+			code++
+		case offDiagonal(sq) == 0:
+			diagonal = append(diagonal, sq)
+		}
+	}
+	for _, sq := range diagonal {
+		mapA1D1D4[sq], triangle[code] = code, sq
+		code++
+	}
+
+	// Both kings on the diagonal come last.
+	type kings struct{ first, second int }
+	bothOnDiagonal := []kings{}
+	code = 0
+	for i, first := range triangle {
+		for second := 0; second < 64; second++ {
+			switch {
+			case touching(first, second):
+			case offDiagonal(first) == 0 && offDiagonal(second) > 0:
+			case offDiagonal(first) == 0 && offDiagonal(second) == 0:
+				bothOnDiagonal = append(bothOnDiagonal, kings{i, second})
+			default:
+				mapKK[i][second] = code
+				code++
+			}
+		}
+	}
+	for _, k := range bothOnDiagonal {
+		mapKK[k.first][k.second] = code
+		code++
+	}
+
+	binomial[0][0] = 1
+	for n := 1; n < 64; n++ {
+		for k := 0; k < 6 && k <= n; k++ {
+			if k > 0 {
+				binomial[k][n] += binomial[k-1][n-1]
+			}
+			if k < n {
+				binomial[k][n] += binomial[k][n-1]
+			}
+		}
+	}
+
+	available := 47
+	for leadPawns := 1; leadPawns <= 5; leadPawns++ {
+		for file := 0; file < 4; file++ {
+			idx := 0
+			for rank := 1; rank <= 6; rank++ {
+				sq := rank*8 + file
+				if leadPawns == 1 {
+					mapPawns[sq] = available
+					mapPawns[sq^7] = available - 1
+					available -= 2
+				}
+				leadPawnIdx[leadPawns][sq] = idx
+				idx += binomial[leadPawns-1][mapPawns[sq]]
+			}
+			leadPawnsSize[leadPawns][file] = idx
+		}
+	}
+}
+
+// encode returns the index of the position with the given pieces on board,
+// which is indexed by table square and holds the table's piece codes, and
+// the side to move in the table's terms. flip turns the board round and
+// swaps the colours first, for positions where black has the pieces the
+// table names first. ok is false for a DTZ table that only stores the other
+// side to move.
+func (t *table) encode(board *[64]int, stm int, flip bool) (d *pairsData, idx uint64, ok bool) {
+	var squares, pieces [maxPieces]int
+	flipColor, flipSquares := 0, 0
+	if flip {
+		flipColor, flipSquares = 8, 56
+	}
+	size, leadPawns, file := 0, 0, 0
+
+	// Pawn tables are split by the file of the leading pawn, the one with
+	// the highest mapPawns, which is the first piece.
+	if t.hasPawns {
+		lead := t.pairs[0][0].pieces[0] ^ flipColor
+		for sq, piece := range board {
+			if piece == lead {
+				squares[size] = sq ^ flipSquares
+				if mapPawns[squares[size]] > mapPawns[squares[0]] {
+					squares[0], squares[size] = squares[size], squares[0]
+				}
+				size++
+			}
+		}
+		leadPawns = size
+		file = fileOf(squares[0])
+		if file > 3 {
+			file = 7 - file
+		}
+	}
+
+	if t.dtz {
+		d = t.pairs[0][file]
+		if d.flags&stmFlag != stm && !(t.symmetric && !t.hasPawns) {
+			return d, 0, false
+		}
+	} else {
+		d = t.pairs[stm][file]
+	}
+
+	for sq, piece := range board {
+		if piece != 0 && (!t.hasPawns || piece != t.pairs[0][0].pieces[0]^flipColor) {
+			squares[size], pieces[size] = sq^flipSquares, piece^flipColor
+			size++
+		}
+	}
+
+	// Put the pieces in the order the table lists them.
+	for i := leadPawns; i < size-1; i++ {
+		for j := i + 1; j < size; j++ {
+			if d.pieces[i] == pieces[j] {
+				pieces[i], pieces[j] = pieces[j], pieces[i]
+				squares[i], squares[j] = squares[j], squares[i]
+				break
+			}
+		}
+	}
+
+	// Mirror the board so the first piece is on files a to d.
+	if fileOf(squares[0]) > 3 {
+		for i := 0; i < size; i++ {
+			squares[i] ^= 7
+		}
+	}
+
+	if t.hasPawns {
+		idx = uint64(leadPawnIdx[leadPawns][squares[0]])
+		sortSquares(squares[1:leadPawns], func(a, b int) bool { return mapPawns[a] < mapPawns[b] })
+		for i := 1; i < leadPawns; i++ {
+			idx += uint64(binomial[i][mapPawns[squares[i]]])
+		}
+	} else {
+		// Without pawns the board can also be turned over, and mirrored in
+		// the a1-h8 diagonal, so the first piece off the diagonal is under
+		// it.
+		if rankOf(squares[0]) > 3 {
+			for i := 0; i < size; i++ {
+				squares[i] ^= 56
+			}
+		}
+		for i := 0; i < d.groupLen[0]; i++ {
+			if offDiagonal(squares[i]) == 0 {
+				continue
+			}
+			if offDiagonal(squares[i]) > 0 {
+				for j := i; j < size; j++ {
+					squares[j] = ((squares[j] >> 3) | (squares[j] << 3)) & 63
+				}
+			}
+			break
+		}
+		idx = leadingIndex(t.hasUniquePieces, squares[:size])
+	}
+
+	// The other groups follow, each a set of identical pieces on squares
+	// the earlier groups leave free.
+	idx *= d.groupIdx[0]
+	group := d.groupLen[0]
+	remainingPawns := t.hasPawns && t.pawnCount[1] > 0
+	for next := 1; d.groupLen[next] != 0; next++ {
+		groupSquares := squares[group : group+d.groupLen[next]]
+		sortSquares(groupSquares, func(a, b int) bool { return a < b })
+		n := 0
+		for i, sq := range groupSquares {
+			adjust := 0
+			for _, earlier := range squares[:group] {
+				if sq > earlier {
+					adjust++
+				}
+			}
+			if remainingPawns {
+				adjust += 8
+			}
+			n += binomial[i+1][sq-adjust]
+		}
+		remainingPawns = false
+		idx += uint64(n) * d.groupIdx[next]
+		group += d.groupLen[next]
+	}
+	return d, idx, true
+}
+
+// leadingIndex numbers the squares of the leading group of a table without
+// pawns: three unique pieces together if there are any, or the kings.
+func leadingIndex(uniquePieces bool, squares []int) uint64 {
+	if !uniquePieces {
+		return uint64(mapKK[mapA1D1D4[squares[0]]][squares[1]])
+	}
+	adjust1 := 0
+	if squares[1] > squares[0] {
+		adjust1 = 1
+	}
+	adjust2 := 0
+	if squares[2] > squares[0] {
+		adjust2++
+	}
+	if squares[2] > squares[1] {
+		adjust2++
+	}
+	var idx int
+	switch {
+	case offDiagonal(squares[0]) != 0:
+		idx = (mapA1D1D4[squares[0]]*63+squares[1]-adjust1)*62 + squares[2] - adjust2
+	case offDiagonal(squares[1]) != 0:
+		idx = (6*63+rankOf(squares[0])*28+mapB1H1H7[squares[1]])*62 + squares[2] - adjust2
+	case offDiagonal(squares[2]) != 0:
+		idx = 6*63*62 + 4*28*62 + rankOf(squares[0])*7*28 + (rankOf(squares[1])-adjust1)*28 + mapB1H1H7[squares[2]]
+	default:
+		idx = 6*63*62 + 4*28*62 + 4*7*28 + rankOf(squares[0])*7*6 + (rankOf(squares[1])-adjust1)*6 + rankOf(squares[2]) - adjust2
+	}
+	return uint64(idx)
+}
+
+// sortSquares is an insertion sort, which keeps equal squares in order and is
+// quickest for the handful of pieces in a table.
+func sortSquares(squares []int, less func(a, b int) bool) {
+	for i := 1; i < len(squares); i++ {
+		for j := i; j > 0 && less(squares[j], squares[j-1]); j-- {
+			squares[j], squares[j-1] = squares[j-1], squares[j]
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package syzygy
+
+import (
+	"strings"
+
+	"github.com/mattellis91/go-chess/chess"
+)
+
+// probeState says how a lookup went, beyond its value.
+type probeState int
+
+const (
+	probeOK probeState = iota
+	// probeFail means a table the position needs is missing or broken.
+	probeFail
+	// zeroingBestMove means the best move is a capture or pawn move, so the
+	// DTZ table's value for the position does not matter.
+	zeroingBestMove
+	// changeSTM means the DTZ table only stores the other side to move.
+	changeSTM
+)
+
+// tbPiece returns the code the table files give a piece such as "bN": 1 to 6
+// for a white pawn, knight, bishop, rook, queen or king, and 9 to 14 for
+// black's.
+func tbPiece(code string) int {
+	piece := strings.IndexByte("pNBRQK", code[1]) + 1
+	if code[0] == 'b' {
+		piece += 8
+	}
+	return piece
+}
+
+// materialOf names the material in p the way tables are named.
+func materialOf(p *chess.Position) string {
+	var sides [2]string
+	for color := range sides {
+		sides[color] = "K"
+		for _, piece := range []int{chess.Queen, chess.Rook, chess.Bishop, chess.Knight, chess.Pawn} {
+			sides[color] += strings.Repeat(string("PNBRQ"[piece]), p.Pieces[color][piece].Count())
+		}
+	}
+	return sides[0] + "v" + sides[1]
+}
+
+func isCapture(move chess.Move) bool {
+	return move.PieceCaptured != "--" || move.IsEnPassant
+}
+
+func isZeroing(move chess.Move) bool {
+	return isCapture(move) || move.PieceMoved[1] == 'p'
+}
+
+// table returns the WDL or DTZ table for p, and whether black has the pieces
+// it names first.
+func (t *Tables) table(p *chess.Position, dtz bool) (*table, bool) {
+	tables := t.wdl
+	if dtz {
+		tables = t.dtz
+	}
+	material := materialOf(p)
+	if table := tables[material]; table != nil {
+		return table, false
+	}
+	sides := strings.Split(material, "v")
+	table := tables[sides[1]+"v"+sides[0]]
+	return table, true
+}
+
+// probeTable looks p up in its WDL table, or in its DTZ table for a position
+// whose result is wdl.
+func (t *Tables) probeTable(p *chess.Position, dtz bool, wdl chess.WDL) (int, probeState) {
+	if p.PieceCount() == 2 {
+		return int(chess.WDLDraw), probeOK
+	}
+	table, blackStronger := t.table(p, dtz)
+	if table == nil || table.load() != nil {
+		return 0, probeFail
+	}
+
+	// A symmetric table only stores white to move, so for black to move the
+	// board is turned round too.
+	flip := blackStronger || (table.symmetric && !p.WhiteToMove)
+	stm := 0
+	if p.WhiteToMove == flip {
+		stm = 1
+	}
+	var board [64]int
+	for occupied := p.Occupied(); occupied != 0; {
+		sq := occupied.PopFirst()
+		board[tbSquare(sq)] = tbPiece(p.PieceAt(sq))
+	}
+	d, idx, ok := table.encode(&board, stm, flip)
+	if !ok {
+		return 0, changeSTM
+	}
+	value, ok := d.value(idx)
+	if !ok {
+		return 0, probeFail
+	}
+	if !dtz {
+		return value - 2, probeOK
+	}
+	return table.mapScore(d, value, int(wdl)), probeOK
+}
+
+// search returns the result of p for the side to move. The tables leave out
+// positions where en passant is possible and may hold any value where a
+// capture is best, so the captures, and with checkZeroing the pawn moves,
+// are tried first.
+func (t *Tables) search(p *chess.Position, checkZeroing bool) (chess.WDL, probeState) {
+	best := chess.WDLLoss
+	moves := p.GetValidMoves()
+	searched := 0
+	for _, move := range moves {
+		if !isCapture(move) && (!checkZeroing || move.PieceMoved[1] != 'p') {
+			continue
+		}
+		searched++
+		p.MakeMove(move)
+		value, state := t.search(p, false)
+		p.UndoMove()
+		if state == probeFail {
+			return chess.WDLDraw, probeFail
+		}
+		if -value > best {
+			best = -value
+			if best == chess.WDLWin {
+				return best, zeroingBestMove
+			}
+		}
+	}
+
+	// With every move searched the table is not needed, and may be wrong.
+	allSearched := searched > 0 && searched == len(moves)
+	value := best
+	if !allSearched {
+		stored, state := t.probeTable(p, false, 0)
+		if state == probeFail {
+			return chess.WDLDraw, probeFail
+		}
+		value = chess.WDL(stored)
+	}
+	if best >= value {
+		if best > chess.WDLDraw || allSearched {
+			return best, zeroingBestMove
+		}
+		return best, probeOK
+	}
+	return value, probeOK
+}
+
+// dtzBeforeZeroing is the distance to zeroing of a position whose best move
+// is a capture or pawn move reaching a position with the result wdl.
+func dtzBeforeZeroing(wdl chess.WDL) int {
+	switch wdl {
+	case chess.WDLWin:
+		return 1
+	case chess.WDLCursedWin:
+		return 101
+	case chess.WDLBlessedLoss:
+		return -101
+	case chess.WDLLoss:
+		return -1
+	}
+	return 0
+}
+
+func sign(n int) int {
+	switch {
+	case n > 0:
+		return 1
+	case n < 0:
+		return -1
+	}
+	return 0
+}
+
+// probeDTZ returns the number of plies to the next capture or pawn move, or to
+// mate, positive if the side to move wins and negative if it loses. Over 100
+// is a win the fifty-move rule spoils. A mated position gives -1.
+func (t *Tables) probeDTZ(p *chess.Position) (int, bool) {
+	wdl, state := t.search(p, true)
+	switch {
+	case state == probeFail:
+		return 0, false
+	case wdl == chess.WDLDraw:
+		return 0, true
+	case state == zeroingBestMove:
+		return dtzBeforeZeroing(wdl), true
+	}
+
+	dtz, state := t.probeTable(p, true, wdl)
+	switch state {
+	case probeFail:
+		return 0, false
+	case probeOK:
+		if wdl == chess.WDLCursedWin || wdl == chess.WDLBlessedLoss {
+			dtz += 100
+		}
+		return dtz * sign(int(wdl)), true
+	}
+
+	// The table only has the other side to move, so find the best distance
+	// one move on.
+	best := 0xffff
+	for _, move := range p.GetValidMoves() {
+		zeroing := isZeroing(move)
+		p.MakeMove(move)
+		if zeroing {
+			result, state := t.search(p, false)
+			dtz = -dtzBeforeZeroing(result)
+			if state == probeFail {
+				p.UndoMove()
+				return 0, false
+			}
+		} else {
+			distance, ok := t.probeDTZ(p)
+			if !ok {
+				p.UndoMove()
+				return 0, false
+			}
+			dtz = -distance
+			if dtz == 1 && p.InCheck() && len(p.GetValidMoves()) == 0 {
+				best = 1
+			}
+			dtz += sign(dtz)
+		}
+		if dtz < best && sign(dtz) == sign(int(wdl)) {
+			best = dtz
+		}
+		p.UndoMove()
+	}
+	if best == 0xffff {
+		return -1, true
+	}
+	return best, true
+}
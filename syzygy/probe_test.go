@@ -0,0 +1,223 @@
+package syzygy
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/mattellis91/go-chess/chess"
+	"github.com/mattellis91/go-chess/endgame"
+	"github.com/mattellis91/go-chess/engine"
+)
+
+var long = flag.Bool("long", false, "probe every position in the tables")
+
+// tableDirs are where the tests find tables: the real Syzygy tables, and
+// those TestWriteTables writes. See testdata/README.md.
+var tableDirs = []struct {
+	name string
+	dir  string
+}{
+	{"syzygy", "testdata"},
+	{"written", writtenTables},
+}
+
+// forEachTables runs f as a subtest with the tables in each of tableDirs.
+func forEachTables(t *testing.T, f func(t *testing.T, tables *Tables)) {
+	for _, d := range tableDirs {
+		d := d
+		t.Run(d.name, func(t *testing.T) {
+			tables, err := Open(d.dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if wdl, _ := tables.Count(); wdl == 0 {
+				t.Skipf("no tables in %s, see testdata/README.md", d.dir)
+			}
+			f(t, tables)
+		})
+	}
+}
+
+func TestMapKK(t *testing.T) {
+	codes := map[int]bool{}
+	for first := range mapKK {
+		for second := 0; second < 64; second++ {
+			if code := mapKK[first][second]; code != 0 || first == 0 && second == 2 {
+				codes[code] = true
+			}
+		}
+	}
+	if len(codes) != 462 || !codes[0] || !codes[461] {
+		t.Errorf("mapKK has %d codes, want 0 to 461", len(codes))
+	}
+}
+
+// TestProbe checks positions whose results are known. Without pawns the
+// only capture or pawn move on the way to a win is the mate, so the distance
+// is the distance to mate.
+func TestProbe(t *testing.T) {
+	tests := []struct {
+		fen      string
+		wdl      chess.WDL
+		distance int
+	}{
+		{"k7/8/1K6/8/8/8/7Q/8 w - - 0 1", chess.WDLWin, 1},
+		{"k7/8/1K6/8/8/8/6Q1/8 b - - 0 1", chess.WDLLoss, 2},
+		{"k7/1Q6/1K6/8/8/8/8/8 b - - 0 1", chess.WDLLoss, 0},
+		// Stalemate, and a queen left to be taken.
+		{"k7/8/1K6/8/8/8/7Q/8 b - - 0 1", chess.WDLDraw, 0},
+		{"8/8/8/8/8/8/1kQ5/7K b - - 0 1", chess.WDLDraw, 0},
+		{"8/8/8/3k4/8/8/8/R3K3 w - - 0 1", chess.WDLWin, 27},
+		{"8/8/8/8/8/8/8/R3K2k w - - 0 1", chess.WDLWin, 5},
+		// The distance is to the pawn's next move, not to mate.
+		{"4k3/8/4K3/4P3/8/8/8/8 w - - 0 1", chess.WDLWin, 3},
+		{"4k3/8/4K3/4P3/8/8/8/8 b - - 0 1", chess.WDLLoss, 4},
+		{"4k3/8/8/8/8/8/4P3/4K3 w - - 0 1", chess.WDLWin, 9},
+		{"4k3/8/8/8/8/8/4P3/4K3 b - - 0 1", chess.WDLDraw, 0},
+		{"8/3k4/8/8/8/8/4P3/4K3 w - - 0 1", chess.WDLDraw, 0},
+		{"k7/8/8/8/8/8/P7/K7 w - - 0 1", chess.WDLDraw, 0},
+		// Black has the pawn, so the table is read with the board turned.
+		{"8/8/8/8/8/2k5/4p3/4K3 b - - 0 1", chess.WDLWin, 5},
+		{"8/8/8/8/3k4/8/4p3/4K3 w - - 0 1", chess.WDLDraw, 0},
+		// Two knights only mate if the defence blunders into it.
+		{"k7/8/NK6/3N4/8/8/8/8 w - - 0 1", chess.WDLWin, 1},
+		{"8/8/8/8/3n4/nk6/8/K7 b - - 0 1", chess.WDLWin, 1},
+		{"k7/2N5/NK6/8/8/8/8/8 b - - 0 1", chess.WDLLoss, 0},
+		{"4k3/8/8/8/8/8/8/1NN1K3 w - - 0 1", chess.WDLDraw, 0},
+	}
+	forEachTables(t, func(t *testing.T, tables *Tables) {
+		for _, tt := range tests {
+			gs, err := chess.NewGameStateFromFEN(tt.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if wdl, ok := tables.ProbeWDL(gs); wdl != tt.wdl || !ok {
+				t.Errorf("%s: ProbeWDL = %v, %v, want %v", tt.fen, wdl, ok, tt.wdl)
+			}
+			if distance, ok := tables.ProbeDistance(gs); distance != tt.distance || !ok {
+				t.Errorf("%s: ProbeDistance = %d, %v, want %d", tt.fen, distance, ok, tt.distance)
+			}
+		}
+	})
+}
+
+func TestProbeMissing(t *testing.T) {
+	forEachTables(t, func(t *testing.T, tables *Tables) {
+		for _, fen := range []string{
+			// No KBNvK table, and castling rights the tables do not know.
+			"4k3/8/8/8/8/8/8/2BNK3 w - - 0 1",
+			"4k3/8/8/8/8/8/8/R3K3 w Q - 0 1",
+		} {
+			gs, err := chess.NewGameStateFromFEN(fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := tables.ProbeWDL(gs); ok {
+				t.Errorf("%s: ProbeWDL found a result", fen)
+			}
+			if _, ok := tables.ProbeDistance(gs); ok {
+				t.Errorf("%s: ProbeDistance found a result", fen)
+			}
+		}
+	})
+}
+
+// TestEngineWithTables checks that the engine, with only the distances to
+// the next pawn move to go on, queens the pawn and mates.
+func TestEngineWithTables(t *testing.T) {
+	forEachTables(t, func(t *testing.T, tables *Tables) {
+		e := engine.NewEngine()
+		e.Tablebase = tables
+		gs, err := chess.NewGameStateFromFEN("4k3/8/8/8/8/8/4P3/4K3 w - - 0 1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for ply := 0; ply < 100 && len(gs.GetValidMoves()) > 0; ply++ {
+			move := e.Search(gs, engine.Limits{Depth: 2}).Move
+			gs.PlayMove(move)
+			want := chess.WDLLoss
+			if gs.WhiteToMove {
+				want = chess.WDLWin
+			}
+			if wdl, _ := tables.ProbeWDL(gs); wdl != want {
+				t.Fatalf("after %s the result is %v, want %v", move.GetUCINotation(), wdl, want)
+			}
+		}
+		if outcome := gs.Outcome(); outcome.Reason != chess.Checkmate {
+			t.Errorf("%s: game ended by %v", gs.FEN(), outcome.Reason)
+		}
+	})
+}
+
+// TestProbeAll checks every position in the tables, with either colour
+// having the pieces, against the endgame package's results.
+func TestProbeAll(t *testing.T) {
+	if !*long {
+		t.Skip("run with -long to probe every position in the tables")
+	}
+	g := endgame.NewGenerator(nil)
+	for _, material := range testMaterials {
+		if _, err := g.Generate(material); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tb := g.Tablebase()
+
+	forEachTables(t, func(t *testing.T, tables *Tables) {
+		for _, material := range testMaterials {
+			material := material
+			t.Run(material, func(t *testing.T) {
+				t.Parallel()
+				probeAll(t, tables, tb, material)
+			})
+		}
+	})
+}
+
+// probeAll checks each position with material against tb, and again with the
+// colours swapped and the board turned round.
+func probeAll(t *testing.T, tables *Tables, tb *endgame.Tablebase, material string) {
+	pieces := tablePieces(material)
+	swapped := make([]int, len(pieces))
+	for i, piece := range pieces {
+		swapped[i] = piece ^ 8
+	}
+	var zeroing func(squares []int) int
+	if newTestTable(material, false).hasPawns {
+		zeroing = distancesToZeroing(t, tb, pieces)
+	}
+	placements(pieces, func(squares []int) {
+		turned := make([]int, len(squares))
+		for i, sq := range squares {
+			turned[i] = sq ^ 56
+		}
+		for _, whiteToMove := range []bool{true, false} {
+			gs := setUp(pieces, squares, whiteToMove).GameState()
+			want, ok := tb.ProbeWDL(gs)
+			if !ok {
+				continue
+			}
+			// The DTZ tables with pawns only store white to move, and
+			// black's distance comes from a search not repeated here, so
+			// only whether it is 0 is checked.
+			wantDistance := -1
+			switch {
+			case want == chess.WDLDraw:
+				wantDistance = 0
+			case zeroing == nil:
+				wantDistance, _ = tb.ProbeDistance(gs)
+			case whiteToMove:
+				wantDistance = zeroing(squares)
+			}
+			for _, gs := range []*chess.GameState{gs, setUp(swapped, turned, !whiteToMove).GameState()} {
+				if wdl, ok := tables.ProbeWDL(gs); wdl != want || !ok {
+					t.Fatalf("%s: ProbeWDL = %v, %v, want %v", gs.FEN(), wdl, ok, want)
+				}
+				distance, ok := tables.ProbeDistance(gs)
+				if !ok || wantDistance >= 0 && distance != wantDistance || wantDistance < 0 && distance == 0 {
+					t.Fatalf("%s: ProbeDistance = %d, %v, want %d", gs.FEN(), distance, ok, wantDistance)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,193 @@
+// Package syzygy probes Syzygy endgame tablebases, so the engine and the GUI
+// can use them through the chess.Tablebase interface.
+//
+// The WDL tables give the result of a position, taking the fifty-move rule
+// into account, and the DTZ tables the distance to the next capture or pawn
+// move on the way to it. Each file is read into memory the first time a
+// position needs it.
+package syzygy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mattellis91/go-chess/chess"
+)
+
+const (
+	WDLSuffix = ".rtbw"
+	DTZSuffix = ".rtbz"
+)
+
+// The first four bytes of every table file.
+var (
+	wdlMagic = []byte{0x71, 0xe8, 0x23, 0x5d}
+	dtzMagic = []byte{0xd7, 0x66, 0x0c, 0xa5}
+)
+
+// Tables are the Syzygy tables found in one or more directories.
+type Tables struct {
+	// wdl and dtz map the material of each table, as in "KRPvKR", to the
+	// table.
+	wdl, dtz  map[string]*table
+	maxPieces int
+}
+
+// Open looks for tables in the directories listed in dirs, separated as in
+// the PATH environment variable, which is how the UCI SyzygyPath option
+// gives them. A file with a table's name but not its magic number is an
+// error.
+func Open(dirs string) (*Tables, error) {
+	t := &Tables{wdl: map[string]*table{}, dtz: map[string]*table{}}
+	for _, dir := range filepath.SplitList(dirs) {
+		if dir == "" {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			tables, magic, dtz := t.wdl, wdlMagic, false
+			if strings.HasSuffix(name, DTZSuffix) {
+				tables, magic, dtz = t.dtz, dtzMagic, true
+			} else if !strings.HasSuffix(name, WDLSuffix) {
+				continue
+			}
+			material := strings.TrimSuffix(name, filepath.Ext(name))
+			pieces, ok := pieceCount(material)
+			if !ok {
+				continue
+			}
+			path := filepath.Join(dir, name)
+			if err := checkMagic(path, magic); err != nil {
+				return nil, err
+			}
+			tables[material] = newTable(path, material, dtz)
+			if pieces > t.maxPieces {
+				t.maxPieces = pieces
+			}
+		}
+	}
+	return t, nil
+}
+
+func checkMagic(path string, magic []byte) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	header := make([]byte, len(magic))
+	if _, err := io.ReadFull(f, header); err != nil || !bytes.Equal(header, magic) {
+		return fmt.Errorf("syzygy: %s is not a Syzygy table", path)
+	}
+	return nil
+}
+
+// pieceCount returns the number of pieces in a table name such as "KRPvKR".
+func pieceCount(material string) (int, bool) {
+	sides := strings.Split(material, "v")
+	if len(sides) != 2 {
+		return 0, false
+	}
+	for _, side := range sides {
+		if len(side) == 0 || side[0] != 'K' || strings.Trim(side[1:], "QRBNP") != "" {
+			return 0, false
+		}
+	}
+	return len(sides[0]) + len(sides[1]), true
+}
+
+// Count returns the number of WDL and DTZ tables found.
+func (t *Tables) Count() (wdl int, dtz int) {
+	return len(t.wdl), len(t.dtz)
+}
+
+// MaxPieces returns the number of pieces in the largest table found.
+func (t *Tables) MaxPieces() int {
+	return t.maxPieces
+}
+
+// Material returns the pieces in gs as Syzygy names its tables, strongest
+// piece first: white's pieces and then black's, as in "KRPvKR".
+func Material(gs *chess.GameState) string {
+	var pieces [2]string
+	for _, piece := range "KQRBNp" {
+		for r := 0; r < 8; r++ {
+			for c := 0; c < 8; c++ {
+				if square := gs.Board[r][c]; square != "--" && rune(square[1]) == piece {
+					side := 0
+					if square[0] == 'b' {
+						side = 1
+					}
+					pieces[side] += strings.ToUpper(string(piece))
+				}
+			}
+		}
+	}
+	return pieces[0] + "v" + pieces[1]
+}
+
+// Has reports whether there is a WDL table for the position in gs. A table
+// serves both colours, so it may be named with black's pieces first.
+func (t *Tables) Has(gs *chess.GameState) bool {
+	material := Material(gs)
+	sides := strings.Split(material, "v")
+	_, ok := t.wdl[material]
+	_, swapped := t.wdl[sides[1]+"v"+sides[0]]
+	return ok || swapped
+}
+
+// position returns gs as a Position to look up, or false if the tables
+// cannot know it: they have no castling, and only so many pieces.
+func (t *Tables) position(gs *chess.GameState) (*chess.Position, bool) {
+	if gs.CastleRights != (chess.CastleRights{}) || gs.PieceCount() > t.maxPieces {
+		return nil, false
+	}
+	return chess.NewPosition(gs), true
+}
+
+// ProbeWDL returns the result for the side to move from the WDL tables. A win
+// or loss the fifty-move rule turns into a draw is a cursed win or blessed
+// loss.
+func (t *Tables) ProbeWDL(gs *chess.GameState) (chess.WDL, bool) {
+	p, ok := t.position(gs)
+	if !ok {
+		return chess.WDLDraw, false
+	}
+	wdl, state := t.search(p, false)
+	return wdl, state != probeFail
+}
+
+// ProbeDistance returns the number of plies, with best play, to the capture
+// or pawn move that reaches a won position, or to mate, from the DTZ tables.
+// It is 0 for a draw or a mated position, and over 100 for a win the
+// fifty-move rule spoils.
+func (t *Tables) ProbeDistance(gs *chess.GameState) (int, bool) {
+	p, ok := t.position(gs)
+	if !ok {
+		return 0, false
+	}
+	dtz, ok := t.probeDTZ(p)
+	switch {
+	case !ok:
+		return 0, false
+	case dtz < 0 && p.InCheck() && len(p.GetValidMoves()) == 0:
+		return 0, true
+	case dtz < 0:
+		return -dtz, true
+	}
+	return dtz, true
+}
+
+// DistanceToZeroing reports that ProbeDistance counts to the next capture or
+// pawn move, which starts the count again, rather than to mate.
+func (t *Tables) DistanceToZeroing() bool {
+	return true
+}
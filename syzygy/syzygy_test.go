@@ -0,0 +1,62 @@
+package syzygy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mattellis91/go-chess/chess"
+)
+
+// writeTable writes a file with only a table's name and magic number, which
+// Open accepts and probing finds truncated.
+func writeTable(t *testing.T, dir string, name string, magic []byte) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), append(magic, 0, 0, 0, 0), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir, other := t.TempDir(), t.TempDir()
+	writeTable(t, dir, "KQvK.rtbw", wdlMagic)
+	writeTable(t, dir, "KQvK.rtbz", dtzMagic)
+	writeTable(t, other, "KRPvKR.rtbw", wdlMagic)
+	writeTable(t, other, "README.txt", nil)
+
+	tables, err := Open(dir + string(os.PathListSeparator) + other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wdl, dtz := tables.Count(); wdl != 2 || dtz != 1 || tables.MaxPieces() != 5 {
+		t.Errorf("found %d WDL and %d DTZ tables of up to %d pieces", wdl, dtz, tables.MaxPieces())
+	}
+
+	for fen, want := range map[string]bool{
+		"4k3/8/8/8/8/8/8/3QK3 w - - 0 1":    true,
+		"4k3/8/8/8/8/8/3q4/6K1 b - - 0 1":   true,
+		"3rk3/8/8/8/8/8/4P3/3RK3 w - - 0 1": true,
+		"4k3/8/8/8/8/8/8/3RK3 w - - 0 1":    false,
+	} {
+		gs, _ := chess.NewGameStateFromFEN(fen)
+		if got := tables.Has(gs); got != want {
+			t.Errorf("%s (%s): Has = %v, want %v", fen, Material(gs), got, want)
+		}
+		if _, ok := tables.ProbeWDL(gs); ok {
+			t.Errorf("%s: probed a truncated table", fen)
+		}
+	}
+
+	writeTable(t, other, "KBvK.rtbw", dtzMagic)
+	if _, err := Open(other); err == nil || !strings.Contains(err.Error(), "KBvK.rtbw") {
+		t.Errorf("a table with the wrong magic number gave %v", err)
+	}
+}
+
+func TestMaterial(t *testing.T) {
+	gs, _ := chess.NewGameStateFromFEN("3rk3/8/8/8/8/8/4P3/3RK3 w - - 0 1")
+	if got := Material(gs); got != "KRPvKR" {
+		t.Errorf("got %s, want KRPvKR", got)
+	}
+}
@@ -0,0 +1,551 @@
+package syzygy
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// maxPieces is the most pieces, kings included, a Syzygy table can have.
+const maxPieces = 7
+
+// Flags of the table as a whole, in the byte after the magic number.
+const (
+	splitFlag    = 1
+	hasPawnsFlag = 2
+)
+
+// Flags of each part of a table.
+const (
+	// stmFlag is set in a DTZ table that stores the positions with black
+	// to move, in the table's colours.
+	stmFlag = 1
+	// mappedFlag means a DTZ table's values go through a map of the
+	// distances each result actually has.
+	mappedFlag = 2
+	// winPliesFlag and lossPliesFlag mean won or lost distances are stored
+	// in plies rather than moves.
+	winPliesFlag  = 4
+	lossPliesFlag = 8
+	// wideFlag means the map holds 16-bit values.
+	wideFlag = 16
+	// singleValueFlag means every position has the same value, and nothing
+	// else is stored.
+	singleValueFlag = 128
+)
+
+// table is one WDL or DTZ file. It is read into memory the first time a
+// position is looked up in it.
+type table struct {
+	path string
+	// material names the table's pieces, the stronger side's first. They
+	// are white in the file, so positions where black has them are turned
+	// round to be looked up.
+	material        string
+	dtz             bool
+	symmetric       bool
+	hasPawns        bool
+	hasUniquePieces bool
+	pieceCount      int
+	// pawnCount is the number of pawns of the leading colour, the side with
+	// fewer pawns but at least one, and then of the other.
+	pawnCount [2]int
+
+	once sync.Once
+	err  error
+	data []byte
+	// pairs holds the compressed values for each side to move (only the
+	// first for DTZ tables and symmetric WDL tables) and, in pawn tables,
+	// for each file of the leading pawn, a to d.
+	pairs [2][4]*pairsData
+}
+
+func newTable(path string, material string, dtz bool) *table {
+	t := &table{path: path, material: material, dtz: dtz}
+	sides := strings.Split(material, "v")
+	t.symmetric = sides[0] == sides[1]
+	var pawns [2]int
+	for i, side := range sides {
+		t.pieceCount += len(side)
+		pawns[i] = strings.Count(side, "P")
+		for _, piece := range "QRBNP" {
+			if strings.Count(side, string(piece)) == 1 {
+				t.hasUniquePieces = true
+			}
+		}
+	}
+	t.hasPawns = pawns[0]+pawns[1] > 0
+	t.pawnCount = pawns
+	if pawns[1] > 0 && (pawns[0] == 0 || pawns[1] < pawns[0]) {
+		t.pawnCount = [2]int{pawns[1], pawns[0]}
+	}
+	return t
+}
+
+// load reads the table the first time it is needed.
+func (t *table) load() error {
+	t.once.Do(func() {
+		data, err := os.ReadFile(t.path)
+		if err != nil {
+			t.err = err
+			return
+		}
+		if err := t.parse(data); err != nil {
+			t.err = fmt.Errorf("syzygy: %s: %v", t.path, err)
+		}
+	})
+	return t.err
+}
+
+// reader walks through a table's headers, remembering whether it ran off the
+// end of the file.
+type reader struct {
+	data  []byte
+	pos   int
+	short bool
+}
+
+func (r *reader) next(n int) []byte {
+	if n < 0 || r.pos+n > len(r.data) {
+		// Carry on with zeros, which is no more than the file's size, and
+		// let the caller check short.
+		r.short = true
+		r.pos = len(r.data)
+		if n < 1 || n > len(r.data) {
+			n = 1
+		}
+		return make([]byte, n)
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *reader) u8() int {
+	return int(r.next(1)[0])
+}
+
+// align moves on to the next multiple of n bytes from the start of the file.
+func (r *reader) align(n int) {
+	if rem := r.pos % n; rem != 0 {
+		r.next(n - rem)
+	}
+}
+
+// parse sets up the table from the contents of its file.
+func (t *table) parse(data []byte) error {
+	magic := wdlMagic
+	if t.dtz {
+		magic = dtzMagic
+	}
+	if len(data) < 5 || string(data[:4]) != string(magic) {
+		return fmt.Errorf("not a Syzygy table")
+	}
+	flags := int(data[4])
+	if (flags&hasPawnsFlag != 0) != t.hasPawns || (flags&splitFlag != 0) == t.symmetric {
+		return fmt.Errorf("file does not hold %s", t.material)
+	}
+	t.data = data
+	r := &reader{data: data, pos: 5}
+
+	sides, files := 1, 1
+	if !t.dtz && !t.symmetric {
+		sides = 2
+	}
+	if t.hasPawns {
+		files = 4
+	}
+	bothPawns := t.hasPawns && t.pawnCount[1] > 0
+
+	for f := 0; f < files; f++ {
+		for side := 0; side < sides; side++ {
+			t.pairs[side][f] = &pairsData{}
+		}
+		orders := r.u8()
+		order := [2][2]int{{orders & 0xf, 0xf}, {orders >> 4, 0xf}}
+		if bothPawns {
+			second := r.u8()
+			order[0][1], order[1][1] = second&0xf, second>>4
+		}
+		for k := 0; k < t.pieceCount; k++ {
+			piece := r.u8()
+			for side := 0; side < sides; side++ {
+				t.pairs[side][f].pieces[k] = piece & 0xf
+				if side == 1 {
+					t.pairs[side][f].pieces[k] = piece >> 4
+				}
+			}
+		}
+		for side := 0; side < sides; side++ {
+			if err := t.setGroups(t.pairs[side][f], order[side], f); err != nil {
+				return err
+			}
+		}
+	}
+	r.align(2)
+
+	for f := 0; f < files; f++ {
+		for side := 0; side < sides; side++ {
+			if err := t.pairs[side][f].setSizes(r); err != nil {
+				return err
+			}
+		}
+	}
+	if t.dtz {
+		t.setMaps(r, files)
+		r.align(2)
+	}
+	for f := 0; f < files; f++ {
+		for side := 0; side < sides; side++ {
+			d := t.pairs[side][f]
+			d.sparseIndex = r.next(6 * d.sparseIndexSize)
+		}
+	}
+	for f := 0; f < files; f++ {
+		for side := 0; side < sides; side++ {
+			d := t.pairs[side][f]
+			d.blockLength = r.next(2 * d.blockLengthSize)
+		}
+	}
+	for f := 0; f < files; f++ {
+		for side := 0; side < sides; side++ {
+			d := t.pairs[side][f]
+			if d.flags&singleValueFlag == 0 {
+				r.align(64)
+				d.data = r.next(d.numBlocks * d.blockSize)
+			}
+		}
+	}
+	if r.short {
+		return fmt.Errorf("file is too short")
+	}
+	return nil
+}
+
+// setGroups splits the pieces into the groups the index is built from: the
+// leading pawns or pieces, then each set of identical pieces. order gives the
+// place of the leading group, and of the other side's pawns if both sides
+// have some, in the index.
+func (t *table) setGroups(d *pairsData, order [2]int, file int) error {
+	firstLen := 2
+	if t.hasPawns {
+		firstLen = 0
+	} else if t.hasUniquePieces {
+		firstLen = 3
+	}
+	n := 0
+	d.groupLen[0] = 1
+	for i := 1; i < t.pieceCount; i++ {
+		firstLen--
+		if firstLen > 0 || d.pieces[i] == d.pieces[i-1] {
+			d.groupLen[n]++
+		} else {
+			n++
+			d.groupLen[n] = 1
+		}
+	}
+	n++
+	d.groupLen[n] = 0
+
+	bothPawns := t.hasPawns && t.pawnCount[1] > 0
+	next, free := 1, 64-d.groupLen[0]
+	if bothPawns {
+		next, free = 2, free-d.groupLen[1]
+	}
+	idx := uint64(1)
+	for k := 0; next < n || k == order[0] || k == order[1]; k++ {
+		switch {
+		case k == order[0]:
+			d.groupIdx[0] = idx
+			switch {
+			case t.hasPawns:
+				idx *= uint64(leadPawnsSize[d.groupLen[0]][file])
+			case t.hasUniquePieces:
+				idx *= 31332
+			default:
+				idx *= 462
+			}
+		case k == order[1]:
+			d.groupIdx[1] = idx
+			idx *= uint64(binomial[d.groupLen[1]][48-d.groupLen[0]])
+		default:
+			if d.groupLen[next] >= len(binomial) {
+				return fmt.Errorf("too many identical pieces")
+			}
+			d.groupIdx[next] = idx
+			idx *= uint64(binomial[d.groupLen[next]][free])
+			free -= d.groupLen[next]
+			next++
+		}
+	}
+	d.groupIdx[n] = idx
+	d.size = idx
+	return nil
+}
+
+// setMaps finds the maps of a DTZ table from distances as stored to real
+// ones, one for each of win, loss, cursed win and blessed loss.
+func (t *table) setMaps(r *reader, files int) {
+	for f := 0; f < files; f++ {
+		d := t.pairs[0][f]
+		if d.flags&mappedFlag == 0 {
+			continue
+		}
+		if d.flags&wideFlag != 0 {
+			r.align(2)
+			for i := range d.mapIdx {
+				count := int(binary.LittleEndian.Uint16(r.next(2)))
+				d.mapIdx[i] = r.pos
+				r.next(2 * count)
+			}
+		} else {
+			for i := range d.mapIdx {
+				count := r.u8()
+				d.mapIdx[i] = r.pos
+				r.next(count)
+			}
+		}
+	}
+}
+
+// pairsData is one part of a table: the values of every position with one
+// side to move and, with pawns, the leading pawn on one file, compressed
+// with canonical Huffman codes for symbols that each stand for a run of
+// values, a symbol expanding into a pair of symbols at a time.
+type pairsData struct {
+	flags     int
+	maxSymLen int
+	minSymLen int
+	numBlocks int
+	blockSize int
+	span      uint64
+	// lowestSym[l] is the first symbol with a code of minSymLen+l bits, and
+	// base[l] that code padded to 64 bits.
+	lowestSym []byte
+	base      []uint64
+	// btree holds the two symbols each symbol expands into, or the value of
+	// a leaf, and symLen one less than the number of values it stands for.
+	btree  []byte
+	symLen []int
+	// blockLength holds one less than the number of values in each block,
+	// and sparseIndex the block and offset of every span'th value.
+	blockLength     []byte
+	blockLengthSize int
+	sparseIndex     []byte
+	sparseIndexSize int
+	data            []byte
+
+	pieces   [maxPieces]int
+	groupIdx [maxPieces + 1]uint64
+	groupLen [maxPieces + 1]int
+	size     uint64
+	// mapIdx is the position in the file of the DTZ map for each result.
+	mapIdx [4]int
+}
+
+func (d *pairsData) setSizes(r *reader) error {
+	d.flags = r.u8()
+	if d.flags&singleValueFlag != 0 {
+		d.minSymLen = r.u8()
+		return nil
+	}
+	d.blockSize = 1 << r.u8()
+	d.span = 1 << r.u8()
+	d.sparseIndexSize = int((d.size + d.span - 1) / d.span)
+	padding := r.u8()
+	d.numBlocks = int(binary.LittleEndian.Uint32(r.next(4)))
+	d.blockLengthSize = d.numBlocks + padding
+	d.maxSymLen = r.u8()
+	d.minSymLen = r.u8()
+	if d.minSymLen == 0 || d.maxSymLen < d.minSymLen || d.maxSymLen > 32 {
+		return fmt.Errorf("bad symbol lengths %d to %d", d.minSymLen, d.maxSymLen)
+	}
+	lengths := d.maxSymLen - d.minSymLen + 1
+	d.lowestSym = r.next(2 * lengths)
+	if r.short {
+		return fmt.Errorf("file is too short")
+	}
+
+	// Longer codes have lower values, so working up from the longest, the
+	// first code of each length follows on from the ones a bit longer.
+	d.base = make([]uint64, lengths)
+	for i := lengths - 2; i >= 0; i-- {
+		d.base[i] = (d.base[i+1] + uint64(d.lowest(i)) - uint64(d.lowest(i+1))) / 2
+	}
+	for i := range d.base {
+		d.base[i] <<= 64 - i - d.minSymLen
+	}
+
+	symbols := int(binary.LittleEndian.Uint16(r.next(2)))
+	d.btree = r.next(3 * symbols)
+	r.next(symbols & 1)
+	if r.short {
+		return fmt.Errorf("file is too short")
+	}
+	d.symLen = make([]int, symbols)
+	visited := make([]bool, symbols)
+	for sym := range d.symLen {
+		if !visited[sym] {
+			length, ok := d.setSymLen(sym, visited)
+			if !ok {
+				return fmt.Errorf("bad symbol %d", sym)
+			}
+			d.symLen[sym] = length
+		}
+	}
+	return nil
+}
+
+func (d *pairsData) lowest(i int) uint16 {
+	return binary.LittleEndian.Uint16(d.lowestSym[2*i:])
+}
+
+func (d *pairsData) left(sym int) int {
+	return int(d.btree[3*sym]) | int(d.btree[3*sym+1]&0xf)<<8
+}
+
+func (d *pairsData) right(sym int) int {
+	return int(d.btree[3*sym+1]>>4) | int(d.btree[3*sym+2])<<4
+}
+
+// leaf marks a symbol that stands for a single value.
+const leaf = 0xfff
+
+func (d *pairsData) setSymLen(sym int, visited []bool) (int, bool) {
+	visited[sym] = true
+	right := d.right(sym)
+	if right == leaf {
+		return 0, true
+	}
+	left := d.left(sym)
+	if left >= len(d.symLen) || right >= len(d.symLen) {
+		return 0, false
+	}
+	for _, child := range []int{left, right} {
+		if !visited[child] {
+			length, ok := d.setSymLen(child, visited)
+			if !ok {
+				return 0, false
+			}
+			d.symLen[child] = length
+		}
+	}
+	return d.symLen[left] + d.symLen[right] + 1, true
+}
+
+func (d *pairsData) blockLen(block int) int {
+	return int(binary.LittleEndian.Uint16(d.blockLength[2*block:]))
+}
+
+// word returns the i'th big-endian 32-bit word of block, or 0 past the end
+// of the file.
+func (d *pairsData) word(block []byte, i int) uint64 {
+	if 4*i+4 > len(block) {
+		return 0
+	}
+	return uint64(binary.BigEndian.Uint32(block[4*i:]))
+}
+
+// value decompresses the value at idx.
+func (d *pairsData) value(idx uint64) (int, bool) {
+	if d.flags&singleValueFlag != 0 {
+		return d.minSymLen, true
+	}
+	k := int(idx / d.span)
+	if idx >= d.size || k >= d.sparseIndexSize {
+		return 0, false
+	}
+
+	// The sparse index gives the block and offset of value k*span+span/2,
+	// from which the blocks are walked to the one holding idx.
+	entry := d.sparseIndex[6*k:]
+	block := int(binary.LittleEndian.Uint32(entry))
+	offset := int(binary.LittleEndian.Uint16(entry[4:]))
+	if block >= d.blockLengthSize {
+		return 0, false
+	}
+	offset += int(idx%d.span) - int(d.span/2)
+	for offset < 0 {
+		if block--; block < 0 {
+			return 0, false
+		}
+		offset += d.blockLen(block) + 1
+	}
+	for block < d.blockLengthSize && offset > d.blockLen(block) {
+		offset -= d.blockLen(block) + 1
+		block++
+	}
+	if block >= d.numBlocks {
+		return 0, false
+	}
+
+	// Read symbols from the start of the block until the one that covers
+	// offset.
+	data := d.data[block*d.blockSize : (block+1)*d.blockSize]
+	buf := d.word(data, 0)<<32 | d.word(data, 1)
+	next, bits := 2, 64
+	var sym int
+	for {
+		l := 0
+		for buf < d.base[l] {
+			l++
+		}
+		sym = int(uint16((buf-d.base[l])>>(64-l-d.minSymLen)) + d.lowest(l))
+		if sym >= len(d.symLen) {
+			return 0, false
+		}
+		if offset < d.symLen[sym]+1 {
+			break
+		}
+		offset -= d.symLen[sym] + 1
+		l += d.minSymLen
+		buf <<= l
+		bits -= l
+		if bits <= 32 {
+			bits += 32
+			buf |= d.word(data, next) << (64 - bits)
+			next++
+		}
+	}
+
+	// Then expand the symbol down to the value.
+	for d.symLen[sym] != 0 {
+		left := d.left(sym)
+		if offset < d.symLen[left]+1 {
+			sym = left
+		} else {
+			offset -= d.symLen[left] + 1
+			sym = d.right(sym)
+		}
+	}
+	return d.left(sym), true
+}
+
+// mapScore turns a value from a DTZ table into a distance in plies for a
+// position with the result wdl, which is never a draw.
+func (t *table) mapScore(d *pairsData, value int, wdl int) int {
+	// The maps are for win, loss, cursed win and blessed loss.
+	resultMap := [5]int{1, 3, 0, 2, 0}[wdl+2]
+	if d.flags&mappedFlag != 0 {
+		at := d.mapIdx[resultMap]
+		if d.flags&wideFlag != 0 {
+			at += 2 * value
+			if at+2 > len(t.data) {
+				return 0
+			}
+			value = int(binary.LittleEndian.Uint16(t.data[at:]))
+		} else {
+			at += value
+			if at >= len(t.data) {
+				return 0
+			}
+			value = int(t.data[at])
+		}
+	}
+	if (wdl == 2 && d.flags&winPliesFlag == 0) || (wdl == -2 && d.flags&lossPliesFlag == 0) || wdl == 1 || wdl == -1 {
+		value *= 2
+	}
+	return value + 1
+}
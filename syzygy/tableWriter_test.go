@@ -0,0 +1,648 @@
+package syzygy
+
+import (
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/mattellis91/go-chess/chess"
+	"github.com/mattellis91/go-chess/endgame"
+)
+
+var update = flag.Bool("update", false, "write the tables in testdata/written from the endgame package's tables")
+
+// testMaterials are the tables the tests probe. The real Syzygy tables for
+// them belong in testdata, and TestWriteTables writes its own in the same
+// format to writtenTables, from the results of the endgame package's
+// retrograde analysis.
+var testMaterials = []string{"KQvK", "KRvK", "KBvK", "KNvK", "KPvK", "KNNvK"}
+
+const writtenTables = "testdata/written"
+
+func TestWriteTables(t *testing.T) {
+	if !*update {
+		t.Skip("run with -update to write the tables in testdata/written")
+	}
+	g := endgame.NewGenerator(nil)
+	for _, material := range testMaterials {
+		if _, err := g.Generate(material); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(writtenTables, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, material := range testMaterials {
+		writeTestTables(t, g.Tablebase(), material)
+	}
+}
+
+// pieceName is the chess package's name for a table's piece code.
+func pieceName(code int) string {
+	color := "w"
+	if code&8 != 0 {
+		color = "b"
+	}
+	return color + string("pNBRQK"[code&7-1])
+}
+
+// tablePieces lists the pieces of material in the order the tables give
+// them: the pawns first, then the kings, then the rest.
+func tablePieces(material string) []int {
+	sides := strings.Split(material, "v")
+	pieces := []int{}
+	for color, side := range sides {
+		for i := 0; i < strings.Count(side, "P"); i++ {
+			pieces = append(pieces, 1+8*color)
+		}
+	}
+	pieces = append(pieces, 6, 14)
+	for color, side := range sides {
+		for _, piece := range side {
+			if piece != 'K' && piece != 'P' {
+				pieces = append(pieces, strings.IndexRune("PNBRQK", piece)+1+8*color)
+			}
+		}
+	}
+	return pieces
+}
+
+// newTestTable returns a table for material with its pieces and groups set
+// up as TestWriteTables writes them.
+func newTestTable(material string, dtz bool) *table {
+	tbl := newTable("", material, dtz)
+	sides, files := 2, 1
+	if dtz || tbl.symmetric {
+		sides = 1
+	}
+	if tbl.hasPawns {
+		files = 4
+	}
+	for side := 0; side < sides; side++ {
+		for f := 0; f < files; f++ {
+			d := &pairsData{}
+			copy(d.pieces[:], tablePieces(material))
+			if dtz {
+				d.flags = winPliesFlag | lossPliesFlag
+			}
+			tbl.setGroups(d, [2]int{0, 0xf}, f)
+			tbl.pairs[side][f] = d
+		}
+	}
+	return tbl
+}
+
+// placements calls f with every way to put pieces on distinct squares, with
+// pawns off the first and last ranks and identical pieces on increasing
+// squares.
+func placements(pieces []int, f func(squares []int)) {
+	squares := make([]int, len(pieces))
+	var place func(i int)
+	place = func(i int) {
+		if i == len(pieces) {
+			f(squares)
+			return
+		}
+	next:
+		for sq := 0; sq < 64; sq++ {
+			if pieces[i]&7 == 1 && (sq < 8 || sq >= 56) {
+				continue
+			}
+			if i > 0 && pieces[i] == pieces[i-1] && sq <= squares[i-1] {
+				continue
+			}
+			for _, other := range squares[:i] {
+				if other == sq {
+					continue next
+				}
+			}
+			squares[i] = sq
+			place(i + 1)
+		}
+	}
+	place(0)
+}
+
+func setUp(pieces []int, squares []int, whiteToMove bool) *chess.Position {
+	p := chess.NewEmptyPosition(whiteToMove)
+	for i, sq := range squares {
+		p.PutPiece(sq, pieceName(pieces[i]))
+	}
+	return p
+}
+
+// writeTestTables writes the WDL and DTZ tables for material. Positions that
+// cannot arise, and in the DTZ table draws, are left for the compression to
+// fill in as it likes.
+func writeTestTables(t *testing.T, tb *endgame.Tablebase, material string) {
+	pieces := tablePieces(material)
+	wdlTable, dtzTable := newTestTable(material, false), newTestTable(material, true)
+	values, seen := map[*pairsData][]int{}, map[*pairsData][]bool{}
+	for _, tbl := range []*table{wdlTable, dtzTable} {
+		for _, sides := range tbl.pairs {
+			for _, d := range sides {
+				if d != nil {
+					values[d], seen[d] = make([]int, d.size), make([]bool, d.size)
+					for i := range values[d] {
+						values[d][i] = -1
+					}
+				}
+			}
+		}
+	}
+
+	var zeroing func(squares []int) int
+	if wdlTable.hasPawns {
+		zeroing = distancesToZeroing(t, tb, pieces)
+	} else if !strings.HasSuffix(material, "vK") {
+		t.Fatalf("%s: only tables against a bare king can take DTZ from distance to mate", material)
+	}
+
+	placements(pieces, func(squares []int) {
+		var board [64]int
+		for i, sq := range squares {
+			board[tbSquare(sq)] = pieces[i]
+		}
+		for stm, whiteToMove := range []bool{true, false} {
+			d, idx, _ := wdlTable.encode(&board, stm, false)
+			dtzPart, dtzIdx, dtzStored := dtzTable.encode(&board, stm, false)
+			if seen[d][idx] && (!dtzStored || seen[dtzPart][dtzIdx]) {
+				continue
+			}
+			// Positions that cannot arise may share an index with one that
+			// can, so only the legal ones count.
+			gs := setUp(pieces, squares, whiteToMove).GameState()
+			wdl, ok := tb.ProbeWDL(gs)
+			if !ok {
+				continue
+			}
+			seen[d][idx] = true
+			if dtzStored {
+				seen[dtzPart][dtzIdx] = true
+			}
+			values[d][idx] = int(wdl) + 2
+			if !dtzStored || wdl == chess.WDLDraw {
+				continue
+			}
+			var distance int
+			if zeroing != nil {
+				distance = zeroing(squares)
+			} else {
+				distance, _ = tb.ProbeDistance(gs)
+			}
+			if distance > 100 {
+				t.Fatalf("%s: %s wins in %d plies, a cursed win", material, gs.FEN(), distance)
+			}
+			if distance == 0 {
+				// Mated, which probeDTZ gives as -1.
+				distance = 1
+			}
+			values[dtzPart][dtzIdx] = distance - 1
+		}
+	})
+
+	for _, tbl := range []*table{wdlTable, dtzTable} {
+		data := tableFile(tbl, values)
+		suffix := WDLSuffix
+		if tbl.dtz {
+			suffix = DTZSuffix
+		}
+		if err := os.WriteFile(filepath.Join(writtenTables, material+suffix), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// distancesToZeroing works out the DTZ, as probeDTZ gives it, of every
+// position in a table with pawns with white to move, from the endgame
+// package's results. A win is a ply from a capture or pawn move that keeps
+// it, or from mate, and otherwise a ply further than the defence's best
+// reply; a loss is as far as the best move leads. These are taken over and
+// over until nothing changes.
+func distancesToZeroing(t *testing.T, tb *endgame.Tablebase, pieces []int) func(squares []int) int {
+	key := func(squares []int, whiteToMove bool) int {
+		k := 0
+		for _, sq := range squares {
+			k = k*64 + sq
+		}
+		if whiteToMove {
+			return 2 * k
+		}
+		return 2*k + 1
+	}
+	type state struct {
+		wdl chess.WDL
+		// now is set for a win with a capture or pawn move that keeps it,
+		// or mate. zeroing counts the captures and pawn moves of a loss.
+		now      bool
+		zeroing  int
+		moves    int
+		children []int
+	}
+	size := 2
+	for range pieces {
+		size *= 64
+	}
+	states := make([]*state, size)
+	placements(pieces, func(squares []int) {
+		for _, whiteToMove := range []bool{true, false} {
+			wdl, ok := tb.ProbeWDL(setUp(pieces, squares, whiteToMove).GameState())
+			if ok && wdl != chess.WDLDraw {
+				states[key(squares, whiteToMove)] = &state{wdl: wdl}
+			}
+		}
+	})
+	placements(pieces, func(squares []int) {
+		for _, whiteToMove := range []bool{true, false} {
+			s := states[key(squares, whiteToMove)]
+			if s == nil {
+				continue
+			}
+			p := setUp(pieces, squares, whiteToMove)
+			moves := p.GetValidMoves()
+			s.moves = len(moves)
+			for _, move := range moves {
+				from, to := move.StartRow*8+move.StartCol, move.EndRow*8+move.EndCol
+				if isZeroing(move) {
+					p.MakeMove(move)
+					wdl, _ := tb.ProbeWDL(p.GameState())
+					p.UndoMove()
+					s.now = s.now || wdl == chess.WDLLoss
+					s.zeroing++
+					continue
+				}
+				child := append([]int(nil), squares...)
+				for i, sq := range child {
+					if sq == from {
+						child[i] = to
+					}
+				}
+				p.MakeMove(move)
+				mate := p.InCheck() && len(p.GetValidMoves()) == 0
+				p.UndoMove()
+				s.now = s.now || mate
+				if c := states[key(child, !whiteToMove)]; c != nil && c.wdl == -s.wdl {
+					s.children = append(s.children, key(child, !whiteToMove))
+				}
+			}
+		}
+	})
+
+	distances := make([]int, size)
+	for changed := true; changed; {
+		changed = false
+		for k, s := range states {
+			if s == nil {
+				continue
+			}
+			distance := 0
+			switch {
+			case s.wdl == chess.WDLWin && s.now:
+				distance = 1
+			case s.wdl == chess.WDLWin:
+				for _, child := range s.children {
+					if d := distances[child]; d != 0 && (distance == 0 || d+1 < distance) {
+						distance = d + 1
+					}
+				}
+			case len(s.children)+s.zeroing < s.moves:
+				t.Fatalf("a lost position has a move that does not lose")
+			default:
+				distance = 1
+				for _, child := range s.children {
+					d := distances[child]
+					if d == 0 {
+						distance = 0
+						break
+					}
+					if d+1 > distance {
+						distance = d + 1
+					}
+				}
+			}
+			if distance != distances[k] {
+				distances[k], changed = distance, true
+			}
+		}
+	}
+	return func(squares []int) int {
+		return distances[key(squares, true)]
+	}
+}
+
+// tableFile lays out a table file with the compressed values of each of its
+// parts.
+func tableFile(tbl *table, values map[*pairsData][]int) []byte {
+	data := append([]byte{}, wdlMagic...)
+	if tbl.dtz {
+		data = append([]byte{}, dtzMagic...)
+	}
+	flags := byte(0)
+	if !tbl.symmetric {
+		flags |= splitFlag
+	}
+	if tbl.hasPawns {
+		flags |= hasPawnsFlag
+	}
+	data = append(data, flags)
+
+	parts := []*pairsData{}
+	for f := 0; f < 4; f++ {
+		if tbl.pairs[0][f] == nil {
+			break
+		}
+		data = append(data, 0)
+		for k := 0; k < tbl.pieceCount; k++ {
+			data = append(data, byte(tbl.pairs[0][f].pieces[k]|tbl.pairs[0][f].pieces[k]<<4))
+		}
+		for side := 0; side < 2; side++ {
+			if d := tbl.pairs[side][f]; d != nil {
+				parts = append(parts, d)
+			}
+		}
+	}
+	if len(data)%2 != 0 {
+		data = append(data, 0)
+	}
+
+	compressed := make([]compressedPart, len(parts))
+	for i, d := range parts {
+		compressed[i] = compress(values[d], d.flags)
+		data = append(data, compressed[i].sizes...)
+	}
+	for _, c := range compressed {
+		data = append(data, c.sparseIndex...)
+	}
+	for _, c := range compressed {
+		data = append(data, c.blockLength...)
+	}
+	for _, c := range compressed {
+		if len(c.blocks) > 0 {
+			for len(data)%64 != 0 {
+				data = append(data, 0)
+			}
+			data = append(data, c.blocks...)
+		}
+	}
+	return data
+}
+
+type compressedPart struct {
+	sizes, sparseIndex, blockLength, blocks []byte
+}
+
+const (
+	blockSizeBits = 6
+	spanBits      = 10
+	maxSymbols    = 1024
+)
+
+// compress packs values, where -1 is a value that does not matter, the way
+// pairsData.value reads them back: adjacent symbols are paired up into new
+// symbols while common pairs remain, and the symbols are then given
+// canonical Huffman codes and packed into blocks.
+func compress(values []int, flags int) compressedPart {
+	first := 0
+	for _, v := range values {
+		if v >= 0 {
+			first = v
+			break
+		}
+	}
+	filled, last, single := make([]int, len(values)), first, true
+	for i, v := range values {
+		if v >= 0 {
+			last, single = v, single && v == first
+		}
+		filled[i] = last
+	}
+	if single {
+		return compressedPart{sizes: []byte{byte(flags | singleValueFlag), byte(filled[0])}}
+	}
+
+	type symbol struct{ left, right, length int }
+	symbols := []symbol{}
+	leaves := map[int]int{}
+	stream := make([]int, len(filled))
+	for i, v := range filled {
+		sym, ok := leaves[v]
+		if !ok {
+			sym = len(symbols)
+			leaves[v] = sym
+			symbols = append(symbols, symbol{v, leaf, 0})
+		}
+		stream[i] = sym
+	}
+	for len(symbols) < maxSymbols {
+		n := len(symbols)
+		counts := make([]int, n*n)
+		for i := 0; i+1 < len(stream); i++ {
+			counts[stream[i]*n+stream[i+1]]++
+		}
+		best, bestCount := -1, 16
+		for pair, count := range counts {
+			if count > bestCount && symbols[pair/n].length+symbols[pair%n].length+2 <= 256 {
+				best, bestCount = pair, count
+			}
+		}
+		if best < 0 {
+			break
+		}
+		a, b := best/n, best%n
+		symbols = append(symbols, symbol{a, b, symbols[a].length + symbols[b].length + 1})
+		paired := stream[:0]
+		for i := 0; i < len(stream); i++ {
+			if i+1 < len(stream) && stream[i] == a && stream[i+1] == b {
+				paired = append(paired, n)
+				i++
+			} else {
+				paired = append(paired, stream[i])
+			}
+		}
+		stream = paired
+	}
+
+	// Huffman code lengths, with a second symbol if only one is used so the
+	// code is complete.
+	counts := make([]int, len(symbols))
+	for _, sym := range stream {
+		counts[sym]++
+	}
+	used := 0
+	for _, count := range counts {
+		if count > 0 {
+			used++
+		}
+	}
+	if used == 1 {
+		for sym := range counts {
+			if counts[sym] == 0 {
+				counts[sym] = 1
+				break
+			}
+		}
+	}
+	lengths := huffmanLengths(counts)
+
+	// Number the symbols from the longest codes to the shortest, and the
+	// ones without a code after them.
+	order := make([]int, len(symbols))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		li, lj := lengths[order[i]], lengths[order[j]]
+		if li == 0 || lj == 0 {
+			return li != 0 && lj == 0
+		}
+		return li > lj
+	})
+	id := make([]int, len(symbols))
+	for i, sym := range order {
+		id[sym] = i
+	}
+	minLen, maxLen := 64, 0
+	for _, l := range lengths {
+		if l > 0 && l < minLen {
+			minLen = l
+		}
+		if l > maxLen {
+			maxLen = l
+		}
+	}
+	if maxLen > 32 {
+		panic(fmt.Sprintf("code of %d bits", maxLen))
+	}
+	countOf := make([]int, maxLen+2)
+	for _, l := range lengths {
+		if l > 0 {
+			countOf[l]++
+		}
+	}
+	lowest := make([]int, maxLen+2)
+	base := make([]uint64, maxLen+2)
+	for l := maxLen - 1; l >= minLen; l-- {
+		lowest[l] = lowest[l+1] + countOf[l+1]
+		base[l] = (base[l+1] + uint64(countOf[l+1])) / 2
+	}
+	codes := make([]uint64, len(symbols))
+	for _, sym := range order {
+		if l := lengths[sym]; l > 0 {
+			codes[sym] = base[l] + uint64(id[sym]-lowest[l])
+		}
+	}
+
+	sizes := []byte{byte(flags), blockSizeBits, spanBits, 0, 0, 0, 0, 0, byte(maxLen), byte(minLen)}
+	for l := minLen; l <= maxLen; l++ {
+		sizes = binary.LittleEndian.AppendUint16(sizes, uint16(lowest[l]))
+	}
+	sizes = binary.LittleEndian.AppendUint16(sizes, uint16(len(symbols)))
+	for _, sym := range order {
+		left, right := symbols[sym].left, symbols[sym].right
+		if right != leaf {
+			left, right = id[left], id[right]
+		}
+		sizes = append(sizes, byte(left), byte(left>>8&0xf|right<<4), byte(right>>4))
+	}
+	if len(symbols)%2 != 0 {
+		sizes = append(sizes, 0)
+	}
+
+	// Pack the symbols into blocks, each holding whole symbols and no more
+	// than 65536 values.
+	blockBits := 8 << blockSizeBits
+	blocks := []byte{}
+	blockLengths := []int{}
+	block, bits, inBlock := make([]byte, 1<<blockSizeBits), 0, 0
+	flush := func() {
+		blocks = append(blocks, block...)
+		blockLengths = append(blockLengths, inBlock)
+		block, bits, inBlock = make([]byte, 1<<blockSizeBits), 0, 0
+	}
+	for _, sym := range stream {
+		l, n := lengths[sym], symbols[sym].length+1
+		if bits+l > blockBits || inBlock+n > 65536 {
+			flush()
+		}
+		for b := l - 1; b >= 0; b-- {
+			if codes[sym]>>uint(b)&1 != 0 {
+				block[bits/8] |= 0x80 >> uint(bits%8)
+			}
+			bits++
+		}
+		inBlock += n
+	}
+	flush()
+	binary.LittleEndian.PutUint32(sizes[4:], uint32(len(blockLengths)))
+
+	// The sparse index points at every span'th value, from span/2 on. Those
+	// past the end fall in padding blocks.
+	span := 1 << spanBits
+	entries := (len(values) + span - 1) / span
+	starts := []int{0}
+	for _, n := range blockLengths {
+		starts = append(starts, starts[len(starts)-1]+n)
+	}
+	sparseIndex := []byte{}
+	padding := 0
+	for k, b := 0, 0; k < entries; k++ {
+		at := k*span + span/2
+		for b < len(blockLengths) && at >= starts[b+1] {
+			b++
+		}
+		block, offset := b, at-starts[b]
+		if b == len(blockLengths) {
+			block, offset = b+offset/65536, offset%65536
+			if block-b+1 > padding {
+				padding = block - b + 1
+			}
+		}
+		sparseIndex = binary.LittleEndian.AppendUint32(sparseIndex, uint32(block))
+		sparseIndex = binary.LittleEndian.AppendUint16(sparseIndex, uint16(offset))
+	}
+	sizes[3] = byte(padding)
+	blockLength := []byte{}
+	for _, n := range blockLengths {
+		blockLength = binary.LittleEndian.AppendUint16(blockLength, uint16(n-1))
+	}
+	for i := 0; i < padding; i++ {
+		blockLength = binary.LittleEndian.AppendUint16(blockLength, 0xffff)
+	}
+	return compressedPart{sizes, sparseIndex, blockLength, blocks}
+}
+
+// huffmanLengths returns the length of the Huffman code of each symbol with
+// a count, and 0 for the others.
+func huffmanLengths(counts []int) []int {
+	type node struct{ count, left, right int }
+	nodes := []node{}
+	live := []int{}
+	for sym, count := range counts {
+		nodes = append(nodes, node{count, -1, -1})
+		if count > 0 {
+			live = append(live, sym)
+		}
+	}
+	for len(live) > 1 {
+		sort.Slice(live, func(i, j int) bool { return nodes[live[i]].count < nodes[live[j]].count })
+		nodes = append(nodes, node{nodes[live[0]].count + nodes[live[1]].count, live[0], live[1]})
+		live = append(live[2:], len(nodes)-1)
+	}
+	lengths := make([]int, len(counts))
+	var walk func(n int, depth int)
+	walk = func(n int, depth int) {
+		if n < len(counts) {
+			lengths[n] = depth
+			return
+		}
+		walk(nodes[n].left, depth+1)
+		walk(nodes[n].right, depth+1)
+	}
+	walk(live[0], 0)
+	return lengths
+}
@@ -0,0 +1,360 @@
+package uci
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattellis91/go-chess/chess"
+	"github.com/mattellis91/go-chess/engine"
+)
+
+var (
+	ErrEngineExited  = errors.New("engine exited")
+	ErrEngineTimeout = errors.New("engine did not reply in time")
+)
+
+// EngineError is returned by Client when the engine misbehaves. Err is
+// ErrEngineExited, ErrEngineTimeout or an error describing a bad reply.
+type EngineError struct {
+	Engine string
+	Err    error
+}
+
+func (e *EngineError) Error() string {
+	return fmt.Sprintf("uci: %s: %v", e.Engine, e.Err)
+}
+
+func (e *EngineError) Unwrap() error {
+	return e.Err
+}
+
+// Client runs another UCI engine as a subprocess. Its methods must not be
+// called from more than one goroutine at a time, except for Close.
+type Client struct {
+	Path string
+	Args []string
+	// Env is the engine's environment, as in exec.Cmd.
+	Env []string
+	// Timeout is how long to wait for a reply, on top of any time the engine
+	// was given to think.
+	Timeout time.Duration
+	// DepthTimeout is how long a search with a depth or node limit, but no
+	// time limit, may take before the engine is given up on.
+	DepthTimeout time.Duration
+
+	// Name and Author are what the engine reported during Start.
+	Name   string
+	Author string
+
+	cmd    *exec.Cmd
+	inMu   sync.Mutex
+	in     io.WriteCloser
+	lines  chan string
+	exited chan struct{}
+}
+
+func NewClient(path string, args ...string) *Client {
+	return &Client{Path: path, Args: args, Timeout: 10 * time.Second, DepthTimeout: 2 * time.Minute}
+}
+
+// Start launches the engine and waits for it to finish the uci handshake.
+func (c *Client) Start() error {
+	c.cmd = exec.Command(c.Path, c.Args...)
+	c.cmd.Env = c.Env
+	in, err := c.cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	out, err := c.cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := c.cmd.Start(); err != nil {
+		return err
+	}
+	c.in = in
+	c.lines = make(chan string, 100)
+	c.exited = make(chan struct{})
+
+	go func() {
+		scanner := bufio.NewScanner(out)
+		for scanner.Scan() {
+			c.lines <- scanner.Text()
+		}
+		close(c.lines)
+		c.cmd.Wait()
+		close(c.exited)
+	}()
+
+	c.send("uci")
+	deadline := time.After(c.Timeout)
+	for {
+		line, err := c.readLine(deadline)
+		if err != nil {
+			c.Close()
+			return err
+		}
+		switch {
+		case strings.HasPrefix(line, "id name "):
+			c.Name = strings.TrimPrefix(line, "id name ")
+		case strings.HasPrefix(line, "id author "):
+			c.Author = strings.TrimPrefix(line, "id author ")
+		case line == "uciok":
+			return nil
+		}
+	}
+}
+
+// Close asks the engine to quit, and kills it if it has not gone within a
+// second.
+func (c *Client) Close() error {
+	if c.cmd == nil || c.cmd.Process == nil {
+		return nil
+	}
+	c.send("quit")
+	kill := time.After(time.Second)
+	for {
+		// Keep reading so the engine is not left blocked writing output.
+		select {
+		case _, ok := <-c.lines:
+			if !ok {
+				<-c.exited
+				return nil
+			}
+		case <-kill:
+			c.cmd.Process.Kill()
+			kill = nil
+		}
+	}
+}
+
+func (c *Client) send(command string) error {
+	c.inMu.Lock()
+	defer c.inMu.Unlock()
+	_, err := io.WriteString(c.in, command+"\n")
+	return err
+}
+
+func (c *Client) fail(err error) error {
+	return &EngineError{Engine: c.Path, Err: err}
+}
+
+// readLine returns the next line from the engine, or an error if it exits or
+// deadline passes first.
+func (c *Client) readLine(deadline <-chan time.Time) (string, error) {
+	select {
+	case line, ok := <-c.lines:
+		if !ok {
+			return "", c.fail(ErrEngineExited)
+		}
+		return line, nil
+	case <-deadline:
+		return "", c.fail(ErrEngineTimeout)
+	}
+}
+
+// NewGame tells the engine the next position is from a different game.
+func (c *Client) NewGame() error {
+	c.send("ucinewgame")
+	return c.waitReady()
+}
+
+func (c *Client) waitReady() error {
+	c.send("isready")
+	deadline := time.After(c.Timeout)
+	for {
+		line, err := c.readLine(deadline)
+		if err != nil {
+			return err
+		}
+		if line == "readyok" {
+			return nil
+		}
+	}
+}
+
+// PositionCommand returns the position command for the game in gs: its
+// starting position followed by every move in MoveLog.
+func PositionCommand(gs *chess.GameState) string {
+	command := "position startpos"
+	if gs.StartFEN != chess.StartingPositionFEN {
+		command = "position fen " + gs.StartFEN
+	}
+	if len(gs.MoveLog) > 0 {
+		moves := make([]string, len(gs.MoveLog))
+		for i, move := range gs.MoveLog {
+			moves[i] = move.GetUCINotation()
+		}
+		command += " moves " + strings.Join(moves, " ")
+	}
+	return command
+}
+
+// GoCommand returns the go command for limits.
+func GoCommand(limits engine.Limits) string {
+	parts := []string{"go"}
+	ms := func(name string, d time.Duration) {
+		if d > 0 {
+			parts = append(parts, name, strconv.FormatInt(d.Milliseconds(), 10))
+		}
+	}
+	if limits.Infinite {
+		return "go infinite"
+	}
+	if limits.Depth > 0 {
+		parts = append(parts, "depth", strconv.Itoa(limits.Depth))
+	}
+	ms("movetime", limits.MoveTime)
+	ms("wtime", limits.WhiteTime)
+	ms("btime", limits.BlackTime)
+	ms("winc", limits.WhiteInc)
+	ms("binc", limits.BlackInc)
+	if limits.MovesToGo > 0 && len(parts) > 1 {
+		parts = append(parts, "movestogo", strconv.Itoa(limits.MovesToGo))
+	}
+	if len(parts) == 1 {
+		return "go infinite"
+	}
+	return strings.Join(parts, " ")
+}
+
+// Go asks the engine for its move in the position in gs and returns it. Info
+// lines are parsed into onInfo, if it is not nil, with their PV as moves.
+// Closing limits.Stop sends stop. An engine that exits, names an illegal move
+// or has not answered Timeout after its thinking time is up, or after stop,
+// gives an EngineError. A search without a time limit gets DepthTimeout
+// instead, unless it is infinite, when only stop ends it.
+func (c *Client) Go(gs *chess.GameState, limits engine.Limits, onInfo func(engine.Info)) (chess.Move, error) {
+	if err := c.send(PositionCommand(gs)); err != nil {
+		return chess.Move{}, c.fail(ErrEngineExited)
+	}
+	if err := c.waitReady(); err != nil {
+		return chess.Move{}, err
+	}
+	c.send(GoCommand(limits))
+
+	// Give up on the engine Timeout after it should have stopped. A search
+	// to a depth has no time it should stop by, so it gets DepthTimeout.
+	var deadline <-chan time.Time
+	var thinkTime time.Duration
+	switch {
+	case limits.Infinite:
+	case limits.MoveTime > 0:
+		thinkTime = limits.MoveTime
+	case gs.WhiteToMove:
+		thinkTime = limits.WhiteTime
+	default:
+		thinkTime = limits.BlackTime
+	}
+	switch {
+	case thinkTime > 0:
+		deadline = time.After(thinkTime + c.Timeout)
+	case !limits.Infinite && c.DepthTimeout > 0:
+		deadline = time.After(c.DepthTimeout)
+	}
+	stop := limits.Stop
+
+	for {
+		var line string
+		select {
+		case <-stop:
+			c.send("stop")
+			stop = nil
+			deadline = time.After(c.Timeout)
+			continue
+		case l, ok := <-c.lines:
+			if !ok {
+				return chess.Move{}, c.fail(ErrEngineExited)
+			}
+			line = l
+		case <-deadline:
+			return chess.Move{}, c.fail(ErrEngineTimeout)
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "info":
+			if onInfo != nil {
+				if info, ok := ParseInfo(gs, fields[1:]); ok {
+					onInfo(info)
+				}
+			}
+		case "bestmove":
+			if len(fields) < 2 || fields[1] == "0000" || fields[1] == "(none)" {
+				return chess.Move{}, c.fail(fmt.Errorf("no move in %q", line))
+			}
+			move, err := gs.Clone().ParseMove(fields[1])
+			if err != nil {
+				return chess.Move{}, c.fail(fmt.Errorf("illegal bestmove %q", fields[1]))
+			}
+			return move, nil
+		}
+	}
+}
+
+// ParseInfo reads the fields of an info line that follow "info". It reports
+// false for lines without a score, such as currmove updates. The PV is read
+// as moves from the position in gs and stops at the first move that is not
+// legal.
+func ParseInfo(gs *chess.GameState, fields []string) (engine.Info, bool) {
+	info := engine.Info{}
+	hasScore := false
+	for i := 0; i < len(fields); i++ {
+		next := func() int {
+			if i+1 >= len(fields) {
+				return 0
+			}
+			i++
+			n, _ := strconv.Atoi(fields[i])
+			return n
+		}
+
+		switch fields[i] {
+		case "depth":
+			info.Depth = next()
+		case "nodes":
+			info.Nodes = next()
+		case "time":
+			info.Time = time.Duration(next()) * time.Millisecond
+		case "score":
+			if i+1 < len(fields) {
+				i++
+				kind := fields[i]
+				n := next()
+				hasScore = true
+				switch {
+				case kind == "cp":
+					info.Score = n
+				case kind == "mate" && n > 0:
+					info.Score = engine.MateScore - (2*n - 1)
+				case kind == "mate":
+					info.Score = -engine.MateScore - 2*n
+				}
+			}
+		case "pv":
+			replay := gs.Clone()
+			for _, text := range fields[i+1:] {
+				move, err := replay.ParseMove(text)
+				if err != nil {
+					break
+				}
+				info.PV = append(info.PV, move)
+				replay.MakeMove(move)
+			}
+			i = len(fields)
+		case "string":
+			i = len(fields)
+		}
+	}
+	return info, hasScore
+}
@@ -0,0 +1,237 @@
+package uci
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattellis91/go-chess/chess"
+	"github.com/mattellis91/go-chess/engine"
+)
+
+// fakeEngineEnv makes the test binary act as a fake engine instead of running
+// the tests; see TestMain.
+const fakeEngineEnv = "GO_CHESS_FAKE_ENGINE"
+
+func TestMain(m *testing.M) {
+	if mode := os.Getenv(fakeEngineEnv); mode != "" {
+		fakeEngine(mode)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// fakeEngine is a minimal UCI engine. "good" runs the real engine; the other
+// modes misbehave after the handshake.
+func fakeEngine(mode string) {
+	if mode == "good" {
+		Run(os.Stdin, os.Stdout)
+		return
+	}
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		switch strings.Fields(scanner.Text() + " x")[0] {
+		case "uci":
+			if mode == "mute" {
+				continue
+			}
+			fmt.Println("id name Fake " + mode)
+			fmt.Println("uciok")
+		case "isready":
+			fmt.Println("readyok")
+		case "go":
+			switch mode {
+			case "crash":
+				os.Exit(3)
+			case "illegal":
+				fmt.Println("info depth 1 score cp 20 pv e2e5")
+				fmt.Println("bestmove e2e5")
+			case "slow":
+				time.Sleep(300 * time.Millisecond)
+				fmt.Println("bestmove e2e4")
+			case "nodepth":
+				if !strings.Contains(scanner.Text(), " depth ") {
+					fmt.Println("bestmove e2e4")
+				}
+			case "hang":
+			}
+		case "quit":
+			return
+		}
+	}
+}
+
+func startFake(t *testing.T, mode string) *Client {
+	t.Helper()
+	c := NewClient(os.Args[0])
+	c.Timeout = 2 * time.Second
+	c.Env = append(os.Environ(), fakeEngineEnv+"="+mode)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestClientPlaysAMove(t *testing.T) {
+	c := startFake(t, "good")
+	if c.Name != EngineName || c.Author != EngineAuthor {
+		t.Errorf("engine is %q by %q", c.Name, c.Author)
+	}
+	if err := c.NewGame(); err != nil {
+		t.Fatal(err)
+	}
+
+	gs := chess.NewGameState()
+	for _, text := range []string{"e4", "e5", "Nf3"} {
+		move, _ := gs.ParseMove(text)
+		gs.MakeMove(move)
+	}
+	infos := []engine.Info{}
+	move, err := c.Go(gs, engine.Limits{Depth: 2}, func(info engine.Info) {
+		infos = append(infos, info)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if move.PieceMoved[0] != 'b' {
+		t.Errorf("engine moved %s for black", move.GetUCINotation())
+	}
+	if len(infos) != 2 || infos[1].Depth != 2 || len(infos[1].PV) == 0 || infos[1].PV[0].MoveId != move.MoveId {
+		t.Errorf("infos = %+v", infos)
+	}
+}
+
+func TestClientStopsAnalysis(t *testing.T) {
+	c := startFake(t, "good")
+	stop := make(chan struct{})
+	time.AfterFunc(200*time.Millisecond, func() { close(stop) })
+	move, err := c.Go(chess.NewGameState(), engine.Limits{Infinite: true, Stop: stop}, nil)
+	if err != nil || move.PieceMoved == "" {
+		t.Fatalf("got %v, %v", move, err)
+	}
+
+	// The engine is still usable afterwards.
+	if _, err := c.Go(chess.NewGameState(), engine.Limits{Depth: 1}, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	tests := []struct {
+		mode string
+		err  error
+	}{
+		{"crash", ErrEngineExited},
+		{"hang", ErrEngineTimeout},
+		{"illegal", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.mode, func(t *testing.T) {
+			c := startFake(t, test.mode)
+			c.Timeout = 200 * time.Millisecond
+			_, err := c.Go(chess.NewGameState(), engine.Limits{MoveTime: 100 * time.Millisecond}, nil)
+			var engineErr *EngineError
+			if !errors.As(err, &engineErr) {
+				t.Fatalf("got %v, want an EngineError", err)
+			}
+			if test.err != nil && !errors.Is(err, test.err) {
+				t.Errorf("got %v, want %v", err, test.err)
+			}
+		})
+	}
+}
+
+// TestClientWaitsForDepth checks that a search to a depth may take longer
+// than Timeout, but not longer than DepthTimeout.
+func TestClientWaitsForDepth(t *testing.T) {
+	c := startFake(t, "slow")
+	c.Timeout, c.DepthTimeout = 100*time.Millisecond, 2*time.Second
+	move, err := c.Go(chess.NewGameState(), engine.Limits{Depth: 20}, nil)
+	if err != nil || move.GetUCINotation() != "e2e4" {
+		t.Errorf("got %v, %v, want e2e4", move.GetUCINotation(), err)
+	}
+
+	c = startFake(t, "nodepth")
+	c.Timeout, c.DepthTimeout = 100*time.Millisecond, 300*time.Millisecond
+	if _, err := c.Go(chess.NewGameState(), engine.Limits{MoveTime: 50 * time.Millisecond}, nil); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, err := c.Go(chess.NewGameState(), engine.Limits{Depth: 4}, nil); !errors.Is(err, ErrEngineTimeout) {
+		t.Errorf("got %v, want %v", err, ErrEngineTimeout)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("gave up after %v", elapsed)
+	}
+}
+
+func TestClientHandshakeTimeout(t *testing.T) {
+	c := NewClient(os.Args[0])
+	c.Timeout = 200 * time.Millisecond
+	c.Env = append(os.Environ(), fakeEngineEnv+"=mute")
+	if err := c.Start(); !errors.Is(err, ErrEngineTimeout) {
+		t.Errorf("got %v, want %v", err, ErrEngineTimeout)
+	}
+}
+
+func TestPositionCommand(t *testing.T) {
+	gs := chess.NewGameState()
+	if got := PositionCommand(gs); got != "position startpos" {
+		t.Errorf("got %q", got)
+	}
+	for _, text := range []string{"e4", "e5", "Nf3"} {
+		move, _ := gs.ParseMove(text)
+		gs.MakeMove(move)
+	}
+	if got := PositionCommand(gs); got != "position startpos moves e2e4 e7e5 g1f3" {
+		t.Errorf("got %q", got)
+	}
+
+	fen := "4k3/P7/8/8/8/8/8/4K3 w - - 0 1"
+	gs, _ = chess.NewGameStateFromFEN(fen)
+	move, _ := gs.ParseMove("a8=N")
+	gs.MakeMove(move)
+	if got := PositionCommand(gs); got != "position fen "+fen+" moves a7a8n" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestGoCommand(t *testing.T) {
+	tests := []struct {
+		limits engine.Limits
+		want   string
+	}{
+		{engine.Limits{}, "go infinite"},
+		{engine.Limits{Depth: 6}, "go depth 6"},
+		{engine.Limits{MoveTime: time.Second}, "go movetime 1000"},
+		{engine.Limits{WhiteTime: time.Minute, BlackTime: 50 * time.Second, WhiteInc: time.Second, BlackInc: time.Second, MovesToGo: 12},
+			"go wtime 60000 btime 50000 winc 1000 binc 1000 movestogo 12"},
+	}
+	for _, test := range tests {
+		if got := GoCommand(test.limits); got != test.want {
+			t.Errorf("GoCommand(%+v) = %q, want %q", test.limits, got, test.want)
+		}
+	}
+}
+
+func TestParseInfo(t *testing.T) {
+	gs := chess.NewGameState()
+	info, ok := ParseInfo(gs, strings.Fields("depth 7 seldepth 9 score mate -3 nodes 1234 nps 5 time 250 pv e2e4 e7e5 e1e8 g1f3"))
+	if !ok || info.Depth != 7 || info.Nodes != 1234 || info.Time != 250*time.Millisecond {
+		t.Errorf("got %+v", info)
+	}
+	if info.Score != -engine.MateScore+6 {
+		t.Errorf("score = %d, want %d", info.Score, -engine.MateScore+6)
+	}
+	if len(info.PV) != 2 {
+		t.Errorf("PV has %d moves, want the 2 legal ones", len(info.PV))
+	}
+	if _, ok := ParseInfo(gs, strings.Fields("currmove e2e4 currmovenumber 1")); ok {
+		t.Error("info without a score was accepted")
+	}
+}
@@ -0,0 +1,448 @@
+// Package uci speaks the Universal Chess Interface, so the engine package can
+// play under chess GUIs that support it.
+package uci
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattellis91/go-chess/book"
+	"github.com/mattellis91/go-chess/chess"
+	"github.com/mattellis91/go-chess/engine"
+	"github.com/mattellis91/go-chess/syzygy"
+)
+
+const (
+	EngineName   = "go-chess"
+	EngineAuthor = "mattellis91"
+)
+
+// session is the state of one conversation with a GUI.
+type session struct {
+	engine *engine.Engine
+	gs     *chess.GameState
+
+	outMu sync.Mutex
+	out   io.Writer
+
+	// stop is closed to end the current search and done is closed once it
+	// has printed its bestmove. Both are nil when no search is running.
+	stop chan struct{}
+	done chan struct{}
+
+	moveOverhead time.Duration
+
+	// book is the book opened with the Book File option. The engine only
+	// plays from it while ownBook is set.
+	book    *book.Book
+	ownBook bool
+}
+
+// option is a setting announced in reply to uci and changed with setoption.
+type option struct {
+	name string
+	// kind is the UCI option type: check, spin, combo, button or string.
+	kind     string
+	def      string
+	min, max int
+	// vars are the values a combo option may take.
+	vars []string
+	set  func(s *session, value string)
+}
+
+// switchOption makes a check option for one of the engine's search options.
+func switchOption(name string, field func(*engine.Options) *bool) option {
+	return option{
+		name: name, kind: "check", def: "true",
+		set: func(s *session, value string) {
+			*field(&s.engine.Options) = value == "true"
+		},
+	}
+}
+
+var options = []option{
+	{
+		name: "Hash", kind: "spin", def: strconv.Itoa(engine.DefaultHashMB), min: 1, max: 1024,
+		set: func(s *session, value string) {
+			mb, _ := strconv.Atoi(value)
+			s.engine.TT.Resize(mb)
+		},
+	},
+	{
+		name: "Threads", kind: "spin", def: "1", min: 1, max: 256,
+		set: func(s *session, value string) {
+			s.engine.Threads, _ = strconv.Atoi(value)
+		},
+	},
+	{
+		name: "Move Overhead", kind: "spin", def: "10", min: 0, max: 5000,
+		set: func(s *session, value string) {
+			ms, _ := strconv.Atoi(value)
+			s.moveOverhead = time.Duration(ms) * time.Millisecond
+		},
+	},
+	{
+		name: "OwnBook", kind: "check", def: "false",
+		set: func(s *session, value string) {
+			s.ownBook = value == "true"
+			s.useBook()
+		},
+	},
+	{
+		name: "Book File", kind: "string", def: "",
+		set: func(s *session, value string) {
+			if s.book != nil {
+				s.book.Close()
+				s.book = nil
+			}
+			if value != "" && value != "<empty>" {
+				b, err := book.Open(value)
+				if err != nil {
+					s.println("info string " + err.Error())
+				}
+				s.book = b
+			}
+			s.useBook()
+		},
+	},
+	{
+		// Book Plies of 0 plays from the book for as long as it has moves.
+		name: "Book Plies", kind: "spin", def: strconv.Itoa(engine.DefaultBookPlies), min: 0, max: 1000,
+		set: func(s *session, value string) {
+			s.engine.BookPlies, _ = strconv.Atoi(value)
+		},
+	},
+	{
+		name: "Book Selection", kind: "combo", def: "weighted", vars: []string{"weighted", "best"},
+		set: func(s *session, value string) {
+			s.engine.BookSelection = book.Weighted
+			if value == "best" {
+				s.engine.BookSelection = book.BestOnly
+			}
+		},
+	},
+	{
+		name: "SyzygyPath", kind: "string", def: "",
+		set: func(s *session, value string) {
+			s.engine.Tablebase = nil
+			if value == "" || value == "<empty>" {
+				return
+			}
+			tables, err := syzygy.Open(value)
+			if err != nil {
+				s.println("info string " + err.Error())
+				return
+			}
+			wdl, dtz := tables.Count()
+			s.println(fmt.Sprintf("info string found %d WDL and %d DTZ tables of up to %d pieces", wdl, dtz, tables.MaxPieces()))
+			s.engine.Tablebase = tables
+		},
+	},
+	switchOption("PVS", func(o *engine.Options) *bool { return &o.PVS }),
+	switchOption("AspirationWindows", func(o *engine.Options) *bool { return &o.AspirationWindows }),
+	switchOption("NullMove", func(o *engine.Options) *bool { return &o.NullMove }),
+	switchOption("LateMoveReductions", func(o *engine.Options) *bool { return &o.LateMoveReductions }),
+	switchOption("CheckExtensions", func(o *engine.Options) *bool { return &o.CheckExtensions }),
+	switchOption("KillerMoves", func(o *engine.Options) *bool { return &o.KillerMoves }),
+	switchOption("HistoryHeuristic", func(o *engine.Options) *bool { return &o.HistoryHeuristic }),
+}
+
+// Run reads UCI commands from in and writes the engine's replies to out until
+// it reads quit or in runs out.
+func Run(in io.Reader, out io.Writer) error {
+	s := &session{
+		engine: engine.NewEngine(),
+		gs:     chess.NewGameState(),
+		out:    out,
+	}
+	s.engine.OnInfo = s.printInfo
+	for _, o := range options {
+		if o.kind != "button" {
+			o.set(s, o.def)
+		}
+	}
+
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "uci":
+			s.println("id name " + EngineName)
+			s.println("id author " + EngineAuthor)
+			for _, o := range options {
+				s.println(o.String())
+			}
+			s.println("uciok")
+		case "isready":
+			s.println("readyok")
+		case "debug", "ponderhit", "register":
+		case "setoption":
+			s.setOption(fields[1:])
+		case "ucinewgame":
+			s.stopSearch()
+			s.gs = chess.NewGameState()
+			s.engine.TT.Clear()
+		case "position":
+			s.stopSearch()
+			if err := s.setPosition(fields[1:]); err != nil {
+				s.println("info string " + err.Error())
+			}
+		case "go":
+			s.stopSearch()
+			s.startSearch(fields[1:])
+		case "stop":
+			s.stopSearch()
+		case "quit":
+			s.stopSearch()
+			return nil
+		default:
+			s.println("info string unknown command " + fields[0])
+		}
+	}
+	s.stopSearch()
+	return scanner.Err()
+}
+
+func (s *session) println(line string) {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	io.WriteString(s.out, line+"\n")
+}
+
+func (o option) String() string {
+	line := "option name " + o.name + " type " + o.kind
+	switch o.kind {
+	case "button":
+	case "spin":
+		line += fmt.Sprintf(" default %s min %d max %d", o.def, o.min, o.max)
+	case "combo":
+		line += " default " + o.def
+		for _, v := range o.vars {
+			line += " var " + v
+		}
+	case "string":
+		if o.def == "" {
+			line += " default <empty>"
+		} else {
+			line += " default " + o.def
+		}
+	default:
+		line += " default " + o.def
+	}
+	return line
+}
+
+// setOption handles "setoption name <id> [value <x>]". Option names may
+// contain spaces and are not case sensitive.
+func (s *session) setOption(args []string) {
+	if len(args) < 2 || args[0] != "name" {
+		s.println("info string malformed setoption")
+		return
+	}
+	name, value := strings.Join(args[1:], " "), ""
+	for i, arg := range args {
+		if arg == "value" {
+			name, value = strings.Join(args[1:i], " "), strings.Join(args[i+1:], " ")
+			break
+		}
+	}
+
+	for _, o := range options {
+		if !strings.EqualFold(o.name, name) {
+			continue
+		}
+		switch o.kind {
+		case "spin":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < o.min || n > o.max {
+				s.println(fmt.Sprintf("info string %s must be a number from %d to %d", o.name, o.min, o.max))
+				return
+			}
+		case "check":
+			if value != "true" && value != "false" {
+				s.println("info string " + o.name + " must be true or false")
+				return
+			}
+		case "combo":
+			valid := false
+			for _, v := range o.vars {
+				if strings.EqualFold(v, value) {
+					value, valid = v, true
+				}
+			}
+			if !valid {
+				s.println("info string " + o.name + " must be one of " + strings.Join(o.vars, ", "))
+				return
+			}
+		}
+		s.stopSearch()
+		o.set(s, value)
+		return
+	}
+	s.println("info string unknown option " + name)
+}
+
+// useBook gives the engine the book if OwnBook is on.
+func (s *session) useBook() {
+	s.engine.Book = nil
+	if s.ownBook {
+		s.engine.Book = s.book
+	}
+}
+
+// setPosition handles "position startpos|fen <fen> [moves <move>...]". The
+// position is left unchanged if any part of the command is invalid.
+func (s *session) setPosition(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing position")
+	}
+	moves := len(args)
+	for i, arg := range args {
+		if arg == "moves" {
+			moves = i
+			break
+		}
+	}
+
+	var gs *chess.GameState
+	switch args[0] {
+	case "startpos":
+		gs = chess.NewGameState()
+	case "fen":
+		var err error
+		gs, err = chess.NewGameStateFromFEN(strings.Join(args[1:moves], " "))
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown position %q", args[0])
+	}
+
+	if moves < len(args) {
+		for _, text := range args[moves+1:] {
+			move, err := gs.ParseMove(text)
+			if err != nil {
+				return err
+			}
+			gs.MakeMove(move)
+		}
+	}
+	s.gs = gs
+	return nil
+}
+
+// startSearch handles "go" and searches on another goroutine, leaving Run
+// free to read stop, isready and quit.
+func (s *session) startSearch(args []string) {
+	limits := engine.Limits{}
+	for i := 0; i < len(args); i++ {
+		value := func() int {
+			if i+1 >= len(args) {
+				return 0
+			}
+			i++
+			n, _ := strconv.Atoi(args[i])
+			return n
+		}
+		ms := func() time.Duration {
+			return time.Duration(value()) * time.Millisecond
+		}
+
+		switch args[i] {
+		case "depth":
+			limits.Depth = value()
+		case "movetime":
+			limits.MoveTime = s.afterOverhead(ms())
+		case "wtime":
+			limits.WhiteTime = s.afterOverhead(ms())
+		case "btime":
+			limits.BlackTime = s.afterOverhead(ms())
+		case "winc":
+			limits.WhiteInc = ms()
+		case "binc":
+			limits.BlackInc = ms()
+		case "movestogo":
+			limits.MovesToGo = value()
+		case "nodes", "mate":
+			value()
+		case "infinite":
+			limits.Infinite = true
+		}
+	}
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	s.stop, s.done = stop, done
+	limits.Stop = stop
+	gs := s.gs.Clone()
+
+	go func() {
+		defer close(done)
+		result := s.engine.Search(gs, limits)
+		if limits.Infinite {
+			// The GUI must say stop before an infinite search may answer.
+			<-stop
+		}
+		if result.FromBook {
+			s.println("info string book move " + result.Move.GetUCINotation())
+		}
+		if result.Move.PieceMoved == "" {
+			s.println("bestmove 0000")
+		} else {
+			s.println("bestmove " + result.Move.GetUCINotation())
+		}
+	}()
+}
+
+// afterOverhead takes the Move Overhead allowance off a time limit, keeping
+// it positive so it still counts as a limit.
+func (s *session) afterOverhead(d time.Duration) time.Duration {
+	if d -= s.moveOverhead; d < time.Millisecond {
+		return time.Millisecond
+	}
+	return d
+}
+
+// stopSearch ends the running search, if any, and waits for its bestmove.
+func (s *session) stopSearch() {
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+	s.stop, s.done = nil, nil
+}
+
+func (s *session) printInfo(info engine.Info) {
+	nps := 0
+	if info.Time > 0 {
+		nps = int(float64(info.Nodes) / info.Time.Seconds())
+	}
+	pv := make([]string, len(info.PV))
+	for i, move := range info.PV {
+		pv[i] = move.GetUCINotation()
+	}
+	s.println(fmt.Sprintf("info depth %d score %s nodes %d nps %d hashfull %d tbhits %d time %d pv %s",
+		info.Depth, formatScore(info.Score), info.Nodes, nps, info.Hashfull, info.TBHits, info.Time.Milliseconds(), strings.Join(pv, " ")))
+}
+
+// formatScore writes a score as centipawns, or as moves to mate when the
+// search has found one.
+func formatScore(score int) string {
+	switch {
+	case score >= engine.MateScore-engine.MaxDepth:
+		return fmt.Sprintf("mate %d", (engine.MateScore-score+1)/2)
+	case score <= -engine.MateScore+engine.MaxDepth:
+		return fmt.Sprintf("mate %d", -(engine.MateScore+score)/2)
+	default:
+		return fmt.Sprintf("cp %d", engine.ReportedCentipawns(score))
+	}
+}
@@ -0,0 +1,281 @@
+package uci
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattellis91/go-chess/book"
+	"github.com/mattellis91/go-chess/chess"
+	"github.com/mattellis91/go-chess/engine"
+)
+
+// harness runs a UCI session on pipes so a test can write commands and wait
+// for replies the way a GUI would.
+type harness struct {
+	t     *testing.T
+	in    *io.PipeWriter
+	lines chan string
+	done  chan error
+}
+
+func newHarness(t *testing.T) *harness {
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	h := &harness{t: t, in: inWriter, lines: make(chan string, 1000), done: make(chan error, 1)}
+
+	go func() {
+		h.done <- Run(inReader, outWriter)
+		outWriter.Close()
+	}()
+	go func() {
+		scanner := bufio.NewScanner(outReader)
+		for scanner.Scan() {
+			h.lines <- scanner.Text()
+		}
+		close(h.lines)
+	}()
+	t.Cleanup(func() {
+		inWriter.Close()
+	})
+	return h
+}
+
+func (h *harness) send(command string) {
+	h.t.Helper()
+	if _, err := io.WriteString(h.in, command+"\n"); err != nil {
+		h.t.Fatalf("sending %q: %v", command, err)
+	}
+}
+
+// next returns the next line the engine writes.
+func (h *harness) next() string {
+	h.t.Helper()
+	select {
+	case line, ok := <-h.lines:
+		if !ok {
+			h.t.Fatal("engine closed its output")
+		}
+		return line
+	case <-time.After(10 * time.Second):
+		h.t.Fatal("timed out waiting for the engine")
+	}
+	return ""
+}
+
+// run plays a script. Lines starting with "> " are sent to the engine, lines
+// starting with "< " must be the start of the next line it writes and lines
+// starting with "~ " skip output until a line that starts that way. It
+// returns the last line read.
+func (h *harness) run(script ...string) string {
+	h.t.Helper()
+	last := ""
+	for _, step := range script {
+		text := step[2:]
+		switch step[:2] {
+		case "> ":
+			h.send(text)
+		case "< ":
+			if last = h.next(); !strings.HasPrefix(last, text) {
+				h.t.Fatalf("engine wrote %q, want %q", last, text)
+			}
+		case "~ ":
+			for last = h.next(); !strings.HasPrefix(last, text); last = h.next() {
+			}
+		default:
+			h.t.Fatalf("bad script step %q", step)
+		}
+	}
+	return last
+}
+
+func TestHandshake(t *testing.T) {
+	h := newHarness(t)
+	h.run(
+		"> uci",
+		"< id name "+EngineName,
+		"< id author "+EngineAuthor,
+		"< option name Hash type spin default 16 min 1 max 1024",
+		"< option name Threads type spin default 1 min 1 max 256",
+		"< option name Move Overhead type spin default 10 min 0 max 5000",
+		"< option name OwnBook type check default false",
+		"< option name Book File type string default <empty>",
+		"< option name Book Plies type spin default 20 min 0 max 1000",
+		"< option name Book Selection type combo default weighted var weighted var best",
+		"< option name SyzygyPath type string default <empty>",
+		"< option name PVS type check default true",
+		"~ option name HistoryHeuristic type check default true",
+		"< uciok",
+		"> isready",
+		"< readyok",
+		"> setoption name move overhead value 50",
+		"> setoption name Hash value 1",
+		"> setoption name Threads value 2",
+		"> setoption name Hash value 0",
+		"< info string Hash must be a number from 1 to 1024",
+		"> setoption name Move Overhead value lots",
+		"< info string Move Overhead must be a number from 0 to 5000",
+		"> setoption name NullMove value false",
+		"> setoption name NullMove value maybe",
+		"< info string NullMove must be true or false",
+		"> setoption name Book Selection value Best",
+		"> setoption name Book Selection value worst",
+		"< info string Book Selection must be one of weighted, best",
+		"> setoption name SyzygyPath value /no/such/dir",
+		"< info string open /no/such/dir",
+		"> setoption name Contempt value 10",
+		"< info string unknown option Contempt",
+		"> frobnicate",
+		"< info string unknown command frobnicate",
+		"> isready",
+		"< readyok",
+		"> quit",
+	)
+	if err := <-h.done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOwnBook(t *testing.T) {
+	gs := chess.NewGameState()
+	move, _ := gs.Clone().ParseMove("a3")
+	var entry [16]byte
+	binary.BigEndian.PutUint64(entry[:8], gs.Hash)
+	binary.BigEndian.PutUint16(entry[8:10], book.EncodeMove(move))
+	binary.BigEndian.PutUint16(entry[10:12], 1)
+	path := filepath.Join(t.TempDir(), "book.bin")
+	if err := os.WriteFile(path, entry[:], 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := newHarness(t)
+	h.run(
+		"> setoption name Book File value "+path,
+		"> position startpos",
+		"> go depth 1",
+		"~ bestmove",
+		"> setoption name OwnBook value true",
+		"> go depth 1",
+		"< info string book move a2a3",
+		"< bestmove a2a3",
+		"> setoption name Book Plies value 0",
+		"> go depth 1",
+		"< info string book move a2a3",
+		"< bestmove a2a3",
+		"> setoption name Book File value "+path+".missing",
+		"< info string open "+path+".missing",
+		"> go depth 1",
+		"< info depth 1",
+	)
+}
+
+func TestGoDepth(t *testing.T) {
+	h := newHarness(t)
+	h.run(
+		"> ucinewgame",
+		"> position startpos moves e2e4 e7e5 g1f3",
+		"> go depth 3",
+		"< info depth 1 score cp ",
+		"< info depth 2 score cp ",
+		"< info depth 3 score cp ",
+	)
+	bestmove := h.run("< bestmove ")
+
+	gs := chess.NewGameState()
+	for _, text := range []string{"e2e4", "e7e5", "g1f3"} {
+		move, _ := gs.ParseMove(text)
+		gs.MakeMove(move)
+	}
+	if _, err := gs.ParseMove(strings.Fields(bestmove)[1]); err != nil {
+		t.Errorf("%q is not legal: %v", bestmove, err)
+	}
+}
+
+func TestInfoLine(t *testing.T) {
+	h := newHarness(t)
+	h.send("position fen 6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1")
+	h.send("go depth 2")
+	line := h.run("~ info depth 2")
+	for _, want := range []string{" score mate 1 ", " nodes ", " nps ", " hashfull ", " tbhits ", " time ", " pv a1a8"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("%q does not contain %q", line, want)
+		}
+	}
+	h.run("< bestmove a1a8")
+}
+
+func TestStopInfiniteSearch(t *testing.T) {
+	h := newHarness(t)
+	h.run(
+		"> setoption name Threads value 3",
+		"> position startpos",
+		"> go infinite",
+		"~ info depth 2",
+		"> isready",
+		"~ readyok",
+	)
+	h.send("stop")
+	h.run("~ bestmove ")
+}
+
+func TestGoWithClock(t *testing.T) {
+	h := newHarness(t)
+	start := time.Now()
+	h.run(
+		"> position startpos moves d2d4",
+		"> go wtime 1000 btime 1000 winc 0 binc 0",
+		"~ bestmove ",
+	)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("used %v with one second on the clock", elapsed)
+	}
+	h.run(
+		"> go movetime 200",
+		"~ bestmove ",
+	)
+}
+
+func TestMatedPosition(t *testing.T) {
+	h := newHarness(t)
+	h.run(
+		"> position startpos moves f2f3 e7e5 g2g4 d8h4",
+		"> go depth 2",
+		"< bestmove 0000",
+	)
+}
+
+func TestBadPosition(t *testing.T) {
+	h := newHarness(t)
+	h.run(
+		"> position startpos moves e2e5",
+		"< info string chess: illegal move \"e2e5\"",
+		"> position fen 8/8/8 w - - 0 1",
+		"~ info string ",
+		"> go depth 1",
+		"< info depth 1",
+		"< bestmove ",
+	)
+}
+
+func TestFormatScore(t *testing.T) {
+	tests := map[int]string{
+		0:                             "cp 0",
+		-35:                           "cp -35",
+		engine.MateScore - 1:          "mate 1",
+		engine.MateScore - 4:          "mate 2",
+		-engine.MateScore + 2:         "mate -1",
+		engine.TablebaseWinScore - 3:  "cp 19997",
+		-engine.TablebaseWinScore + 3: "cp -19997",
+		engine.TablebaseWinScore - engine.MaxDepth: "cp 19936",
+	}
+	for score, want := range tests {
+		if got := formatScore(score); got != want {
+			t.Errorf("formatScore(%d) = %q, want %q", score, got, want)
+		}
+	}
+}
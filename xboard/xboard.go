@@ -0,0 +1,416 @@
+// Package xboard speaks the Chess Engine Communication Protocol used by
+// XBoard, WinBoard and tournament managers that predate UCI.
+package xboard
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattellis91/go-chess/chess"
+	"github.com/mattellis91/go-chess/engine"
+	"github.com/mattellis91/go-chess/syzygy"
+)
+
+const EngineName = "go-chess"
+
+// features are sent in reply to protover 2, followed by an option feature for
+// each of searchOptions and then done=1 so the GUI knows there are no more.
+var features = []string{
+	`myname="` + EngineName + `"`,
+	"setboard=1", "usermove=1", "ping=1", "playother=1", "time=1", "colors=0", "memory=1", "smp=1", `egt="syzygy"`,
+	"sigint=0", "sigterm=0", "reuse=1", "analyze=0", "draw=0", "san=0",
+}
+
+// searchOptions are offered to the GUI as check boxes and set with the option
+// command.
+var searchOptions = []struct {
+	name  string
+	field func(*engine.Options) *bool
+}{
+	{"PVS", func(o *engine.Options) *bool { return &o.PVS }},
+	{"AspirationWindows", func(o *engine.Options) *bool { return &o.AspirationWindows }},
+	{"NullMove", func(o *engine.Options) *bool { return &o.NullMove }},
+	{"LateMoveReductions", func(o *engine.Options) *bool { return &o.LateMoveReductions }},
+	{"CheckExtensions", func(o *engine.Options) *bool { return &o.CheckExtensions }},
+	{"KillerMoves", func(o *engine.Options) *bool { return &o.KillerMoves }},
+	{"HistoryHeuristic", func(o *engine.Options) *bool { return &o.HistoryHeuristic }},
+}
+
+// session is the state of one conversation with a GUI. Only the goroutine in
+// Run touches it; searches report back on results.
+type session struct {
+	engine *engine.Engine
+	gs     *chess.GameState
+
+	outMu sync.Mutex
+	out   io.Writer
+
+	// engineWhite and engineBlack say which sides the engine plays. Both are
+	// false in force mode.
+	engineWhite bool
+	engineBlack bool
+	post        bool
+
+	depth    int
+	moveTime time.Duration
+	// movesPerSession is the number of moves in each time control, or 0 if
+	// base is for the whole game.
+	movesPerSession int
+	base            time.Duration
+	increment       time.Duration
+	engineClock     time.Duration
+	otherClock      time.Duration
+
+	// searching is set while a search runs. Closing stop makes it move now;
+	// stop is nil once it has been closed.
+	searching    bool
+	stop         chan struct{}
+	results      chan engine.SearchResult
+	pendingPings []string
+}
+
+// Run reads CECP commands from in and writes the engine's replies to out until
+// it reads quit or in runs out.
+func Run(in io.Reader, out io.Writer) error {
+	s := &session{
+		engine:  engine.NewEngine(),
+		gs:      chess.NewGameState(),
+		out:     out,
+		results: make(chan engine.SearchResult, 1),
+	}
+	s.newGame()
+
+	// done lets the reading goroutine finish once Run returns, rather than
+	// wait forever to hand over the line after quit.
+	lines := make(chan string)
+	done := make(chan struct{})
+	defer close(done)
+	scanner := bufio.NewScanner(in)
+	go func() {
+		defer close(lines)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				s.cancelSearch()
+				return scanner.Err()
+			}
+			if !s.handle(line) {
+				s.cancelSearch()
+				return nil
+			}
+		case result := <-s.results:
+			s.finishSearch(result)
+		}
+	}
+}
+
+func (s *session) println(line string) {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	io.WriteString(s.out, line+"\n")
+}
+
+// handle runs one command and reports false when it was quit.
+func (s *session) handle(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return true
+	}
+	args := fields[1:]
+
+	switch fields[0] {
+	case "quit":
+		return false
+	case "xboard", "accepted", "rejected", "random", "hard", "easy", "computer", "name", "rating", "ics", "draw", "hint", "bk":
+	case "protover":
+		offered := append([]string(nil), features...)
+		for _, o := range searchOptions {
+			offered = append(offered, fmt.Sprintf(`option="%s -check 1"`, o.name))
+		}
+		s.println("feature " + strings.Join(append(offered, "done=1"), " "))
+	case "option":
+		s.setOption(strings.Join(args, " "))
+	case "ping":
+		if s.searching {
+			// Answer after the move, so the GUI knows the move came first.
+			s.pendingPings = append(s.pendingPings, strings.Join(args, " "))
+		} else {
+			s.println("pong " + strings.Join(args, " "))
+		}
+	case "new":
+		s.cancelSearch()
+		s.newGame()
+	case "force":
+		s.cancelSearch()
+		s.engineWhite, s.engineBlack = false, false
+	case "go":
+		s.cancelSearch()
+		s.engineWhite, s.engineBlack = s.gs.WhiteToMove, !s.gs.WhiteToMove
+		s.think()
+	case "playother":
+		s.cancelSearch()
+		s.engineWhite, s.engineBlack = !s.gs.WhiteToMove, s.gs.WhiteToMove
+	case "white", "black":
+		// Protocol version 1 colour commands; colors=0 turns them off.
+	case "?":
+		s.moveNow()
+	case "usermove":
+		if len(args) == 1 {
+			s.userMove(args[0])
+		}
+	case "setboard":
+		s.cancelSearch()
+		gs, err := chess.NewGameStateFromFEN(strings.Join(args, " "))
+		if err != nil {
+			s.println("tellusererror Illegal position")
+			return true
+		}
+		s.gs = gs
+	case "undo":
+		s.cancelSearch()
+		s.gs.UndoMove()
+	case "remove":
+		s.cancelSearch()
+		s.gs.UndoMove()
+		s.gs.UndoMove()
+	case "result":
+		s.cancelSearch()
+		s.engineWhite, s.engineBlack = false, false
+	case "level":
+		s.level(args)
+	case "st":
+		if len(args) == 1 {
+			seconds, _ := strconv.ParseFloat(args[0], 64)
+			s.moveTime = time.Duration(seconds * float64(time.Second))
+		}
+	case "sd":
+		if len(args) == 1 {
+			s.depth, _ = strconv.Atoi(args[0])
+		}
+	case "time", "otim":
+		if len(args) == 1 {
+			centiseconds, _ := strconv.Atoi(args[0])
+			clock := time.Duration(centiseconds) * 10 * time.Millisecond
+			if fields[0] == "time" {
+				s.engineClock = clock
+			} else {
+				s.otherClock = clock
+			}
+		}
+	case "memory":
+		if len(args) == 1 {
+			s.cancelSearch()
+			mb, _ := strconv.Atoi(args[0])
+			s.engine.TT.Resize(mb)
+		}
+	case "cores":
+		if len(args) == 1 {
+			s.cancelSearch()
+			s.engine.Threads, _ = strconv.Atoi(args[0])
+		}
+	case "egtpath":
+		if len(args) >= 2 && args[0] == "syzygy" {
+			s.cancelSearch()
+			tables, err := syzygy.Open(strings.Join(args[1:], " "))
+			if err != nil {
+				s.println("Error (" + err.Error() + "): " + strings.Join(fields, " "))
+				break
+			}
+			s.engine.Tablebase = tables
+		}
+	case "post":
+		s.post = true
+	case "nopost":
+		s.post = false
+	default:
+		// Without usermove=1 a GUI would send bare moves.
+		if _, err := s.gs.ParseMove(fields[0]); err == nil && len(fields) == 1 {
+			s.userMove(fields[0])
+		} else {
+			s.println("Error (unknown command): " + fields[0])
+		}
+	}
+	return true
+}
+
+// setOption handles "option NAME=VALUE" for one of searchOptions.
+func (s *session) setOption(setting string) {
+	name, value, _ := strings.Cut(setting, "=")
+	for _, o := range searchOptions {
+		if o.name == name {
+			s.cancelSearch()
+			*o.field(&s.engine.Options) = value == "1"
+			return
+		}
+	}
+	s.println("Error (unknown option): " + name)
+}
+
+// newGame handles "new": the clocks go back to the start of the time control
+// and the depth limit set by sd is dropped. The time control, st included,
+// stays for the next game.
+func (s *session) newGame() {
+	s.gs = chess.NewGameState()
+	s.engine.TT.Clear()
+	s.engineWhite, s.engineBlack = false, true
+	s.depth = 0
+	s.engineClock, s.otherClock = s.base, s.base
+}
+
+// level handles "level MPS BASE INC", where MPS is the number of moves per
+// time control or 0, BASE minutes or minutes:seconds and INC seconds.
+func (s *session) level(args []string) {
+	if len(args) != 3 {
+		s.println("Error (bad level): " + strings.Join(args, " "))
+		return
+	}
+	minutes, seconds, _ := strings.Cut(args[1], ":")
+	m, _ := strconv.Atoi(minutes)
+	sec, _ := strconv.Atoi(seconds)
+	inc, _ := strconv.ParseFloat(args[2], 64)
+	s.movesPerSession, _ = strconv.Atoi(args[0])
+	s.base = time.Duration(m)*time.Minute + time.Duration(sec)*time.Second
+	s.increment = time.Duration(inc * float64(time.Second))
+	s.engineClock, s.otherClock = s.base, s.base
+	s.moveTime = 0
+}
+
+func (s *session) engineToMove() bool {
+	if s.gs.WhiteToMove {
+		return s.engineWhite
+	}
+	return s.engineBlack
+}
+
+func (s *session) userMove(text string) {
+	move, err := s.gs.ParseMove(text)
+	if err != nil {
+		s.println("Illegal move: " + text)
+		return
+	}
+	s.gs.MakeMove(move)
+	if !s.reportResult() {
+		s.think()
+	}
+}
+
+// reportResult tells the GUI if the game has just ended, and stops the engine
+// playing on.
+func (s *session) reportResult() bool {
+	outcome := s.gs.Outcome()
+	if outcome.Result == chess.NoResult {
+		return false
+	}
+	s.println(fmt.Sprintf("%v {%v}", outcome.Result, outcome.Reason))
+	s.engineWhite, s.engineBlack = false, false
+	return true
+}
+
+// think starts a search if it is the engine's turn.
+func (s *session) think() {
+	if !s.engineToMove() || s.searching {
+		return
+	}
+
+	limits := engine.Limits{Depth: s.depth, MoveTime: s.moveTime}
+	if s.moveTime == 0 && s.engineClock > 0 {
+		limits.WhiteTime, limits.BlackTime = s.engineClock, s.otherClock
+		limits.WhiteInc, limits.BlackInc = s.increment, s.increment
+		if !s.gs.WhiteToMove {
+			limits.WhiteTime, limits.BlackTime = s.otherClock, s.engineClock
+		}
+		if s.movesPerSession > 0 {
+			limits.MovesToGo = s.movesPerSession - (s.gs.FullmoveNumber-1)%s.movesPerSession
+		}
+	}
+	if limits == (engine.Limits{}) {
+		// No time control has been set, so don't think forever.
+		limits.Depth = engine.DefaultDepth
+	}
+	stop := make(chan struct{})
+	s.searching, s.stop = true, stop
+	limits.Stop = stop
+
+	post := s.post
+	s.engine.OnInfo = func(info engine.Info) {
+		if post {
+			s.printThinking(info)
+		}
+	}
+
+	gs := s.gs.Clone()
+	go func() {
+		s.results <- s.engine.Search(gs, limits)
+	}()
+}
+
+func (s *session) moveNow() {
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+}
+
+// cancelSearch abandons the running search without playing its move.
+func (s *session) cancelSearch() {
+	if !s.searching {
+		return
+	}
+	s.moveNow()
+	<-s.results
+	s.searching = false
+	s.answerPings()
+}
+
+func (s *session) finishSearch(result engine.SearchResult) {
+	s.searching, s.stop = false, nil
+	if result.Move.PieceMoved != "" && s.engineToMove() {
+		s.gs.MakeMove(result.Move)
+		s.println("move " + result.Move.GetUCINotation())
+		s.reportResult()
+	}
+	s.answerPings()
+}
+
+func (s *session) answerPings() {
+	for _, ping := range s.pendingPings {
+		s.println("pong " + ping)
+	}
+	s.pendingPings = nil
+}
+
+// printThinking writes the ply, score, time in centiseconds, nodes and
+// principal variation after each depth. Mates are shown as
+// 100000 plus the number of moves, as XBoard expects, and tablebase wins as
+// about 20000.
+func (s *session) printThinking(info engine.Info) {
+	score := info.Score
+	switch {
+	case score >= engine.MateScore-engine.MaxDepth:
+		score = 100000 + (engine.MateScore-score+1)/2
+	case score <= -engine.MateScore+engine.MaxDepth:
+		score = -100000 - (engine.MateScore+score)/2
+	default:
+		score = engine.ReportedCentipawns(score)
+	}
+	pv := make([]string, len(info.PV))
+	for i, move := range info.PV {
+		pv[i] = move.GetUCINotation()
+	}
+	s.println(fmt.Sprintf("%d %d %d %d %s", info.Depth, score, info.Time.Milliseconds()/10, info.Nodes, strings.Join(pv, " ")))
+}
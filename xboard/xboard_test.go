@@ -0,0 +1,271 @@
+package xboard
+
+import (
+	"bufio"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattellis91/go-chess/chess"
+	"github.com/mattellis91/go-chess/engine"
+)
+
+// harness runs a CECP session on pipes so a test can write commands and wait for
+// replies the way a GUI would.
+type harness struct {
+	t     *testing.T
+	in    *io.PipeWriter
+	lines chan string
+	done  chan error
+}
+
+func newHarness(t *testing.T) *harness {
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	h := &harness{t: t, in: inWriter, lines: make(chan string, 1000), done: make(chan error, 1)}
+
+	go func() {
+		h.done <- Run(inReader, outWriter)
+		outWriter.Close()
+	}()
+	go func() {
+		scanner := bufio.NewScanner(outReader)
+		for scanner.Scan() {
+			h.lines <- scanner.Text()
+		}
+		close(h.lines)
+	}()
+	t.Cleanup(func() {
+		inWriter.Close()
+	})
+	return h
+}
+
+func (h *harness) send(command string) {
+	h.t.Helper()
+	if _, err := io.WriteString(h.in, command+"\n"); err != nil {
+		h.t.Fatalf("sending %q: %v", command, err)
+	}
+}
+
+// next returns the next line the engine writes.
+func (h *harness) next() string {
+	h.t.Helper()
+	select {
+	case line, ok := <-h.lines:
+		if !ok {
+			h.t.Fatal("engine closed its output")
+		}
+		return line
+	case <-time.After(10 * time.Second):
+		h.t.Fatal("timed out waiting for the engine")
+	}
+	return ""
+}
+
+// run plays a script. Lines starting with "> " are sent to the engine, lines
+// starting with "< " must be the start of the next line it writes and lines
+// starting with "~ " skip output until a line that starts that way. It
+// returns the last line read.
+func (h *harness) run(script ...string) string {
+	h.t.Helper()
+	last := ""
+	for _, step := range script {
+		text := step[2:]
+		switch step[:2] {
+		case "> ":
+			h.send(text)
+		case "< ":
+			if last = h.next(); !strings.HasPrefix(last, text) {
+				h.t.Fatalf("engine wrote %q, want %q", last, text)
+			}
+		case "~ ":
+			for last = h.next(); !strings.HasPrefix(last, text); last = h.next() {
+			}
+		default:
+			h.t.Fatalf("bad script step %q", step)
+		}
+	}
+	return last
+}
+
+func TestFeatures(t *testing.T) {
+	h := newHarness(t)
+	line := h.run(
+		"> xboard",
+		"> protover 2",
+		"< feature ",
+	)
+	for _, want := range []string{`myname="go-chess"`, "setboard=1", "usermove=1", "ping=1", `egt="syzygy"`, `option="NullMove -check 1"`} {
+		if !strings.Contains(line, want) {
+			t.Errorf("%q does not offer %s", line, want)
+		}
+	}
+	if !strings.HasSuffix(line, "done=1") {
+		t.Errorf("%q does not end with done=1", line)
+	}
+	h.run(
+		"> memory 8",
+		"> cores 2",
+		"> egtpath syzygy /no/such/dir",
+		"< Error (open /no/such/dir",
+		"> option NullMove=0",
+		"> option Contempt=10",
+		"< Error (unknown option): Contempt",
+		"> ping 7",
+		"< pong 7",
+		"> quit",
+	)
+	if err := <-h.done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestGame plays both sides of the usual command sequence: the engine replies
+// to the user's moves as black, then takes over white after go.
+func TestGame(t *testing.T) {
+	h := newHarness(t)
+	reply := h.run(
+		"> new",
+		"> sd 2",
+		"> usermove e2e4",
+		"~ move ",
+	)
+	gs := chess.NewGameState()
+	play := func(text string) {
+		t.Helper()
+		move, err := gs.ParseMove(text)
+		if err != nil {
+			t.Fatalf("engine played %q: %v", text, err)
+		}
+		gs.MakeMove(move)
+	}
+	play("e2e4")
+	play(strings.TrimPrefix(reply, "move "))
+
+	h.run(
+		"> force",
+		"> usermove g1f3",
+		"> ping 1",
+		"< pong 1",
+	)
+	play("g1f3")
+
+	reply = h.run(
+		"> go",
+		"~ move ",
+	)
+	play(strings.TrimPrefix(reply, "move "))
+	if !gs.WhiteToMove {
+		t.Error("after go the engine should have played black")
+	}
+}
+
+func TestIllegalMoveAndUndo(t *testing.T) {
+	h := newHarness(t)
+	h.run(
+		"> new",
+		"> force",
+		"> usermove e2e5",
+		"< Illegal move: e2e5",
+		"> usermove e2e4",
+		"> usermove e7e5",
+		"> undo",
+		"> usermove e7e6",
+		"> remove",
+		"> usermove e2e3",
+		"> ping 2",
+		"< pong 2",
+		"> banana",
+		"< Error (unknown command): banana",
+	)
+}
+
+func TestSetboardAndMate(t *testing.T) {
+	h := newHarness(t)
+	h.run(
+		"> new",
+		"> force",
+		"> setboard 8/8/8",
+		"< tellusererror Illegal position",
+		"> setboard 6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1",
+		"> post",
+		"> sd 2",
+		"> go",
+		"~ 2 100001 ",
+		"< move a1a8",
+		"< 1-0 {checkmate}",
+	)
+}
+
+func TestPingWaitsForMove(t *testing.T) {
+	h := newHarness(t)
+	h.run(
+		"> new",
+		"> st 0.2",
+		"> usermove d2d4",
+		"> ping 3",
+		"< move ",
+		"< pong 3",
+		"> level 40 0:30 0",
+		"> time 3000",
+		"> otim 3000",
+		"> usermove c2c4",
+		"~ move ",
+	)
+}
+
+func TestMoveNow(t *testing.T) {
+	h := newHarness(t)
+	start := time.Now()
+	h.run(
+		"> new",
+		"> st 60",
+		"> usermove e2e4",
+		"> ?",
+		"~ move ",
+	)
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("? took %v to produce a move", elapsed)
+	}
+}
+
+func TestNewResetsClocks(t *testing.T) {
+	s := &session{engine: engine.NewEngine(), out: io.Discard, results: make(chan engine.SearchResult, 1)}
+	for _, command := range []string{"level 40 5 2", "time 1234", "otim 5678", "st 3", "sd 4", "new"} {
+		s.handle(command)
+	}
+	if s.engineClock != 5*time.Minute || s.otherClock != 5*time.Minute {
+		t.Errorf("after new the clocks are %v and %v, want 5m0s", s.engineClock, s.otherClock)
+	}
+	if s.moveTime != 3*time.Second || s.movesPerSession != 40 {
+		t.Errorf("after new st is %v and the moves per session %d, want 3s and 40", s.moveTime, s.movesPerSession)
+	}
+	if s.depth != 0 {
+		t.Errorf("after new sd is %d, want 0", s.depth)
+	}
+}
+
+// TestQuitStopsReading checks that the goroutine reading commands does not
+// outlive Run when more input follows quit.
+func TestQuitStopsReading(t *testing.T) {
+	before := runtime.NumGoroutine()
+	inReader, inWriter := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- Run(inReader, io.Discard)
+	}()
+	io.WriteString(inWriter, "quit\n")
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	// The write returns once the reading goroutine has taken the line.
+	io.WriteString(inWriter, "new\n")
+	for deadline := time.Now().Add(5 * time.Second); runtime.NumGoroutine() > before; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines running after Run returned, %d before", runtime.NumGoroutine(), before)
+		}
+	}
+}